@@ -0,0 +1,22 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/rpc"
+)
+
+// Runs the offline block engine, start the bot with BLOCKENGINE_URL=http://<addr> to use it
+func main() {
+	addr := flag.String("addr", "127.0.0.1:9900", "listen address")
+	pendingPolls := flag.Int("pending-polls", 1, "in-flight polls reported as pending before a bundle resolves")
+	flag.Parse()
+
+	engine := rpc.NewLocalBlockEngine()
+	engine.PendingPolls = *pendingPolls
+
+	log.Printf("Local block engine listening on http://%s%s", *addr, rpc.JITO_BUNDLES_PATH)
+	log.Fatal(http.ListenAndServe(*addr, engine))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/config"
+	db "github.com/iqbalbaharum/lp-remove-tracker/internal/database"
+)
+
+const usage = `usage: migrate <command>
+
+commands:
+  up              apply every pending migration
+  down [steps]    revert the last steps migrations, default 1
+  to <version>    migrate up or down to version, 0 reverts everything
+  status          list migrations and whether they are applied
+`
+
+// Migrates the database in MYSQL_DSN and MYSQL_DBNAME with the migrations built into the binary
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := config.InitEnv(); err != nil {
+		log.Fatal(err)
+	}
+
+	client, err := sql.Open("mysql", config.MySqlDsn)
+	if err != nil {
+		log.Fatalf("Failed to connect to MySQL: %v", err)
+	}
+	defer client.Close()
+
+	database, err := db.NewDatabase(client, config.MySqlDbName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := database.CreateDatabase(); err != nil {
+		log.Fatal(err)
+	}
+
+	migrator, err := database.Migrator()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch flag.Arg(0) {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		steps := 1
+		if flag.NArg() > 1 {
+			steps, err = strconv.Atoi(flag.Arg(1))
+			if err != nil || steps < 1 {
+				log.Fatalf("Invalid steps %q", flag.Arg(1))
+			}
+		}
+		err = migrator.Down(steps)
+	case "to":
+		if flag.NArg() < 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		version, parseErr := strconv.ParseUint(flag.Arg(1), 10, 64)
+		if parseErr != nil {
+			log.Fatalf("Invalid version %q", flag.Arg(1))
+		}
+		err = migrator.To(version)
+	case "status":
+		var statuses []db.MigrationStatus
+		statuses, err = migrator.Status()
+		for _, status := range statuses {
+			applied := "pending"
+			if status.Applied {
+				applied = "applied " + status.AppliedAt.UTC().Format(time.DateTime)
+			}
+			fmt.Printf("%04d %-32s %s\n", status.Version, status.Name, applied)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,346 @@
+package arbitrage
+
+import (
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/config"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/liquidity"
+)
+
+// Lamports charged per signature
+const BASE_FEE_LAMPORTS = 5000
+
+type Config struct {
+	TipLamports         uint64
+	PriorityFeeLamports uint64
+	MinProfitLamports   uint64
+	MaxInputLamports    uint64
+	MaxOpportunities    int
+}
+
+type Hop struct {
+	AmmId      solana.PublicKey
+	InputMint  solana.PublicKey
+	OutputMint solana.PublicKey
+	Direction  liquidity.SwapDirection
+	AmountIn   uint64
+	AmountOut  uint64
+}
+
+type Opportunity struct {
+	Hops        []Hop
+	AmountIn    uint64
+	AmountOut   uint64
+	GrossProfit uint64
+	NetProfit   uint64
+	Slot        uint64
+}
+
+// RouteFinder keeps a graph of mints linked by Raydium pools and searches the
+// 2 and 3 hop WSOL cycles through every pool whose reserve changes.
+type RouteFinder struct {
+	config Config
+
+	pools map[solana.PublicKey]*pool
+	mints map[solana.PublicKey][]*pool
+	pairs map[pairKey][]*pool
+
+	mutex         sync.Mutex
+	pending       map[solana.PublicKey]liquidity.PoolReserve
+	signal        chan struct{}
+	opportunities chan []Opportunity
+}
+
+func NewRouteFinder(config Config) *RouteFinder {
+	return &RouteFinder{
+		config:        config,
+		pools:         make(map[solana.PublicKey]*pool),
+		mints:         make(map[solana.PublicKey][]*pool),
+		pairs:         make(map[pairKey][]*pool),
+		pending:       make(map[solana.PublicKey]liquidity.PoolReserve),
+		signal:        make(chan struct{}, 1),
+		opportunities: make(chan []Opportunity, 100),
+	}
+}
+
+// Update queues a reserve change. Changes to the same pool made before the
+// finder gets to them are coalesced, so a burst of swaps costs one search.
+func (f *RouteFinder) Update(reserve liquidity.PoolReserve) {
+	f.mutex.Lock()
+	f.pending[reserve.AmmId] = reserve
+	f.mutex.Unlock()
+
+	select {
+	case f.signal <- struct{}{}:
+	default:
+	}
+}
+
+// Opportunities delivers the profitable cycles found after each batch of updates, best first
+func (f *RouteFinder) Opportunities() <-chan []Opportunity {
+	return f.opportunities
+}
+
+func (f *RouteFinder) Run() {
+	for range f.signal {
+		f.mutex.Lock()
+		batch := f.pending
+		f.pending = make(map[solana.PublicKey]liquidity.PoolReserve)
+		f.mutex.Unlock()
+
+		changed := make([]*pool, 0, len(batch))
+		for _, reserve := range batch {
+			changed = append(changed, f.apply(reserve))
+		}
+
+		var found []Opportunity
+		seen := make(map[string]bool)
+		for _, p := range changed {
+			for _, cycle := range f.cyclesThrough(p) {
+				key := cycleKey(cycle)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+
+				if !quotable(cycle) {
+					continue
+				}
+
+				if opportunity, ok := f.evaluate(cycle); ok {
+					found = append(found, opportunity)
+				}
+			}
+		}
+
+		if len(found) == 0 {
+			continue
+		}
+
+		sort.Slice(found, func(i, j int) bool { return found[i].NetProfit > found[j].NetProfit })
+		if f.config.MaxOpportunities > 0 && len(found) > f.config.MaxOpportunities {
+			found = found[:f.config.MaxOpportunities]
+		}
+
+		select {
+		case f.opportunities <- found:
+		default:
+			log.Printf("Arbitrage | Dropped %d opportunities, consumer is behind", len(found))
+		}
+	}
+}
+
+func (f *RouteFinder) apply(reserve liquidity.PoolReserve) *pool {
+	p, exists := f.pools[reserve.AmmId]
+	if !exists {
+		p = &pool{
+			ammId:     reserve.AmmId,
+			baseMint:  reserve.BaseMint,
+			quoteMint: reserve.QuoteMint,
+		}
+
+		f.pools[p.ammId] = p
+		f.mints[p.baseMint] = append(f.mints[p.baseMint], p)
+		f.mints[p.quoteMint] = append(f.mints[p.quoteMint], p)
+
+		key := newPairKey(p.baseMint, p.quoteMint)
+		f.pairs[key] = append(f.pairs[key], p)
+	}
+
+	p.info = *reserve.PoolInfo()
+	p.slot = reserve.Slot
+	// The default fee would quote cycles the pool's own fee or PnL rule out
+	p.quotable = reserve.State != nil && reserve.State.SwapFeeNumerator < reserve.State.SwapFeeDenominator
+
+	return p
+}
+
+// cyclesThrough lists every WSOL -> ... -> WSOL cycle of 2 or 3 distinct pools that uses p
+func (f *RouteFinder) cyclesThrough(p *pool) [][]leg {
+	wsol := config.WRAPPED_SOL
+	var cycles [][]leg
+
+	if p.baseMint == wsol || p.quoteMint == wsol {
+		x := p.other(wsol)
+
+		// WSOL -> X -> WSOL
+		for _, other := range f.pairs[newPairKey(wsol, x)] {
+			if other == p {
+				continue
+			}
+			cycles = append(cycles,
+				[]leg{{p, wsol}, {other, x}},
+				[]leg{{other, wsol}, {p, x}},
+			)
+		}
+
+		// WSOL -> X -> Y -> WSOL, with p on either end
+		for _, middle := range f.mints[x] {
+			y := middle.other(x)
+			if middle == p || y == wsol {
+				continue
+			}
+
+			for _, last := range f.pairs[newPairKey(y, wsol)] {
+				cycles = append(cycles,
+					[]leg{{p, wsol}, {middle, x}, {last, y}},
+					[]leg{{last, wsol}, {middle, y}, {p, x}},
+				)
+			}
+		}
+
+		return cycles
+	}
+
+	// WSOL -> X -> Y -> WSOL with p in the middle, in both directions
+	for _, x := range []solana.PublicKey{p.baseMint, p.quoteMint} {
+		y := p.other(x)
+		for _, first := range f.pairs[newPairKey(wsol, x)] {
+			for _, last := range f.pairs[newPairKey(y, wsol)] {
+				cycles = append(cycles, []leg{{first, wsol}, {p, x}, {last, y}})
+			}
+		}
+	}
+
+	return cycles
+}
+
+// quotable tells if every pool of the cycle has its state read. The state of the ones routed
+// through is asked for, so the cycle is quoted on a later update.
+func quotable(cycle []leg) bool {
+	ok := true
+	for _, l := range cycle {
+		liquidity.WantPoolState(l.pool.ammId)
+		ok = ok && l.pool.quotable
+	}
+	return ok
+}
+
+// evaluate sizes the input that maximises the cycle's profit and keeps the
+// cycle only if it clears fees, tip and the configured minimum
+func (f *RouteFinder) evaluate(cycle []leg) (Opportunity, bool) {
+	if !marginallyProfitable(cycle) {
+		return Opportunity{}, false
+	}
+
+	first := cycle[0]
+	maxIn := first.pool.info.BaseReserve
+	if first.pool.direction(first.inputMint) == liquidity.QUOTE_TO_BASE {
+		maxIn = first.pool.info.QuoteReserve
+	}
+	if f.config.MaxInputLamports > 0 && f.config.MaxInputLamports < maxIn {
+		maxIn = f.config.MaxInputLamports
+	}
+
+	amountIn, amountOut := optimalInput(cycle, maxIn)
+	if amountOut <= amountIn {
+		return Opportunity{}, false
+	}
+
+	gross := amountOut - amountIn
+	costs := f.config.TipLamports + f.config.PriorityFeeLamports + BASE_FEE_LAMPORTS
+	if gross <= costs || gross-costs < f.config.MinProfitLamports {
+		return Opportunity{}, false
+	}
+
+	opportunity := Opportunity{
+		AmountIn:    amountIn,
+		AmountOut:   amountOut,
+		GrossProfit: gross,
+		NetProfit:   gross - costs,
+	}
+
+	amount := amountIn
+	for _, l := range cycle {
+		out := l.amountOut(amount)
+		opportunity.Hops = append(opportunity.Hops, Hop{
+			AmmId:      l.pool.ammId,
+			InputMint:  l.inputMint,
+			OutputMint: l.pool.other(l.inputMint),
+			Direction:  l.pool.direction(l.inputMint),
+			AmountIn:   amount,
+			AmountOut:  out,
+		})
+		opportunity.Slot = max(opportunity.Slot, l.pool.slot)
+		amount = out
+	}
+
+	return opportunity, true
+}
+
+// marginallyProfitable checks the product of the fee adjusted spot prices, a
+// cycle that loses on an infinitesimal trade loses on any size
+func marginallyProfitable(cycle []leg) bool {
+	rate := 1.0
+	for _, l := range cycle {
+		info := &l.pool.info
+		reserveIn, reserveOut := info.BaseReserve, info.QuoteReserve
+		if l.pool.direction(l.inputMint) == liquidity.QUOTE_TO_BASE {
+			reserveIn, reserveOut = reserveOut, reserveIn
+		}
+
+		if reserveIn == 0 || reserveOut == 0 {
+			return false
+		}
+
+		fee := float64(info.SwapFeeNumerator) / float64(info.SwapFeeDenominator)
+		rate *= (1 - fee) * float64(reserveOut) / float64(reserveIn)
+	}
+	return rate > 1
+}
+
+// optimalInput runs a ternary search over the integer input, profit along a
+// chain of constant product pools is concave in the input amount
+func optimalInput(cycle []leg, maxIn uint64) (uint64, uint64) {
+	if maxIn == 0 {
+		return 0, 0
+	}
+
+	// Lamport amounts stay far below 2^63, the total SOL supply is around 2^59
+	profit := func(amountIn uint64) (int64, uint64) {
+		amount := amountIn
+		for _, l := range cycle {
+			amount = l.amountOut(amount)
+			if amount == 0 {
+				return -int64(amountIn), 0
+			}
+		}
+		return int64(amount) - int64(amountIn), amount
+	}
+
+	lo, hi := uint64(1), maxIn
+	for hi-lo > 2 {
+		m1 := lo + (hi-lo)/3
+		m2 := hi - (hi-lo)/3
+
+		p1, _ := profit(m1)
+		p2, _ := profit(m2)
+		if p1 < p2 {
+			lo = m1 + 1
+		} else {
+			hi = m2 - 1
+		}
+	}
+
+	bestIn, bestOut := lo, uint64(0)
+	bestProfit := int64(-1 << 62)
+	for amountIn := lo; amountIn <= hi; amountIn++ {
+		p, out := profit(amountIn)
+		if p > bestProfit {
+			bestIn, bestOut, bestProfit = amountIn, out, p
+		}
+	}
+
+	return bestIn, bestOut
+}
+
+func cycleKey(cycle []leg) string {
+	key := ""
+	for _, l := range cycle {
+		key += l.pool.ammId.String() + l.inputMint.String()
+	}
+	return key
+}
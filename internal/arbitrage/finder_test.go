@@ -0,0 +1,149 @@
+package arbitrage
+
+import (
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/config"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/liquidity"
+)
+
+var (
+	mintX = solana.MustPublicKeyFromBase58("EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v")
+	mintY = solana.MustPublicKeyFromBase58("Es9vMFrzaCERmJfrF4H2FYD4KCoNkY11McCe8BenwNYB")
+)
+
+func addPool(f *RouteFinder, id byte, base solana.PublicKey, quote solana.PublicKey, baseReserve uint64, quoteReserve uint64) *pool {
+	return f.apply(liquidity.PoolReserve{
+		AmmId:     solana.PublicKey{id},
+		BaseMint:  base,
+		QuoteMint: quote,
+		Base:      baseReserve,
+		Quote:     quoteReserve,
+		Slot:      uint64(id),
+		State: &liquidity.PoolState{
+			SwapFeeNumerator:   liquidity.DEFAULT_SWAP_FEE_NUMERATOR,
+			SwapFeeDenominator: liquidity.DEFAULT_SWAP_FEE_DENOMINATOR,
+		},
+	})
+}
+
+// The expected input is the continuous optimum and the expected profit the best one found by
+// an exhaustive search around it with the program's swap math. The profit is flat near the
+// optimum and the floors make it only piecewise concave, so the search may stop within 0.05%
+// of the input and a few lamports short of the profit.
+func TestEvaluate(t *testing.T) {
+	wsol := config.WRAPPED_SOL
+
+	tests := []struct {
+		name     string
+		pools    func(f *RouteFinder) *pool
+		hops     int
+		amountIn uint64
+		profit   uint64
+	}{
+		{
+			name: "2 hops",
+			pools: func(f *RouteFinder) *pool {
+				addPool(f, 2, mintX, wsol, 1_000_000_000_000, 120_000_000_000)
+				return addPool(f, 1, wsol, mintX, 100_000_000_000, 1_000_000_000_000)
+			},
+			hops:     2,
+			amountIn: 4_652_758_415,
+			profit:   431_340_958,
+		},
+		{
+			name: "3 hops",
+			pools: func(f *RouteFinder) *pool {
+				addPool(f, 2, mintX, mintY, 4_000_000_000_000, 8_000_000_000)
+				addPool(f, 3, mintY, wsol, 10_000_000_000, 55_000_000_000)
+				return addPool(f, 1, wsol, mintX, 50_000_000_000, 5_000_000_000_000)
+			},
+			hops:     3,
+			amountIn: 693_898_813,
+			profit:   31_140_981,
+		},
+		{
+			name: "balanced pools",
+			pools: func(f *RouteFinder) *pool {
+				addPool(f, 2, mintX, wsol, 1_000_000_000_000, 100_000_000_000)
+				return addPool(f, 1, wsol, mintX, 100_000_000_000, 1_000_000_000_000)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewRouteFinder(Config{})
+			p := tt.pools(f)
+
+			var found []Opportunity
+			for _, cycle := range f.cyclesThrough(p) {
+				if opportunity, ok := f.evaluate(cycle); ok {
+					found = append(found, opportunity)
+				}
+			}
+
+			if tt.hops == 0 {
+				if len(found) != 0 {
+					t.Fatalf("found %d opportunities, want none", len(found))
+				}
+				return
+			}
+			if len(found) != 1 {
+				t.Fatalf("found %d opportunities, want 1", len(found))
+			}
+
+			opportunity := found[0]
+			if len(opportunity.Hops) != tt.hops || opportunity.Hops[0].AmmId != p.ammId || opportunity.Hops[0].InputMint != wsol {
+				t.Fatalf("unexpected route %+v", opportunity.Hops)
+			}
+			if last := opportunity.Hops[len(opportunity.Hops)-1]; last.OutputMint != wsol || last.AmountOut != opportunity.AmountOut {
+				t.Errorf("route does not end in the output: %+v", last)
+			}
+
+			if diff := absDiff(opportunity.AmountIn, tt.amountIn); diff*2_000 > tt.amountIn {
+				t.Errorf("got input %d, want about %d", opportunity.AmountIn, tt.amountIn)
+			}
+			if opportunity.GrossProfit > tt.profit || tt.profit-opportunity.GrossProfit > 8 {
+				t.Errorf("got profit %d, want %d", opportunity.GrossProfit, tt.profit)
+			}
+			if opportunity.NetProfit != opportunity.GrossProfit-BASE_FEE_LAMPORTS {
+				t.Errorf("got net profit %d for gross %d", opportunity.NetProfit, opportunity.GrossProfit)
+			}
+		})
+	}
+}
+
+func TestCyclesThrough(t *testing.T) {
+	wsol := config.WRAPPED_SOL
+
+	f := NewRouteFinder(Config{})
+	a := addPool(f, 1, wsol, mintX, 1, 1)
+	addPool(f, 2, mintX, wsol, 1, 1)
+	middle := addPool(f, 3, mintX, mintY, 1, 1)
+	addPool(f, 4, mintY, wsol, 1, 1)
+
+	// a with the other WSOL/X pool both ways, and a on either end of the route through Y
+	if cycles := f.cyclesThrough(a); len(cycles) != 4 {
+		t.Errorf("got %d cycles through a WSOL pool, want 4", len(cycles))
+	}
+
+	// Through X/Y both ways, starting from either WSOL/X pool
+	cycles := f.cyclesThrough(middle)
+	if len(cycles) != 4 {
+		t.Fatalf("got %d cycles through the middle pool, want 4", len(cycles))
+	}
+	for _, cycle := range cycles {
+		if cycle[0].inputMint != wsol || cycle[1].pool != middle || cycle[2].pool.other(cycle[2].inputMint) != wsol {
+			t.Errorf("not a WSOL cycle through the middle pool: %+v", cycle)
+		}
+	}
+}
+
+func absDiff(a uint64, b uint64) uint64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
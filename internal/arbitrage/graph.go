@@ -0,0 +1,97 @@
+package arbitrage
+
+import (
+	"bytes"
+	"math/bits"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/liquidity"
+)
+
+type pool struct {
+	ammId     solana.PublicKey
+	baseMint  solana.PublicKey
+	quoteMint solana.PublicKey
+	info      liquidity.LiquidityPoolInfo
+	slot      uint64
+	quotable  bool
+}
+
+type pairKey struct {
+	a solana.PublicKey
+	b solana.PublicKey
+}
+
+// leg is one swap of a cycle: a pool traded from inputMint to its other mint
+type leg struct {
+	pool      *pool
+	inputMint solana.PublicKey
+}
+
+func newPairKey(x solana.PublicKey, y solana.PublicKey) pairKey {
+	if bytes.Compare(x[:], y[:]) < 0 {
+		return pairKey{x, y}
+	}
+	return pairKey{y, x}
+}
+
+func (p *pool) other(mint solana.PublicKey) solana.PublicKey {
+	if p.baseMint == mint {
+		return p.quoteMint
+	}
+	return p.baseMint
+}
+
+func (p *pool) direction(inputMint solana.PublicKey) liquidity.SwapDirection {
+	if p.baseMint == inputMint {
+		return liquidity.BASE_TO_QUOTE
+	}
+	return liquidity.QUOTE_TO_BASE
+}
+
+// amountOut is liquidity.ComputeAmountOut on 128 bit integers, without allocations.
+// It returns 0 whenever the trade cannot be executed.
+func (l leg) amountOut(amountIn uint64) uint64 {
+	info := &l.pool.info
+	reserveIn, reserveOut := info.BaseReserve, info.QuoteReserve
+	if l.pool.direction(l.inputMint) == liquidity.QUOTE_TO_BASE {
+		reserveIn, reserveOut = reserveOut, reserveIn
+	}
+
+	if amountIn == 0 || reserveIn == 0 || reserveOut == 0 {
+		return 0
+	}
+
+	hi, lo := bits.Mul64(amountIn, info.SwapFeeNumerator)
+	fee := ceilDiv128(hi, lo, info.SwapFeeDenominator)
+	inAfterFee := amountIn - fee
+
+	denominator, carry := bits.Add64(reserveIn, inAfterFee, 0)
+	if carry != 0 {
+		return 0
+	}
+
+	hi, lo = bits.Mul64(reserveOut, inAfterFee)
+	out, _ := bits.Div64(hi, lo, denominator)
+
+	return out
+}
+
+// ceilDiv128 mirrors the program's CheckedCeilDiv, see liquidity.ceilDiv
+func ceilDiv128(hi uint64, lo uint64, denominator uint64) uint64 {
+	quotient, remainder := bits.Div64(hi, lo, denominator)
+
+	// A zero quotient means hi is 0 and lo < denominator, so this is 2*lo >= denominator
+	if quotient == 0 {
+		if lo >= denominator-lo {
+			return 1
+		}
+		return 0
+	}
+
+	if remainder > 0 {
+		quotient++
+	}
+
+	return quotient
+}
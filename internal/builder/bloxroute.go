@@ -0,0 +1,19 @@
+package builder
+
+import (
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/config"
+)
+
+const BLOXROUTE_MEMO_TEXT = "Powered by bloXroute Trader Api"
+
+// Memo bloXroute expects on transactions submitted through the Trader API
+func BloxRouteMemoInstruction() solana.Instruction {
+	return solana.NewInstruction(config.BLOXROUTE_MEMO, solana.AccountMetaSlice{}, []byte(BLOXROUTE_MEMO_TEXT))
+}
+
+// Tip transfer to bloXroute, transactions without one are not forwarded
+func BloxRouteTipInstruction(payer solana.PublicKey, lamports uint64) solana.Instruction {
+	return system.NewTransferInstruction(lamports, payer, config.BLOXROUTE_TIP).Build()
+}
@@ -0,0 +1,255 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	computebudget "github.com/gagliardetto/solana-go/programs/compute-budget"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/programs/token"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/coder"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/config"
+	bot "github.com/iqbalbaharum/lp-remove-tracker/internal/library"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/liquidity"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
+)
+
+type Side int
+
+const (
+	// BUY spends WSOL for the pool token
+	BUY Side = iota
+	// SELL spends the pool token for WSOL
+	SELL
+)
+
+func (s Side) String() string {
+	if s == BUY {
+		return "BUY"
+	}
+	return "SELL"
+}
+
+var (
+	ErrMissingPoolKeys = errors.New("missing pool keys")
+	ErrMissingOwner    = errors.New("missing owner")
+	ErrZeroAmount      = errors.New("swap amount is zero")
+)
+
+// Tip is a transfer to a relay's tip account
+type Tip struct {
+	Account  solana.PublicKey
+	Lamports uint64
+}
+
+type SwapParams struct {
+	PoolKeys *types.RaydiumPoolKeys
+	// Owner pays for the transaction and owns the token accounts
+	Owner solana.PublicKey
+	Side  Side
+	// ExactOut builds a SwapBaseOut instead of a SwapBaseIn
+	ExactOut bool
+	// AmountIn is the exact input for SwapBaseIn and the maximum input for SwapBaseOut
+	AmountIn uint64
+	// AmountOut is the minimum output for SwapBaseIn and the exact output for SwapBaseOut
+	AmountOut        uint64
+	ComputeUnitLimit uint32
+	// ComputeUnitPrice is in micro-lamports per compute unit
+	ComputeUnitPrice uint64
+	// Tips are paid in order, tipping config.BLOXROUTE_TIP also adds the bloXroute memo
+	Tips []Tip
+	// LookupTable holds the addresses of PoolKeys.LookupTableAccount. When empty they
+	// are fetched from the lookup table cache.
+	LookupTable     solana.PublicKeySlice
+	RecentBlockhash solana.Hash
+}
+
+// Build the swap and sign it with the owner's key
+func BuildSwapTransaction(params SwapParams, payer solana.PrivateKey) (*solana.Transaction, error) {
+	if !payer.PublicKey().Equals(params.Owner) {
+		return nil, fmt.Errorf("payer %s is not the owner %s", payer.PublicKey(), params.Owner)
+	}
+
+	tx, err := NewSwapTransaction(params)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
+		if key.Equals(params.Owner) {
+			return &payer
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// Build the unsigned v0 swap transaction
+func NewSwapTransaction(params SwapParams) (*solana.Transaction, error) {
+	instructions, err := SwapInstructions(params)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []solana.TransactionOption{solana.TransactionPayer(params.Owner)}
+
+	if lookupTable := params.PoolKeys.LookupTableAccount; !lookupTable.IsZero() {
+		addresses := params.LookupTable
+		if len(addresses) == 0 {
+			table, err := bot.GetLookupTable(lookupTable)
+			if err != nil {
+				return nil, fmt.Errorf("lookup table %s: %w", lookupTable, err)
+			}
+			addresses = table.Addresses
+		}
+
+		opts = append(opts, solana.TransactionAddressTables(map[solana.PublicKey]solana.PublicKeySlice{
+			lookupTable: addresses,
+		}))
+	}
+
+	return solana.NewTransaction(instructions, params.RecentBlockhash, opts...)
+}
+
+// Every instruction of the swap in execution order: compute budget, account setup, WSOL wrap,
+// swap, WSOL unwrap and tip
+func SwapInstructions(params SwapParams) ([]solana.Instruction, error) {
+	pKey := params.PoolKeys
+	if pKey == nil || pKey.ID.IsZero() {
+		return nil, ErrMissingPoolKeys
+	}
+
+	if params.Owner.IsZero() {
+		return nil, ErrMissingOwner
+	}
+
+	if params.AmountIn == 0 || (params.ExactOut && params.AmountOut == 0) {
+		return nil, ErrZeroAmount
+	}
+
+	mint, _, err := liquidity.GetMint(pKey)
+	if err != nil {
+		return nil, err
+	}
+
+	owner := params.Owner
+
+	wsolAccount, _, err := solana.FindAssociatedTokenAddress(owner, config.WRAPPED_SOL)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenAccount, _, err := solana.FindAssociatedTokenAddress(owner, mint)
+	if err != nil {
+		return nil, err
+	}
+
+	var instructions []solana.Instruction
+
+	if params.ComputeUnitLimit > 0 {
+		instructions = append(instructions, computebudget.NewSetComputeUnitLimitInstruction(params.ComputeUnitLimit).Build())
+	}
+
+	if params.ComputeUnitPrice > 0 {
+		instructions = append(instructions, computebudget.NewSetComputeUnitPriceInstruction(params.ComputeUnitPrice).Build())
+	}
+
+	instructions = append(instructions, CreateAssociatedTokenAccountIdempotent(owner, owner, config.WRAPPED_SOL, wsolAccount))
+
+	source, destination := wsolAccount, tokenAccount
+	if params.Side == BUY {
+		// For SwapBaseOut AmountIn is the maximum, the unused WSOL is returned on unwrap
+		instructions = append(instructions,
+			CreateAssociatedTokenAccountIdempotent(owner, owner, mint, tokenAccount),
+			system.NewTransferInstruction(params.AmountIn, owner, wsolAccount).Build(),
+			token.NewSyncNativeInstruction(wsolAccount).Build(),
+		)
+	} else {
+		source, destination = tokenAccount, wsolAccount
+	}
+
+	swap, err := SwapInstruction(pKey, source, destination, owner, params.ExactOut, params.AmountIn, params.AmountOut)
+	if err != nil {
+		return nil, err
+	}
+	instructions = append(instructions, swap)
+
+	instructions = append(instructions, token.NewCloseAccountInstruction(wsolAccount, owner, owner, []solana.PublicKey{}).Build())
+
+	for _, tip := range params.Tips {
+		if tip.Account.IsZero() || tip.Lamports == 0 {
+			continue
+		}
+		if tip.Account == config.BLOXROUTE_TIP {
+			instructions = append(instructions, BloxRouteMemoInstruction())
+		}
+		instructions = append(instructions, system.NewTransferInstruction(tip.Lamports, owner, tip.Account).Build())
+	}
+
+	return instructions, nil
+}
+
+// Raydium swap instruction. OpenBook markets take the 18 account layout with the target orders,
+// every other market takes the 17 account layout.
+func SwapInstruction(pKey *types.RaydiumPoolKeys, source solana.PublicKey, destination solana.PublicKey, owner solana.PublicKey, exactOut bool, amountIn uint64, amountOut uint64) (solana.Instruction, error) {
+	var data []byte
+	var err error
+
+	instructionCoder := coder.NewRaydiumAmmInstructionCoder()
+	if exactOut {
+		data, err = instructionCoder.Encode(coder.SwapBaseOut{MaxAmountIn: amountIn, AmountOut: amountOut})
+	} else {
+		data, err = instructionCoder.Encode(coder.SwapBaseIn{AmountIn: amountIn, MinimumAmountOut: amountOut})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	layout, size := coder.SwapAccounts17, 17
+	if pKey.MarketProgramID == config.OPENBOOK_ID {
+		layout, size = coder.SwapAccounts18, 18
+	}
+
+	accounts := make(solana.AccountMetaSlice, size)
+	accounts[layout.TokenProgram] = solana.Meta(config.TOKEN_PROGRAM_ID)
+	accounts[layout.Amm] = solana.Meta(pKey.ID).WRITE()
+	accounts[layout.AmmAuthority] = solana.Meta(pKey.Authority)
+	accounts[layout.AmmOpenOrders] = solana.Meta(pKey.OpenOrders).WRITE()
+	if layout.AmmTargetOrders >= 0 {
+		accounts[layout.AmmTargetOrders] = solana.Meta(pKey.TargetOrders).WRITE()
+	}
+	accounts[layout.PoolCoinVault] = solana.Meta(pKey.BaseVault).WRITE()
+	accounts[layout.PoolPcVault] = solana.Meta(pKey.QuoteVault).WRITE()
+	accounts[layout.MarketProgram] = solana.Meta(pKey.MarketProgramID)
+	accounts[layout.Market] = solana.Meta(pKey.MarketID).WRITE()
+	accounts[layout.MarketBids] = solana.Meta(pKey.MarketBids).WRITE()
+	accounts[layout.MarketAsks] = solana.Meta(pKey.MarketAsks).WRITE()
+	accounts[layout.MarketEventQueue] = solana.Meta(pKey.MarketEventQueue).WRITE()
+	accounts[layout.MarketCoinVault] = solana.Meta(pKey.MarketBaseVault).WRITE()
+	accounts[layout.MarketPcVault] = solana.Meta(pKey.MarketQuoteVault).WRITE()
+	accounts[layout.MarketVaultSigner] = solana.Meta(pKey.MarketAuthority)
+	accounts[layout.UserSourceToken] = solana.Meta(source).WRITE()
+	accounts[layout.UserDestToken] = solana.Meta(destination).WRITE()
+	accounts[layout.UserOwner] = solana.Meta(owner).SIGNER()
+
+	return solana.NewInstruction(config.RAYDIUM_AMM_V4, accounts, data), nil
+}
+
+// Associated token account creation that succeeds when the account already exists
+func CreateAssociatedTokenAccountIdempotent(payer solana.PublicKey, wallet solana.PublicKey, mint solana.PublicKey, account solana.PublicKey) solana.Instruction {
+	accounts := solana.AccountMetaSlice{
+		solana.Meta(payer).WRITE().SIGNER(),
+		solana.Meta(account).WRITE(),
+		solana.Meta(wallet),
+		solana.Meta(mint),
+		solana.Meta(solana.SystemProgramID),
+		solana.Meta(config.TOKEN_PROGRAM_ID),
+	}
+
+	return solana.NewInstruction(config.ASSOCIATED_TOKEN_PROGRAM_ID, accounts, []byte{1})
+}
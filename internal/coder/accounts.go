@@ -0,0 +1,435 @@
+package coder
+
+// Account positions of every AMM v4 instruction, following the order the
+// program's instruction builders pass them in.
+
+type InitializeAccountLayout struct {
+	TokenProgram    int
+	SystemProgram   int
+	Rent            int
+	Amm             int
+	AmmAuthority    int
+	AmmOpenOrders   int
+	LpMint          int
+	CoinMint        int
+	PcMint          int
+	PoolCoinVault   int
+	PoolPcVault     int
+	WithdrawQueue   int
+	AmmTargetOrders int
+	UserLpToken     int
+	PoolTempLp      int
+	MarketProgram   int
+	Market          int
+	UserWallet      int
+}
+
+type Initialize2AccountLayout struct {
+	TokenProgram           int
+	AssociatedTokenProgram int
+	SystemProgram          int
+	Rent                   int
+	Amm                    int
+	AmmAuthority           int
+	AmmOpenOrders          int
+	LpMint                 int
+	CoinMint               int
+	PcMint                 int
+	PoolCoinVault          int
+	PoolPcVault            int
+	AmmTargetOrders        int
+	AmmConfig              int
+	CreateFeeDestination   int
+	MarketProgram          int
+	Market                 int
+	UserWallet             int
+	UserCoinToken          int
+	UserPcToken            int
+	UserLpToken            int
+}
+
+type MonitorStepAccountLayout struct {
+	TokenProgram      int
+	Rent              int
+	Clock             int
+	Amm               int
+	AmmAuthority      int
+	AmmOpenOrders     int
+	AmmTargetOrders   int
+	PoolCoinVault     int
+	PoolPcVault       int
+	MarketProgram     int
+	Market            int
+	MarketCoinVault   int
+	MarketPcVault     int
+	MarketVaultSigner int
+	MarketReqQueue    int
+	MarketEventQueue  int
+	MarketBids        int
+	MarketAsks        int
+}
+
+type DepositAccountLayout struct {
+	TokenProgram     int
+	Amm              int
+	AmmAuthority     int
+	AmmOpenOrders    int
+	AmmTargetOrders  int
+	LpMint           int
+	PoolCoinVault    int
+	PoolPcVault      int
+	Market           int
+	UserCoinToken    int
+	UserPcToken      int
+	UserLpToken      int
+	UserWallet       int
+	MarketEventQueue int
+}
+
+type WithdrawAccountLayout struct {
+	TokenProgram      int
+	Amm               int
+	AmmAuthority      int
+	AmmOpenOrders     int
+	AmmTargetOrders   int
+	LpMint            int
+	PoolCoinVault     int
+	PoolPcVault       int
+	PoolPcTempLp      int
+	PoolCoinTempLp    int
+	MarketProgram     int
+	Market            int
+	MarketCoinVault   int
+	MarketPcVault     int
+	MarketVaultSigner int
+	UserLpToken       int
+	UserCoinToken     int
+	UserPcToken       int
+	UserWallet        int
+	MarketEventQueue  int
+	MarketBids        int
+	MarketAsks        int
+	ReferrerPcWallet  int
+}
+
+type MigrateToOpenBookAccountLayout struct {
+	TokenProgram      int
+	SystemProgram     int
+	Rent              int
+	Amm               int
+	AmmAuthority      int
+	AmmOpenOrders     int
+	PoolCoinVault     int
+	PoolPcVault       int
+	AmmTargetOrders   int
+	MarketProgram     int
+	Market            int
+	MarketBids        int
+	MarketAsks        int
+	MarketEventQueue  int
+	MarketCoinVault   int
+	MarketPcVault     int
+	MarketVaultSigner int
+	NewAmmOpenOrders  int
+	NewMarketProgram  int
+	NewMarket         int
+	Admin             int
+}
+
+type SetParamsAccountLayout struct {
+	TokenProgram      int
+	Amm               int
+	AmmAuthority      int
+	AmmOpenOrders     int
+	AmmTargetOrders   int
+	PoolCoinVault     int
+	PoolPcVault       int
+	MarketProgram     int
+	Market            int
+	MarketCoinVault   int
+	MarketPcVault     int
+	MarketVaultSigner int
+	MarketEventQueue  int
+	MarketBids        int
+	MarketAsks        int
+	AmmAdmin          int
+	NewAmmOpenOrders  int
+}
+
+type WithdrawPnlAccountLayout struct {
+	TokenProgram      int
+	Amm               int
+	AmmConfig         int
+	AmmAuthority      int
+	AmmOpenOrders     int
+	PoolCoinVault     int
+	PoolPcVault       int
+	CoinPnlToken      int
+	PcPnlToken        int
+	PnlOwner          int
+	AmmTargetOrders   int
+	MarketProgram     int
+	Market            int
+	MarketEventQueue  int
+	MarketCoinVault   int
+	MarketPcVault     int
+	MarketVaultSigner int
+}
+
+type WithdrawSrmAccountLayout struct {
+	TokenProgram int
+	Amm          int
+	AmmOwner     int
+	AmmAuthority int
+	SrmToken     int
+	DestSrmToken int
+}
+
+// SwapAccountLayout covers both SwapBaseIn and SwapBaseOut. AmmTargetOrders is -1
+// in the 17 account layout, which drops it and shifts the rest down by one.
+type SwapAccountLayout struct {
+	TokenProgram      int
+	Amm               int
+	AmmAuthority      int
+	AmmOpenOrders     int
+	AmmTargetOrders   int
+	PoolCoinVault     int
+	PoolPcVault       int
+	MarketProgram     int
+	Market            int
+	MarketBids        int
+	MarketAsks        int
+	MarketEventQueue  int
+	MarketCoinVault   int
+	MarketPcVault     int
+	MarketVaultSigner int
+	UserSourceToken   int
+	UserDestToken     int
+	UserOwner         int
+}
+
+type PreInitializeAccountLayout struct {
+	TokenProgram    int
+	SystemProgram   int
+	Rent            int
+	AmmTargetOrders int
+	WithdrawQueue   int
+	AmmAuthority    int
+	LpMint          int
+	CoinMint        int
+	PcMint          int
+	PoolCoinVault   int
+	PoolPcVault     int
+	PoolTempLp      int
+	Market          int
+	UserWallet      int
+}
+
+type SimulateInfoAccountLayout struct {
+	Amm              int
+	AmmAuthority     int
+	AmmOpenOrders    int
+	PoolCoinVault    int
+	PoolPcVault      int
+	LpMint           int
+	Market           int
+	MarketEventQueue int
+}
+
+type AdminCancelOrdersAccountLayout struct {
+	TokenProgram      int
+	Amm               int
+	AmmAuthority      int
+	AmmOpenOrders     int
+	AmmTargetOrders   int
+	PoolCoinVault     int
+	PoolPcVault       int
+	AmmOwner          int
+	AmmConfig         int
+	MarketProgram     int
+	Market            int
+	MarketCoinVault   int
+	MarketPcVault     int
+	MarketVaultSigner int
+	MarketEventQueue  int
+	MarketBids        int
+	MarketAsks        int
+}
+
+type CreateConfigAccountLayout struct {
+	Admin                int
+	AmmConfig            int
+	CreateFeeDestination int
+	SystemProgram        int
+	Rent                 int
+}
+
+type UpdateConfigAccountLayout struct {
+	Admin     int
+	AmmConfig int
+}
+
+var InitializeAccounts = InitializeAccountLayout{
+	TokenProgram: 0, SystemProgram: 1, Rent: 2, Amm: 3, AmmAuthority: 4, AmmOpenOrders: 5,
+	LpMint: 6, CoinMint: 7, PcMint: 8, PoolCoinVault: 9, PoolPcVault: 10, WithdrawQueue: 11,
+	AmmTargetOrders: 12, UserLpToken: 13, PoolTempLp: 14, MarketProgram: 15, Market: 16, UserWallet: 17,
+}
+
+var Initialize2Accounts = Initialize2AccountLayout{
+	TokenProgram: 0, AssociatedTokenProgram: 1, SystemProgram: 2, Rent: 3, Amm: 4, AmmAuthority: 5,
+	AmmOpenOrders: 6, LpMint: 7, CoinMint: 8, PcMint: 9, PoolCoinVault: 10, PoolPcVault: 11,
+	AmmTargetOrders: 12, AmmConfig: 13, CreateFeeDestination: 14, MarketProgram: 15, Market: 16,
+	UserWallet: 17, UserCoinToken: 18, UserPcToken: 19, UserLpToken: 20,
+}
+
+var MonitorStepAccounts = MonitorStepAccountLayout{
+	TokenProgram: 0, Rent: 1, Clock: 2, Amm: 3, AmmAuthority: 4, AmmOpenOrders: 5, AmmTargetOrders: 6,
+	PoolCoinVault: 7, PoolPcVault: 8, MarketProgram: 9, Market: 10, MarketCoinVault: 11, MarketPcVault: 12,
+	MarketVaultSigner: 13, MarketReqQueue: 14, MarketEventQueue: 15, MarketBids: 16, MarketAsks: 17,
+}
+
+var DepositAccounts = DepositAccountLayout{
+	TokenProgram: 0, Amm: 1, AmmAuthority: 2, AmmOpenOrders: 3, AmmTargetOrders: 4, LpMint: 5,
+	PoolCoinVault: 6, PoolPcVault: 7, Market: 8, UserCoinToken: 9, UserPcToken: 10, UserLpToken: 11,
+	UserWallet: 12, MarketEventQueue: 13,
+}
+
+// WithdrawAccounts20 is the layout without the padding temp LP accounts
+var WithdrawAccounts20 = WithdrawAccountLayout{
+	TokenProgram: 0, Amm: 1, AmmAuthority: 2, AmmOpenOrders: 3, AmmTargetOrders: 4, LpMint: 5,
+	PoolCoinVault: 6, PoolPcVault: 7, PoolPcTempLp: -1, PoolCoinTempLp: -1, MarketProgram: 8, Market: 9,
+	MarketCoinVault: 10, MarketPcVault: 11, MarketVaultSigner: 12, UserLpToken: 13, UserCoinToken: 14,
+	UserPcToken: 15, UserWallet: 16, MarketEventQueue: 17, MarketBids: 18, MarketAsks: 19,
+	ReferrerPcWallet: -1,
+}
+
+// WithdrawAccounts22 pads the pool vaults with the two temp LP accounts the program skips
+var WithdrawAccounts22 = WithdrawAccountLayout{
+	TokenProgram: 0, Amm: 1, AmmAuthority: 2, AmmOpenOrders: 3, AmmTargetOrders: 4, LpMint: 5,
+	PoolCoinVault: 6, PoolPcVault: 7, PoolPcTempLp: 8, PoolCoinTempLp: 9, MarketProgram: 10, Market: 11,
+	MarketCoinVault: 12, MarketPcVault: 13, MarketVaultSigner: 14, UserLpToken: 15, UserCoinToken: 16,
+	UserPcToken: 17, UserWallet: 18, MarketEventQueue: 19, MarketBids: 20, MarketAsks: 21,
+	ReferrerPcWallet: -1,
+}
+
+// WithdrawAccounts23 is the padded layout with the referrer's PC wallet last
+var WithdrawAccounts23 = WithdrawAccountLayout{
+	TokenProgram: 0, Amm: 1, AmmAuthority: 2, AmmOpenOrders: 3, AmmTargetOrders: 4, LpMint: 5,
+	PoolCoinVault: 6, PoolPcVault: 7, PoolPcTempLp: 8, PoolCoinTempLp: 9, MarketProgram: 10, Market: 11,
+	MarketCoinVault: 12, MarketPcVault: 13, MarketVaultSigner: 14, UserLpToken: 15, UserCoinToken: 16,
+	UserPcToken: 17, UserWallet: 18, MarketEventQueue: 19, MarketBids: 20, MarketAsks: 21,
+	ReferrerPcWallet: 22,
+}
+
+// WithdrawAccountsOf returns the withdraw layout for the instruction's number of accounts, or
+// false for a count the program rejects
+func WithdrawAccountsOf(accounts int) (WithdrawAccountLayout, bool) {
+	switch accounts {
+	case 20:
+		return WithdrawAccounts20, true
+	case 22:
+		return WithdrawAccounts22, true
+	case 23:
+		return WithdrawAccounts23, true
+	default:
+		return WithdrawAccountLayout{}, false
+	}
+}
+
+var MigrateToOpenBookAccounts = MigrateToOpenBookAccountLayout{
+	TokenProgram: 0, SystemProgram: 1, Rent: 2, Amm: 3, AmmAuthority: 4, AmmOpenOrders: 5,
+	PoolCoinVault: 6, PoolPcVault: 7, AmmTargetOrders: 8, MarketProgram: 9, Market: 10, MarketBids: 11,
+	MarketAsks: 12, MarketEventQueue: 13, MarketCoinVault: 14, MarketPcVault: 15, MarketVaultSigner: 16,
+	NewAmmOpenOrders: 17, NewMarketProgram: 18, NewMarket: 19, Admin: 20,
+}
+
+var SetParamsAccounts = SetParamsAccountLayout{
+	TokenProgram: 0, Amm: 1, AmmAuthority: 2, AmmOpenOrders: 3, AmmTargetOrders: 4, PoolCoinVault: 5,
+	PoolPcVault: 6, MarketProgram: 7, Market: 8, MarketCoinVault: 9, MarketPcVault: 10,
+	MarketVaultSigner: 11, MarketEventQueue: 12, MarketBids: 13, MarketAsks: 14, AmmAdmin: 15,
+	NewAmmOpenOrders: 16,
+}
+
+var WithdrawPnlAccounts = WithdrawPnlAccountLayout{
+	TokenProgram: 0, Amm: 1, AmmConfig: 2, AmmAuthority: 3, AmmOpenOrders: 4, PoolCoinVault: 5,
+	PoolPcVault: 6, CoinPnlToken: 7, PcPnlToken: 8, PnlOwner: 9, AmmTargetOrders: 10, MarketProgram: 11,
+	Market: 12, MarketEventQueue: 13, MarketCoinVault: 14, MarketPcVault: 15, MarketVaultSigner: 16,
+}
+
+var WithdrawSrmAccounts = WithdrawSrmAccountLayout{
+	TokenProgram: 0, Amm: 1, AmmOwner: 2, AmmAuthority: 3, SrmToken: 4, DestSrmToken: 5,
+}
+
+// SwapAccounts18 is the layout with the AMM target orders account, used with OpenBook markets
+var SwapAccounts18 = SwapAccountLayout{
+	TokenProgram: 0, Amm: 1, AmmAuthority: 2, AmmOpenOrders: 3, AmmTargetOrders: 4, PoolCoinVault: 5,
+	PoolPcVault: 6, MarketProgram: 7, Market: 8, MarketBids: 9, MarketAsks: 10, MarketEventQueue: 11,
+	MarketCoinVault: 12, MarketPcVault: 13, MarketVaultSigner: 14, UserSourceToken: 15,
+	UserDestToken: 16, UserOwner: 17,
+}
+
+// SwapAccounts17 is the layout without the AMM target orders account
+var SwapAccounts17 = SwapAccountLayout{
+	TokenProgram: 0, Amm: 1, AmmAuthority: 2, AmmOpenOrders: 3, AmmTargetOrders: -1, PoolCoinVault: 4,
+	PoolPcVault: 5, MarketProgram: 6, Market: 7, MarketBids: 8, MarketAsks: 9, MarketEventQueue: 10,
+	MarketCoinVault: 11, MarketPcVault: 12, MarketVaultSigner: 13, UserSourceToken: 14,
+	UserDestToken: 15, UserOwner: 16,
+}
+
+var PreInitializeAccounts = PreInitializeAccountLayout{
+	TokenProgram: 0, SystemProgram: 1, Rent: 2, AmmTargetOrders: 3, WithdrawQueue: 4, AmmAuthority: 5,
+	LpMint: 6, CoinMint: 7, PcMint: 8, PoolCoinVault: 9, PoolPcVault: 10, PoolTempLp: 11, Market: 12,
+	UserWallet: 13,
+}
+
+var SimulateInfoAccounts = SimulateInfoAccountLayout{
+	Amm: 0, AmmAuthority: 1, AmmOpenOrders: 2, PoolCoinVault: 3, PoolPcVault: 4, LpMint: 5, Market: 6,
+	MarketEventQueue: 7,
+}
+
+var AdminCancelOrdersAccounts = AdminCancelOrdersAccountLayout{
+	TokenProgram: 0, Amm: 1, AmmAuthority: 2, AmmOpenOrders: 3, AmmTargetOrders: 4, PoolCoinVault: 5,
+	PoolPcVault: 6, AmmOwner: 7, AmmConfig: 8, MarketProgram: 9, Market: 10, MarketCoinVault: 11,
+	MarketPcVault: 12, MarketVaultSigner: 13, MarketEventQueue: 14, MarketBids: 15, MarketAsks: 16,
+}
+
+var CreateConfigAccounts = CreateConfigAccountLayout{
+	Admin: 0, AmmConfig: 1, CreateFeeDestination: 2, SystemProgram: 3, Rent: 4,
+}
+
+var UpdateConfigAccounts = UpdateConfigAccountLayout{
+	Admin: 0, AmmConfig: 1,
+}
+
+// AmmAccountIndex returns the position of the AMM account for a decoded
+// instruction, or false when the instruction does not reference a pool.
+func AmmAccountIndex(instruction interface{}) (int, bool) {
+	switch instruction.(type) {
+	case Initialize:
+		return InitializeAccounts.Amm, true
+	case Initialize2:
+		return Initialize2Accounts.Amm, true
+	case MonitorStep:
+		return MonitorStepAccounts.Amm, true
+	case Deposit:
+		return DepositAccounts.Amm, true
+	case Withdraw:
+		return WithdrawAccounts20.Amm, true
+	case MigrateToOpenBook:
+		return MigrateToOpenBookAccounts.Amm, true
+	case SetParams:
+		return SetParamsAccounts.Amm, true
+	case WithdrawPnl:
+		return WithdrawPnlAccounts.Amm, true
+	case WithdrawSrm:
+		return WithdrawSrmAccounts.Amm, true
+	case SwapBaseIn, SwapBaseOut:
+		return SwapAccounts18.Amm, true
+	case SimulateInfo:
+		return SimulateInfoAccounts.Amm, true
+	case AdminCancelOrders:
+		return AdminCancelOrdersAccounts.Amm, true
+	default:
+		return 0, false
+	}
+}
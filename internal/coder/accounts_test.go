@@ -0,0 +1,56 @@
+package coder
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Withdraw accounts in the order the AMM program reads them, the temp LP accounts are only
+// there in the 22 and 23 account forms and the referrer only in the 23 account one
+func withdrawProgramOrder(accounts int) []string {
+	order := []string{"TokenProgram", "Amm", "AmmAuthority", "AmmOpenOrders", "AmmTargetOrders", "LpMint", "PoolCoinVault", "PoolPcVault"}
+	if accounts > 20 {
+		order = append(order, "PoolPcTempLp", "PoolCoinTempLp")
+	}
+	order = append(order, "MarketProgram", "Market", "MarketCoinVault", "MarketPcVault", "MarketVaultSigner", "UserLpToken", "UserCoinToken", "UserPcToken", "UserWallet", "MarketEventQueue", "MarketBids", "MarketAsks")
+	if accounts == 23 {
+		order = append(order, "ReferrerPcWallet")
+	}
+	return order
+}
+
+func TestWithdrawAccountsOf(t *testing.T) {
+	for _, accounts := range []int{20, 22, 23} {
+		layout, ok := WithdrawAccountsOf(accounts)
+		if !ok {
+			t.Fatalf("no layout for %d accounts", accounts)
+		}
+
+		positions := make(map[string]int)
+		for i, name := range withdrawProgramOrder(accounts) {
+			positions[name] = i
+		}
+		if len(positions) != accounts {
+			t.Fatalf("program order has %d accounts, want %d", len(positions), accounts)
+		}
+
+		value := reflect.ValueOf(layout)
+		for i := 0; i < value.NumField(); i++ {
+			name := value.Type().Field(i).Name
+
+			want, ok := positions[name]
+			if !ok {
+				want = -1
+			}
+			if got := int(value.Field(i).Int()); got != want {
+				t.Errorf("%d accounts: %s at %d, want %d", accounts, name, got, want)
+			}
+		}
+	}
+
+	for _, accounts := range []int{17, 18, 21, 24} {
+		if _, ok := WithdrawAccountsOf(accounts); ok {
+			t.Errorf("layout for %d accounts, the program rejects it", accounts)
+		}
+	}
+}
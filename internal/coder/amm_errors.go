@@ -0,0 +1,133 @@
+package coder
+
+import "fmt"
+
+// AmmError is a custom program error of the Raydium AMM v4 program, in the order of its error enum
+type AmmError uint32
+
+const (
+	AMM_ERROR_ALREADY_IN_USE AmmError = iota
+	AMM_ERROR_INVALID_PROGRAM_ADDRESS
+	AMM_ERROR_EXPECTED_MINT
+	AMM_ERROR_EXPECTED_ACCOUNT
+	AMM_ERROR_INVALID_COIN_VAULT
+	AMM_ERROR_INVALID_PC_VAULT
+	AMM_ERROR_INVALID_TOKEN_LP
+	AMM_ERROR_INVALID_DEST_TOKEN_COIN
+	AMM_ERROR_INVALID_DEST_TOKEN_PC
+	AMM_ERROR_INVALID_POOL_MINT
+	AMM_ERROR_INVALID_OPEN_ORDERS
+	AMM_ERROR_INVALID_SERUM_MARKET
+	AMM_ERROR_INVALID_SERUM_PROGRAM
+	AMM_ERROR_INVALID_TARGET_ORDERS
+	AMM_ERROR_INVALID_WITHDRAW_QUEUE
+	AMM_ERROR_INVALID_TEMP_LP
+	AMM_ERROR_INVALID_COIN_MINT
+	AMM_ERROR_INVALID_PC_MINT
+	AMM_ERROR_INVALID_OWNER
+	AMM_ERROR_INVALID_SUPPLY
+	AMM_ERROR_INVALID_DELEGATE
+	AMM_ERROR_INVALID_SIGN_ACCOUNT
+	AMM_ERROR_INVALID_STATUS
+	AMM_ERROR_INVALID_INSTRUCTION
+	AMM_ERROR_WRONG_ACCOUNTS_NUMBER
+	AMM_ERROR_WITHDRAW_TRANSFER_BUSY
+	AMM_ERROR_WITHDRAW_QUEUE_FULL
+	AMM_ERROR_WITHDRAW_QUEUE_EMPTY
+	AMM_ERROR_INVALID_PARAMS_SET
+	AMM_ERROR_INVALID_INPUT
+	AMM_ERROR_EXCEEDED_SLIPPAGE
+	AMM_ERROR_CALCULATION_EX_RATE_FAILURE
+	AMM_ERROR_CHECKED_SUB_OVERFLOW
+	AMM_ERROR_CHECKED_ADD_OVERFLOW
+	AMM_ERROR_CHECKED_MUL_OVERFLOW
+	AMM_ERROR_CHECKED_DIV_OVERFLOW
+	AMM_ERROR_CHECKED_EMPTY_FUNDS
+	AMM_ERROR_CALC_PNL_ERROR
+	AMM_ERROR_INVALID_SPL_TOKEN_PROGRAM
+	AMM_ERROR_TAKE_PNL_ERROR
+	AMM_ERROR_INSUFFICIENT_FUNDS
+	AMM_ERROR_CONVERSION_FAILURE
+	AMM_ERROR_INVALID_USER_TOKEN
+	AMM_ERROR_INVALID_SRM_MINT
+	AMM_ERROR_INVALID_SRM_TOKEN
+	AMM_ERROR_TOO_MANY_OPEN_ORDERS
+	AMM_ERROR_ORDER_AT_SLOT_IS_PLACED
+	AMM_ERROR_INVALID_SYS_PROGRAM_ADDRESS
+	AMM_ERROR_INVALID_FEE
+	AMM_ERROR_REPEAT_CREATE_AMM
+	AMM_ERROR_NOT_ALLOW_ZERO_LP
+	AMM_ERROR_INVALID_CLOSE_AUTHORITY
+	AMM_ERROR_INVALID_FREEZE_AUTHORITY
+	AMM_ERROR_INVALID_REFER_PC_MINT
+	AMM_ERROR_INVALID_CONFIG_ACCOUNT
+	AMM_ERROR_REPEAT_CREATE_CONFIG_ACCOUNT
+	AMM_ERROR_UNKNOWN_AMM_ERROR
+)
+
+var ammErrorNames = [...]string{
+	"AlreadyInUse",
+	"InvalidProgramAddress",
+	"ExpectedMint",
+	"ExpectedAccount",
+	"InvalidCoinVault",
+	"InvalidPCVault",
+	"InvalidTokenLP",
+	"InvalidDestTokenCoin",
+	"InvalidDestTokenPC",
+	"InvalidPoolMint",
+	"InvalidOpenOrders",
+	"InvalidSerumMarket",
+	"InvalidSerumProgram",
+	"InvalidTargetOrders",
+	"InvalidWithdrawQueue",
+	"InvalidTempLp",
+	"InvalidCoinMint",
+	"InvalidPCMint",
+	"InvalidOwner",
+	"InvalidSupply",
+	"InvalidDelegate",
+	"InvalidSignAccount",
+	"InvalidStatus",
+	"InvalidInstruction",
+	"WrongAccountsNumber",
+	"WithdrawTransferBusy",
+	"WithdrawQueueFull",
+	"WithdrawQueueEmpty",
+	"InvalidParamsSet",
+	"InvalidInput",
+	"ExceededSlippage",
+	"CalculationExRateFailure",
+	"CheckedSubOverflow",
+	"CheckedAddOverflow",
+	"CheckedMulOverflow",
+	"CheckedDivOverflow",
+	"CheckedEmptyFunds",
+	"CalcPnlError",
+	"InvalidSplTokenProgram",
+	"TakePnlError",
+	"InsufficientFunds",
+	"ConversionFailure",
+	"InvalidUserToken",
+	"InvalidSrmMint",
+	"InvalidSrmToken",
+	"TooManyOpenOrders",
+	"OrderAtSlotIsPlaced",
+	"InvalidSysProgramAddress",
+	"InvalidFee",
+	"RepeatCreateAmm",
+	"NotAllowZeroLP",
+	"InvalidCloseAuthority",
+	"InvalidFreezeAuthority",
+	"InvalidReferPCMint",
+	"InvalidConfigAccount",
+	"RepeatCreateConfigAccount",
+	"UnknownAmmError",
+}
+
+func (e AmmError) Error() string {
+	if int(e) < len(ammErrorNames) {
+		return fmt.Sprintf("raydium amm error %d: %s", uint32(e), ammErrorNames[e])
+	}
+	return fmt.Sprintf("raydium amm error %d", uint32(e))
+}
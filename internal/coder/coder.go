@@ -1,5 +1,69 @@
 package coder
 
+import "github.com/gagliardetto/solana-go"
+
+// Raydium AMM v4 instruction discriminators
+const (
+	INSTRUCTION_INITIALIZE            = 0
+	INSTRUCTION_INITIALIZE2           = 1
+	INSTRUCTION_MONITOR_STEP          = 2
+	INSTRUCTION_DEPOSIT               = 3
+	INSTRUCTION_WITHDRAW              = 4
+	INSTRUCTION_MIGRATE_TO_OPENBOOK   = 5
+	INSTRUCTION_SET_PARAMS            = 6
+	INSTRUCTION_WITHDRAW_PNL          = 7
+	INSTRUCTION_WITHDRAW_SRM          = 8
+	INSTRUCTION_SWAP_BASE_IN          = 9
+	INSTRUCTION_PRE_INITIALIZE        = 10
+	INSTRUCTION_SWAP_BASE_OUT         = 11
+	INSTRUCTION_SIMULATE_INFO         = 12
+	INSTRUCTION_ADMIN_CANCEL_ORDERS   = 13
+	INSTRUCTION_CREATE_CONFIG_ACCOUNT = 14
+	INSTRUCTION_UPDATE_CONFIG_ACCOUNT = 15
+)
+
+// SetParams param values
+const (
+	PARAM_STATUS               = 0
+	PARAM_STATE                = 1
+	PARAM_ORDER_NUM            = 2
+	PARAM_DEPTH                = 3
+	PARAM_AMOUNT_WAVE          = 4
+	PARAM_MIN_PRICE_MULTIPLIER = 5
+	PARAM_MAX_PRICE_MULTIPLIER = 6
+	PARAM_MIN_SIZE             = 7
+	PARAM_VOL_MAX_CUT_RATIO    = 8
+	PARAM_FEES                 = 9
+	PARAM_AMM_OWNER            = 10
+	PARAM_SET_OPEN_TIME        = 11
+	PARAM_LAST_ORDER_DISTANCE  = 12
+	PARAM_INIT_ORDER_DEPTH     = 13
+	PARAM_SET_SWITCH_TIME      = 14
+	PARAM_CLEAR_OPEN_TIME      = 15
+	PARAM_SEPERATE             = 16
+	PARAM_UPDATE_OPEN_ORDER    = 17
+)
+
+// SimulateInfo param values
+const (
+	SIMULATE_POOL_INFO          = 0
+	SIMULATE_SWAP_BASE_IN_INFO  = 1
+	SIMULATE_SWAP_BASE_OUT_INFO = 2
+	SIMULATE_RUN_CRANK_INFO     = 3
+)
+
+// UpdateConfigAccount param values
+const (
+	CONFIG_PARAM_OWNER           = 0
+	CONFIG_PARAM_PNL_OWNER       = 1
+	CONFIG_PARAM_CREATE_POOL_FEE = 2
+)
+
+type Initialize struct {
+	Nonce    byte
+	OpenTime uint64
+}
+
 type Initialize2 struct {
 	Nonce          byte
 	OpenTime       uint64
@@ -7,7 +71,54 @@ type Initialize2 struct {
 	InitCoinAmount uint64
 }
 
+type MonitorStep struct {
+	PlanOrderLimit   uint16
+	PlaceOrderLimit  uint16
+	CancelOrderLimit uint16
+}
+
+type Deposit struct {
+	MaxCoinAmount  uint64
+	MaxPcAmount    uint64
+	BaseSide       uint64
+	OtherAmountMin *uint64
+}
+
 type Withdraw struct {
+	Amount        uint64
+	MinCoinAmount *uint64
+	MinPcAmount   *uint64
+}
+
+type MigrateToOpenBook struct{}
+
+type Fees struct {
+	MinSeparateNumerator   uint64
+	MinSeparateDenominator uint64
+	TradeFeeNumerator      uint64
+	TradeFeeDenominator    uint64
+	PnlNumerator           uint64
+	PnlDenominator         uint64
+	SwapFeeNumerator       uint64
+	SwapFeeDenominator     uint64
+}
+
+type LastOrderDistance struct {
+	LastOrderNumerator   uint64
+	LastOrderDenominator uint64
+}
+
+type SetParams struct {
+	Param             byte
+	Value             *uint64
+	NewPubkey         *solana.PublicKey
+	Fees              *Fees
+	LastOrderDistance *LastOrderDistance
+}
+
+type WithdrawPnl struct{}
+
+type WithdrawSrm struct {
 	Amount uint64
 }
 
@@ -16,7 +127,29 @@ type SwapBaseIn struct {
 	MinimumAmountOut uint64
 }
 
+type PreInitialize struct {
+	Nonce byte
+}
+
 type SwapBaseOut struct {
 	MaxAmountIn uint64
 	AmountOut   uint64
 }
+
+type SimulateInfo struct {
+	Param       byte
+	SwapBaseIn  *SwapBaseIn
+	SwapBaseOut *SwapBaseOut
+}
+
+type AdminCancelOrders struct {
+	Limit uint16
+}
+
+type CreateConfigAccount struct{}
+
+type UpdateConfigAccount struct {
+	Param         byte
+	Owner         *solana.PublicKey
+	CreatePoolFee *uint64
+}
@@ -0,0 +1,65 @@
+package coder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrShortBuffer          = errors.New("short buffer")
+	ErrTrailingBytes        = errors.New("trailing bytes")
+	ErrUnknownDiscriminator = errors.New("unknown discriminator")
+	ErrWrongOwner           = errors.New("wrong account owner")
+)
+
+// readExact reads fields in order and requires them to consume the rest of buf
+func readExact(buf *bytes.Reader, fields ...interface{}) error {
+	size, err := fieldsSize(fields)
+	if err != nil {
+		return err
+	}
+
+	if buf.Len() > size {
+		return fmt.Errorf("%w: expected %d bytes, got %d", ErrTrailingBytes, size, buf.Len())
+	}
+
+	return readFields(buf, size, fields)
+}
+
+// readPrefix reads fields in order and leaves any remaining bytes in buf
+func readPrefix(buf *bytes.Reader, fields ...interface{}) error {
+	size, err := fieldsSize(fields)
+	if err != nil {
+		return err
+	}
+
+	return readFields(buf, size, fields)
+}
+
+func readFields(buf *bytes.Reader, size int, fields []interface{}) error {
+	if buf.Len() < size {
+		return fmt.Errorf("%w: expected %d bytes, got %d", ErrShortBuffer, size, buf.Len())
+	}
+
+	for _, field := range fields {
+		if err := binary.Read(buf, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func fieldsSize(fields []interface{}) (int, error) {
+	size := 0
+	for _, field := range fields {
+		n := binary.Size(field)
+		if n < 0 {
+			return 0, fmt.Errorf("unsupported field type %T", field)
+		}
+		size += n
+	}
+	return size, nil
+}
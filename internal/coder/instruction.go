@@ -3,7 +3,9 @@ package coder
 import (
 	"bytes"
 	"encoding/binary"
-	"errors"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
 )
 
 // RaydiumAmmInstructionCoder implements the Coder interface.
@@ -18,55 +20,273 @@ func (coder *RaydiumAmmInstructionCoder) Decode(data []byte) (interface{}, error
 	return decodeData(data)
 }
 
+// Encode encodes a swap instruction into its instruction data.
+func (coder *RaydiumAmmInstructionCoder) Encode(instruction interface{}) ([]byte, error) {
+	return encodeData(instruction)
+}
+
+// Encoding function. Only the swap instructions are built by the bot.
+func encodeData(instruction interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	switch ix := instruction.(type) {
+	case SwapBaseIn:
+		buf.WriteByte(INSTRUCTION_SWAP_BASE_IN)
+		binary.Write(buf, binary.LittleEndian, ix.AmountIn)
+		binary.Write(buf, binary.LittleEndian, ix.MinimumAmountOut)
+	case SwapBaseOut:
+		buf.WriteByte(INSTRUCTION_SWAP_BASE_OUT)
+		binary.Write(buf, binary.LittleEndian, ix.MaxAmountIn)
+		binary.Write(buf, binary.LittleEndian, ix.AmountOut)
+	default:
+		return nil, fmt.Errorf("cannot encode instruction %T", instruction)
+	}
+
+	return buf.Bytes(), nil
+}
+
 // Decoding function.
 func decodeData(data []byte) (interface{}, error) {
 	buf := bytes.NewReader(data)
 	var instructionID byte
-	binary.Read(buf, binary.LittleEndian, &instructionID)
+	if err := readPrefix(buf, &instructionID); err != nil {
+		return nil, err
+	}
 
 	switch instructionID {
-	case 1:
+	case INSTRUCTION_INITIALIZE:
+		return decodeInitialize(buf)
+	case INSTRUCTION_INITIALIZE2:
 		return decodeInitialize2(buf)
-	case 4:
+	case INSTRUCTION_MONITOR_STEP:
+		return decodeMonitorStep(buf)
+	case INSTRUCTION_DEPOSIT:
+		return decodeDeposit(buf)
+	case INSTRUCTION_WITHDRAW:
 		return decodeWithdraw(buf)
-	case 9:
+	case INSTRUCTION_MIGRATE_TO_OPENBOOK:
+		return MigrateToOpenBook{}, readExact(buf)
+	case INSTRUCTION_SET_PARAMS:
+		return decodeSetParams(buf)
+	case INSTRUCTION_WITHDRAW_PNL:
+		return WithdrawPnl{}, readExact(buf)
+	case INSTRUCTION_WITHDRAW_SRM:
+		return decodeWithdrawSrm(buf)
+	case INSTRUCTION_SWAP_BASE_IN:
 		return decodeSwapBaseIn(buf)
-	case 11:
+	case INSTRUCTION_PRE_INITIALIZE:
+		return decodePreInitialize(buf)
+	case INSTRUCTION_SWAP_BASE_OUT:
 		return decodeSwapBaseOut(buf)
+	case INSTRUCTION_SIMULATE_INFO:
+		return decodeSimulateInfo(buf)
+	case INSTRUCTION_ADMIN_CANCEL_ORDERS:
+		return decodeAdminCancelOrders(buf)
+	case INSTRUCTION_CREATE_CONFIG_ACCOUNT:
+		return CreateConfigAccount{}, readExact(buf)
+	case INSTRUCTION_UPDATE_CONFIG_ACCOUNT:
+		return decodeUpdateConfigAccount(buf)
 	default:
-		return nil, errors.New("invalid instruction ID")
+		return nil, fmt.Errorf("%w: instruction %d", ErrUnknownDiscriminator, instructionID)
 	}
 }
 
+func decodeInitialize(buf *bytes.Reader) (Initialize, error) {
+	var instruction Initialize
+	if err := readExact(buf, &instruction.Nonce, &instruction.OpenTime); err != nil {
+		return Initialize{}, err
+	}
+
+	return instruction, nil
+}
+
 func decodeInitialize2(buf *bytes.Reader) (Initialize2, error) {
 	var instruction Initialize2
-	binary.Read(buf, binary.LittleEndian, &instruction.Nonce)
-	binary.Read(buf, binary.LittleEndian, &instruction.OpenTime)
-	binary.Read(buf, binary.LittleEndian, &instruction.InitPcAmount)
-	binary.Read(buf, binary.LittleEndian, &instruction.InitCoinAmount)
+	if err := readExact(buf, &instruction.Nonce, &instruction.OpenTime, &instruction.InitPcAmount, &instruction.InitCoinAmount); err != nil {
+		return Initialize2{}, err
+	}
+
+	return instruction, nil
+}
+
+func decodeMonitorStep(buf *bytes.Reader) (MonitorStep, error) {
+	var instruction MonitorStep
+	if err := readExact(buf, &instruction.PlanOrderLimit, &instruction.PlaceOrderLimit, &instruction.CancelOrderLimit); err != nil {
+		return MonitorStep{}, err
+	}
+
+	return instruction, nil
+}
+
+func decodeDeposit(buf *bytes.Reader) (Deposit, error) {
+	var instruction Deposit
+	if err := readPrefix(buf, &instruction.MaxCoinAmount, &instruction.MaxPcAmount, &instruction.BaseSide); err != nil {
+		return Deposit{}, err
+	}
+
+	// Newer clients append the minimum amount of the other side
+	if buf.Len() > 0 {
+		var otherAmountMin uint64
+		if err := readExact(buf, &otherAmountMin); err != nil {
+			return Deposit{}, err
+		}
+		instruction.OtherAmountMin = &otherAmountMin
+	}
 
 	return instruction, nil
 }
 
 func decodeWithdraw(buf *bytes.Reader) (Withdraw, error) {
 	var instruction Withdraw
-	binary.Read(buf, binary.LittleEndian, &instruction.Amount)
+	if err := readPrefix(buf, &instruction.Amount); err != nil {
+		return Withdraw{}, err
+	}
+
+	// Newer clients append the minimum amounts to receive
+	if buf.Len() > 0 {
+		var minCoinAmount, minPcAmount uint64
+		if err := readExact(buf, &minCoinAmount, &minPcAmount); err != nil {
+			return Withdraw{}, err
+		}
+		instruction.MinCoinAmount = &minCoinAmount
+		instruction.MinPcAmount = &minPcAmount
+	}
+
+	return instruction, nil
+}
+
+func decodeSetParams(buf *bytes.Reader) (SetParams, error) {
+	var instruction SetParams
+	if err := readPrefix(buf, &instruction.Param); err != nil {
+		return SetParams{}, err
+	}
+
+	var err error
+	switch instruction.Param {
+	case PARAM_AMM_OWNER:
+		var pubkey solana.PublicKey
+		err = readExact(buf, &pubkey)
+		instruction.NewPubkey = &pubkey
+	case PARAM_FEES:
+		var fees Fees
+		err = readExact(buf, &fees)
+		instruction.Fees = &fees
+	case PARAM_LAST_ORDER_DISTANCE:
+		var distance LastOrderDistance
+		err = readExact(buf, &distance)
+		instruction.LastOrderDistance = &distance
+	default:
+		// Params such as UpdateOpenOrder carry no value, the change is in the accounts
+		if buf.Len() > 0 {
+			var value uint64
+			err = readExact(buf, &value)
+			instruction.Value = &value
+		}
+	}
+
+	if err != nil {
+		return SetParams{}, err
+	}
+
+	return instruction, nil
+}
+
+func decodeWithdrawSrm(buf *bytes.Reader) (WithdrawSrm, error) {
+	var instruction WithdrawSrm
+	if err := readExact(buf, &instruction.Amount); err != nil {
+		return WithdrawSrm{}, err
+	}
 
 	return instruction, nil
 }
 
 func decodeSwapBaseIn(buf *bytes.Reader) (SwapBaseIn, error) {
 	var instruction SwapBaseIn
-	binary.Read(buf, binary.LittleEndian, &instruction.AmountIn)
-	binary.Read(buf, binary.LittleEndian, &instruction.MinimumAmountOut)
+	if err := readExact(buf, &instruction.AmountIn, &instruction.MinimumAmountOut); err != nil {
+		return SwapBaseIn{}, err
+	}
+
+	return instruction, nil
+}
+
+func decodePreInitialize(buf *bytes.Reader) (PreInitialize, error) {
+	var instruction PreInitialize
+	if err := readExact(buf, &instruction.Nonce); err != nil {
+		return PreInitialize{}, err
+	}
 
 	return instruction, nil
 }
 
 func decodeSwapBaseOut(buf *bytes.Reader) (SwapBaseOut, error) {
 	var instruction SwapBaseOut
-	binary.Read(buf, binary.LittleEndian, &instruction.MaxAmountIn)
-	binary.Read(buf, binary.LittleEndian, &instruction.AmountOut)
+	if err := readExact(buf, &instruction.MaxAmountIn, &instruction.AmountOut); err != nil {
+		return SwapBaseOut{}, err
+	}
+
+	return instruction, nil
+}
+
+func decodeSimulateInfo(buf *bytes.Reader) (SimulateInfo, error) {
+	var instruction SimulateInfo
+	if err := readPrefix(buf, &instruction.Param); err != nil {
+		return SimulateInfo{}, err
+	}
+
+	switch instruction.Param {
+	case SIMULATE_SWAP_BASE_IN_INFO:
+		swap, err := decodeSwapBaseIn(buf)
+		if err != nil {
+			return SimulateInfo{}, err
+		}
+		instruction.SwapBaseIn = &swap
+	case SIMULATE_SWAP_BASE_OUT_INFO:
+		swap, err := decodeSwapBaseOut(buf)
+		if err != nil {
+			return SimulateInfo{}, err
+		}
+		instruction.SwapBaseOut = &swap
+	default:
+		if err := readExact(buf); err != nil {
+			return SimulateInfo{}, err
+		}
+	}
+
+	return instruction, nil
+}
+
+func decodeAdminCancelOrders(buf *bytes.Reader) (AdminCancelOrders, error) {
+	var instruction AdminCancelOrders
+	if err := readExact(buf, &instruction.Limit); err != nil {
+		return AdminCancelOrders{}, err
+	}
+
+	return instruction, nil
+}
+
+func decodeUpdateConfigAccount(buf *bytes.Reader) (UpdateConfigAccount, error) {
+	var instruction UpdateConfigAccount
+	if err := readPrefix(buf, &instruction.Param); err != nil {
+		return UpdateConfigAccount{}, err
+	}
+
+	var err error
+	switch instruction.Param {
+	case CONFIG_PARAM_OWNER, CONFIG_PARAM_PNL_OWNER:
+		var owner solana.PublicKey
+		err = readExact(buf, &owner)
+		instruction.Owner = &owner
+	case CONFIG_PARAM_CREATE_POOL_FEE:
+		var fee uint64
+		err = readExact(buf, &fee)
+		instruction.CreatePoolFee = &fee
+	default:
+		err = fmt.Errorf("%w: config param %d", ErrUnknownDiscriminator, instruction.Param)
+	}
+
+	if err != nil {
+		return UpdateConfigAccount{}, err
+	}
 
 	return instruction, nil
 }
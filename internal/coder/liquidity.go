@@ -2,11 +2,22 @@ package coder
 
 import (
 	"bytes"
-	"encoding/binary"
 
 	"github.com/gagliardetto/solana-go"
 )
 
+// AMM status values of LiquidityState.Status
+const (
+	AMM_STATUS_UNINITIALIZED  = 0
+	AMM_STATUS_INITIALIZED    = 1
+	AMM_STATUS_DISABLED       = 2
+	AMM_STATUS_WITHDRAW_ONLY  = 3
+	AMM_STATUS_LIQUIDITY_ONLY = 4
+	AMM_STATUS_ORDERBOOK_ONLY = 5
+	AMM_STATUS_SWAP_ONLY      = 6
+	AMM_STATUS_WAITING_TRADE  = 7
+)
+
 type LiquidityState struct {
 	Status                 uint64
 	Nonce                  uint64
@@ -62,6 +73,16 @@ type LiquidityState struct {
 	Padding                [3]uint64
 }
 
+// Swappable reports whether the pool's status allows swaps, now or once it opens
+func (s *LiquidityState) Swappable() bool {
+	switch s.Status {
+	case AMM_STATUS_INITIALIZED, AMM_STATUS_SWAP_ONLY, AMM_STATUS_WAITING_TRADE:
+		return true
+	default:
+		return false
+	}
+}
+
 type RaydiumLiquidityCoder struct{}
 
 func NewRaydiumLiquidityCoder() *RaydiumLiquidityCoder {
@@ -77,7 +98,9 @@ func decodeRaydiumLiqudityData(data []byte) (LiquidityState, error) {
 	buf := bytes.NewReader(data)
 	var state LiquidityState
 
-	binary.Read(buf, binary.LittleEndian, &state)
+	if err := readExact(buf, &state); err != nil {
+		return LiquidityState{}, err
+	}
 
 	return state, nil
 }
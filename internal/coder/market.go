@@ -2,7 +2,7 @@ package coder
 
 import (
 	"bytes"
-	"encoding/binary"
+	"fmt"
 
 	"github.com/gagliardetto/solana-go"
 )
@@ -47,7 +47,14 @@ func decodeRaydiumMarketData(data []byte) (MarketStateLayoutV3, error) {
 	buf := bytes.NewReader(data)
 	var state MarketStateLayoutV3
 
-	binary.Read(buf, binary.LittleEndian, &state)
+	if err := readExact(buf, &state); err != nil {
+		return MarketStateLayoutV3{}, err
+	}
+
+	// Serum/OpenBook accounts start with the "serum" magic
+	if string(state.Unused1[:]) != "serum" {
+		return MarketStateLayoutV3{}, fmt.Errorf("%w: market account header %q", ErrUnknownDiscriminator, state.Unused1[:])
+	}
 
 	return state, nil
 }
@@ -0,0 +1,126 @@
+package coder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"time"
+)
+
+// SPL Token instruction discriminators the bot reads, shared by Token-2022
+const (
+	TOKEN_INSTRUCTION_TRANSFER         = 3
+	TOKEN_INSTRUCTION_BURN             = 8
+	TOKEN_INSTRUCTION_TRANSFER_CHECKED = 12
+	TOKEN_INSTRUCTION_BURN_CHECKED     = 15
+)
+
+type TokenBurnAccountLayout struct {
+	Account int
+	Mint    int
+	Owner   int
+}
+
+type TokenTransferAccountLayout struct {
+	Source      int
+	Destination int
+	Owner       int
+}
+
+type TokenTransferCheckedAccountLayout struct {
+	Source      int
+	Mint        int
+	Destination int
+	Owner       int
+}
+
+var (
+	TokenBurnAccounts            = TokenBurnAccountLayout{Account: 0, Mint: 1, Owner: 2}
+	TokenTransferAccounts        = TokenTransferAccountLayout{Source: 0, Destination: 1, Owner: 2}
+	TokenTransferCheckedAccounts = TokenTransferCheckedAccountLayout{Source: 0, Mint: 1, Destination: 2, Owner: 3}
+)
+
+// TokenInstruction is a burn or a transfer, Checked tells apart the variants carrying the mint
+type TokenInstruction struct {
+	Instruction uint8
+	Amount      uint64
+	Checked     bool
+}
+
+func (ix TokenInstruction) IsBurn() bool {
+	return ix.Instruction == TOKEN_INSTRUCTION_BURN || ix.Instruction == TOKEN_INSTRUCTION_BURN_CHECKED
+}
+
+func (ix TokenInstruction) IsTransfer() bool {
+	return ix.Instruction == TOKEN_INSTRUCTION_TRANSFER || ix.Instruction == TOKEN_INSTRUCTION_TRANSFER_CHECKED
+}
+
+// DecodeTokenInstruction decodes the burn and transfer instructions, every other one returns
+// ErrUnknownDiscriminator
+func DecodeTokenInstruction(data []byte) (TokenInstruction, error) {
+	buf := bytes.NewReader(data)
+
+	var ix TokenInstruction
+	if err := readPrefix(buf, &ix.Instruction); err != nil {
+		return ix, err
+	}
+
+	switch ix.Instruction {
+	case TOKEN_INSTRUCTION_TRANSFER, TOKEN_INSTRUCTION_BURN:
+		return ix, readExact(buf, &ix.Amount)
+	case TOKEN_INSTRUCTION_TRANSFER_CHECKED, TOKEN_INSTRUCTION_BURN_CHECKED:
+		var decimals uint8
+		ix.Checked = true
+		return ix, readExact(buf, &ix.Amount, &decimals)
+	default:
+		return ix, ErrUnknownDiscriminator
+	}
+}
+
+// Streamflow prefixes its instructions with the Anchor discriminator, the first 8 bytes of
+// sha256("global:<name>"). Both create instructions start with the same schedule parameters.
+var STREAMFLOW_CREATE_DISCRIMINATORS = [][]byte{anchorDiscriminator("create"), anchorDiscriminator("create_unchecked")}
+
+func anchorDiscriminator(name string) []byte {
+	sum := sha256.Sum256([]byte("global:" + name))
+	return sum[:8]
+}
+
+// DecodeStreamflowUnlockTime reads when a Streamflow create instruction releases the last of
+// its tokens. The parameters follow the 8 byte discriminator: start time, net amount deposited,
+// period, amount per period, cliff and cliff amount. A lock is a stream releasing everything
+// at its cliff.
+func DecodeStreamflowUnlockTime(data []byte) (int64, bool) {
+	if len(data) < 56 {
+		return 0, false
+	}
+
+	create := false
+	for _, discriminator := range STREAMFLOW_CREATE_DISCRIMINATORS {
+		create = create || bytes.Equal(data[:8], discriminator)
+	}
+	if !create {
+		return 0, false
+	}
+
+	field := func(i int) uint64 {
+		return binary.LittleEndian.Uint64(data[8+i*8:])
+	}
+	start, deposited, period, perPeriod, cliff, cliffAmount := field(0), field(1), field(2), field(3), field(4), field(5)
+
+	unlock := max(start, cliff)
+	if deposited > cliffAmount {
+		if period == 0 || perPeriod == 0 {
+			return 0, false
+		}
+		periods := (deposited - cliffAmount + perPeriod - 1) / perPeriod
+		unlock += periods * period
+	}
+
+	// Anything past this is not a unix time
+	if unlock == 0 || unlock > uint64(time.Now().AddDate(1000, 0, 0).Unix()) {
+		return 0, false
+	}
+
+	return int64(unlock), true
+}
@@ -1,9 +1,13 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
-	"math/rand"
 	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
@@ -19,21 +23,19 @@ var (
 	RAYDIUM_AUTHORITY           = solana.MustPublicKeyFromBase58("5Q544fKrFoe6tsEbD7S8EmxGTJYAKtTVhAW5Q5pge4j1")
 	BLOXROUTE_MEMO              = solana.MustPublicKeyFromBase58("HQ2UUt18uJqKaQFJhgV9zaTdQxUZjNrsKFgoEDquBkcx")
 	BLOXROUTE_TIP               = solana.MustPublicKeyFromBase58("HWEoBxYs7ssKuudEjzjmpfJVX7Dvi7wescFsVx2L5yoY")
+	STREAMFLOW_ID               = solana.MustPublicKeyFromBase58("strmRqUCoQUgGUan5YhzUZa6KqdzwX5L6FpUxfmKg5m")
+	RAYDIUM_LOCKER_ID           = solana.MustPublicKeyFromBase58("LockrWmn6K5twhz3y9w1dQERbmgSaRkfnTeTKbpofwE")
 	LAMPORTS_PER_SOL            = 1000000000
 	TA_RENT_LAMPORTS            = 2039280
 	TA_SIZE                     = 165
-	BUY_METHOD                  = "bloxroute"
 	BLOCKENGINE_URL             = "https://amsterdam.mainnet.block-engine.jito.wtf"
-	GRPC1                       = types.GrpcConfig{
-		Addr:               "lineage-ams.rpcpool.com",
-		Token:              "390cc92f-d182-4400-a829-9524d8a9e23a",
-		InsecureConnection: false,
-	}
-	GRPC2 = types.GrpcConfig{
-		Addr:               "2.57.214.64:4001",
-		Token:              "",
-		InsecureConnection: true,
-	}
+)
+
+// TRADE_MODE decides what happens to entries: nothing, recorded as paper trades or sent
+const (
+	TRADE_MODE_OFF   = "off"
+	TRADE_MODE_PAPER = "paper"
+	TRADE_MODE_LIVE  = "live"
 )
 
 var (
@@ -41,12 +43,59 @@ var (
 	GrpcAddr           string
 	GrpcToken          string
 	InsecureConnection bool
+	GrpcSourcesFile    string
+	WithdrawRulesFile  string
+	GrpcSources        []types.GrpcConfig
+	ArbTipLamports     uint64
+	ArbPriorityFee     uint64
+	ArbMinProfit       uint64
+	ArbMaxInput        uint64
 	RedisAddr          string
 	RedisPassword      string
 	RpcHttpUrl         string
 	RpcWsUrl           string
 	MySqlDsn           string
 	MySqlDbName        string
+
+	SubmitRelays             []string
+	SkipSimulationStrategies []string
+
+	TradeMode                    string
+	PayerPrivateKey              solana.PrivateKey
+	EntryAmountLamports          uint64
+	EntryMaxPoolBps              uint64
+	EntryMaxPoolExposureLamports uint64
+	EntrySlippageBps             uint64
+	EntryComputeUnitLimit        uint64
+	EntryComputeUnitPrice        uint64
+	EntryTipLamports             uint64
+
+	PaperLandingDelaySlots uint64
+
+	ExitTakeProfitBps   uint64
+	ExitStopLossBps     uint64
+	ExitTrailingStopBps uint64
+	ExitMaxHoldSeconds  uint64
+	ExitChunks          uint64
+	ExitSlippageBps     uint64
+
+	TrackerTtlTriggerOnlySeconds uint64
+	TrackerTtlBothSeconds        uint64
+	TrackerTtlPauseSeconds       uint64
+	TrackerTtlNotTrackedSeconds  uint64
+	TrackerReevaluateSeconds     uint64
+	TrackerDeadReserveLamports   uint64
+
+	LpLockers                  []solana.PublicKey
+	LpStatusMinBps             uint64
+	LpMintFilterLimit          uint64
+	LpMintFilterRefreshSeconds uint64
+
+	BloxRouteWsUrl                  string
+	BloxRouteHttpUrl                string
+	BloxRouteAuth                   string
+	BloxRouteFrontRunningProtection bool
+	BloxRouteTipLamports            uint64
 )
 
 func InitEnv() error {
@@ -64,22 +113,190 @@ func InitEnv() error {
 	MySqlDsn = os.Getenv("MYSQL_DSN")
 	MySqlDbName = os.Getenv("MYSQL_DBNAME")
 
+	BloxRouteWsUrl = os.Getenv("BLOXROUTE_WS_URL")
+	if BloxRouteWsUrl == "" {
+		BloxRouteWsUrl = "wss://ny.solana.dex.blxrbdn.com/ws"
+	}
+	BloxRouteHttpUrl = os.Getenv("BLOXROUTE_HTTP_URL")
+	if BloxRouteHttpUrl == "" {
+		BloxRouteHttpUrl = "https://ny.solana.dex.blxrbdn.com"
+	}
+	BloxRouteAuth = os.Getenv("BLOXROUTE_AUTH_HEADER")
+	BloxRouteFrontRunningProtection = os.Getenv("BLOXROUTE_FRONT_RUNNING_PROTECTION") == "true"
+	// bloXroute only forwards transactions tipping at least 0.001 SOL
+	BloxRouteTipLamports = getEnvUint64("BLOXROUTE_TIP_LAMPORTS", 1000000)
+
+	SubmitRelays = getEnvList("SUBMIT_RELAYS", []string{"bloxroute"})
+	// Strategies sending without the simulation gate, for when its latency costs more than a failed send
+	SkipSimulationStrategies = getEnvList("SKIP_SIMULATION_STRATEGIES", nil)
+
+	TradeMode = os.Getenv("TRADE_MODE")
+	switch TradeMode {
+	case "":
+		TradeMode = TRADE_MODE_OFF
+	case TRADE_MODE_OFF, TRADE_MODE_PAPER, TRADE_MODE_LIVE:
+	default:
+		return fmt.Errorf("unknown TRADE_MODE %q", TradeMode)
+	}
+
+	if key := os.Getenv("PAYER_PRIVATE_KEY"); key != "" {
+		payer, err := solana.PrivateKeyFromBase58(key)
+		if err != nil {
+			return fmt.Errorf("invalid PAYER_PRIVATE_KEY: %w", err)
+		}
+		PayerPrivateKey = payer
+	}
+
+	EntryAmountLamports = getEnvUint64("ENTRY_AMOUNT_LAMPORTS", uint64(LAMPORTS_PER_SOL/10))
+	EntryMaxPoolBps = getEnvUint64("ENTRY_MAX_POOL_BPS", 100)
+	// Lamports spent in one pool across entries, by default a pool is entered once
+	EntryMaxPoolExposureLamports = getEnvUint64("ENTRY_MAX_POOL_EXPOSURE_LAMPORTS", EntryAmountLamports)
+	EntrySlippageBps = getEnvUint64("ENTRY_SLIPPAGE_BPS", 1000)
+	EntryComputeUnitLimit = getEnvUint64("ENTRY_COMPUTE_UNIT_LIMIT", 80000)
+	EntryComputeUnitPrice = getEnvUint64("ENTRY_COMPUTE_UNIT_PRICE", 100000)
+	EntryTipLamports = getEnvUint64("ENTRY_TIP_LAMPORTS", 100000)
+	// Slots between a trigger and the paper entry landing, paper trades are not marked before
+	PaperLandingDelaySlots = getEnvUint64("PAPER_LANDING_DELAY_SLOTS", 1)
+
+	// Exit thresholds are in bps of the entry cost, 0 disables the exit
+	ExitTakeProfitBps = getEnvUint64("EXIT_TAKE_PROFIT_BPS", 5000)
+	ExitStopLossBps = getEnvUint64("EXIT_STOP_LOSS_BPS", 2500)
+	ExitTrailingStopBps = getEnvUint64("EXIT_TRAILING_STOP_BPS", 0)
+	ExitMaxHoldSeconds = getEnvUint64("EXIT_MAX_HOLD_SECONDS", 300)
+	ExitChunks = max(getEnvUint64("EXIT_CHUNKS", 1), 1)
+	ExitSlippageBps = getEnvUint64("EXIT_SLIPPAGE_BPS", 1500)
+
+	// How long a tracker keeps its status without a new transition, 0 never expires it
+	TrackerTtlTriggerOnlySeconds = getEnvUint64("TRACKER_TTL_TRIGGER_ONLY_SECONDS", 24*60*60)
+	TrackerTtlBothSeconds = getEnvUint64("TRACKER_TTL_BOTH_SECONDS", 24*60*60)
+	TrackerTtlPauseSeconds = getEnvUint64("TRACKER_TTL_PAUSE_SECONDS", 6*60*60)
+	TrackerTtlNotTrackedSeconds = getEnvUint64("TRACKER_TTL_NOT_TRACKED_SECONDS", 7*24*60*60)
+	TrackerReevaluateSeconds = getEnvUint64("TRACKER_REEVALUATE_SECONDS", 60)
+	// A tracked pool below the dead reserve is dropped
+	TrackerDeadReserveLamports = getEnvUint64("TRACKER_DEAD_RESERVE_LAMPORTS", uint64(LAMPORTS_PER_SOL/100))
+
+	// Programs LP sent to is locked. Locks are only detected in transactions that stream in: a
+	// source's accountInclude must list these programs, or the locker must pass the LP mint, as
+	// TransferChecked does, for the LP mint filter to catch it. Listing a program here does not
+	// subscribe to it.
+	for _, locker := range getEnvList("LP_LOCKER_PROGRAMS", []string{STREAMFLOW_ID.String(), RAYDIUM_LOCKER_ID.String()}) {
+		programId, err := solana.PublicKeyFromBase58(locker)
+		if err != nil {
+			return fmt.Errorf("invalid LP_LOCKER_PROGRAMS entry %q: %w", locker, err)
+		}
+		LpLockers = append(LpLockers, programId)
+	}
+	// A burn or lock moving less of the LP supply leaves the pool's LP status as is
+	LpStatusMinBps = getEnvUint64("LP_STATUS_MIN_BPS", 5000)
+	// Every source also streams the transactions of the most recently seen pools' LP mints, so
+	// burns outside the AMM's transactions are seen
+	LpMintFilterLimit = getEnvUint64("LP_MINT_FILTER_LIMIT", 5000)
+	LpMintFilterRefreshSeconds = max(getEnvUint64("LP_MINT_FILTER_REFRESH_SECONDS", 30), 1)
+
+	if blockEngineUrl := os.Getenv("BLOCKENGINE_URL"); blockEngineUrl != "" {
+		BLOCKENGINE_URL = blockEngineUrl
+	}
+
+	ArbTipLamports = getEnvUint64("ARB_TIP_LAMPORTS", 100000)
+	ArbPriorityFee = getEnvUint64("ARB_PRIORITY_FEE_LAMPORTS", 10000)
+	ArbMinProfit = getEnvUint64("ARB_MIN_PROFIT_LAMPORTS", 100000)
+	ArbMaxInput = getEnvUint64("ARB_MAX_INPUT_LAMPORTS", uint64(10*LAMPORTS_PER_SOL))
+
+	WithdrawRulesFile = os.Getenv("WITHDRAW_RULES_FILE")
+	if WithdrawRulesFile == "" {
+		WithdrawRulesFile = "withdraw_rules.json"
+	}
+
+	GrpcSourcesFile = os.Getenv("GRPC_SOURCES_FILE")
+	if GrpcSourcesFile == "" {
+		GrpcSourcesFile = "sources.json"
+	}
+
+	sources, err := loadSources()
+	if err != nil {
+		return err
+	}
+	GrpcSources = sources
+
 	return nil
 }
 
-func GetJitoTipAddress() solana.PublicKey {
+// Comma separated list, empty entries are dropped
+func getEnvList(key string, fallback []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+func getEnvUint64(key string, fallback uint64) uint64 {
+	value, err := strconv.ParseUint(os.Getenv(key), 10, 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// Sources come from GRPC_SOURCES_FILE when it exists, otherwise from the single
+// GRPC_ENDPOINT/GRPC_TOKEN pair in the environment
+func loadSources() ([]types.GrpcConfig, error) {
+	if _, err := os.Stat(GrpcSourcesFile); err == nil {
+		return LoadGrpcSources(GrpcSourcesFile)
+	}
 
-	var mainnetTipAccounts = []solana.PublicKey{
-		solana.MustPublicKeyFromBase58("96gYZGLnJYVFmbjzopPSU6QiEV5fGqZNyN9nmNhvrZU5"),
-		solana.MustPublicKeyFromBase58("HFqU5x63VTqvQss8hp11i4wVV8bD44PvwucfZ2bU7gRe"),
-		solana.MustPublicKeyFromBase58("Cw8CFyM9FkoMi7K7Crf6HNQqf4uEMzpKw6QNghXLvLkY"),
-		solana.MustPublicKeyFromBase58("ADaUMid9yfUytqMBgopwjb2DTLSokTSzL1zt6iGPaS49"),
-		solana.MustPublicKeyFromBase58("DfXygSm4jCyNCybVYYK6DwvWqjKee8pbDmJGcLWNDXjh"),
-		solana.MustPublicKeyFromBase58("ADuUkR4vqLUMWXxW9gh6D6L8pMSawimctcNZ5pGwDcEt"),
-		solana.MustPublicKeyFromBase58("DttWaMuVvTiduZRnguLF7jNxTgiMBZ1hyAumKUiL2KRL"),
-		solana.MustPublicKeyFromBase58("3AVi9Tg9Uo68tJfuvoKvqKNWKkC5wPdSSdeBnizKZ6jT"),
+	if GrpcAddr == "" {
+		return nil, errors.New("no Geyser sources configured: set GRPC_SOURCES_FILE or GRPC_ENDPOINT")
 	}
 
-	randomIndex := rand.Intn(len(mainnetTipAccounts))
-	return mainnetTipAccounts[randomIndex]
+	source := types.GrpcConfig{
+		Name:  "default",
+		Addr:  GrpcAddr,
+		Token: GrpcToken,
+	}
+	if InsecureConnection {
+		source.TLS = TLS_MODE_INSECURE
+	}
+
+	if err := normalizeGrpcSource(&source); err != nil {
+		return nil, err
+	}
+
+	return []types.GrpcConfig{source}, nil
+}
+
+var JITO_TIP_ACCOUNTS = []solana.PublicKey{
+	solana.MustPublicKeyFromBase58("96gYZGLnJYVFmbjzopPSU6QiEV5fGqZNyN9nmNhvrZU5"),
+	solana.MustPublicKeyFromBase58("HFqU5x63VTqvQss8hp11i4wVV8bD44PvwucfZ2bU7gRe"),
+	solana.MustPublicKeyFromBase58("Cw8CFyM9FkoMi7K7Crf6HNQqf4uEMzpKw6QNghXLvLkY"),
+	solana.MustPublicKeyFromBase58("ADaUMid9yfUytqMBgopwjb2DTLSokTSzL1zt6iGPaS49"),
+	solana.MustPublicKeyFromBase58("DfXygSm4jCyNCybVYYK6DwvWqjKee8pbDmJGcLWNDXjh"),
+	solana.MustPublicKeyFromBase58("ADuUkR4vqLUMWXxW9gh6D6L8pMSawimctcNZ5pGwDcEt"),
+	solana.MustPublicKeyFromBase58("DttWaMuVvTiduZRnguLF7jNxTgiMBZ1hyAumKUiL2KRL"),
+	solana.MustPublicKeyFromBase58("3AVi9Tg9Uo68tJfuvoKvqKNWKkC5wPdSSdeBnizKZ6jT"),
+}
+
+var jitoTipIndex atomic.Uint32
+
+// Rotate through the tip accounts so consecutive bundles do not contend on the same account
+func GetJitoTipAddress() solana.PublicKey {
+	index := jitoTipIndex.Add(1) - 1
+	return JITO_TIP_ACCOUNTS[int(index)%len(JITO_TIP_ACCOUNTS)]
+}
+
+func IsJitoTipAddress(addr solana.PublicKey) bool {
+	for _, account := range JITO_TIP_ACCOUNTS {
+		if account == addr {
+			return true
+		}
+	}
+	return false
 }
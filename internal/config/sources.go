@@ -0,0 +1,91 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
+)
+
+const (
+	TLS_MODE_TLS      = "tls"
+	TLS_MODE_INSECURE = "insecure"
+)
+
+type grpcSourcesFile struct {
+	Sources []types.GrpcConfig `json:"sources"`
+}
+
+// LoadGrpcSources reads the Geyser source list from a JSON file. Address and token
+// values are expanded against the environment, so "${TRITON_TOKEN}" keeps secrets
+// out of the file itself.
+func LoadGrpcSources(path string) ([]types.GrpcConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file grpcSourcesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	if len(file.Sources) == 0 {
+		return nil, fmt.Errorf("no sources defined in %s", path)
+	}
+
+	names := make(map[string]bool)
+	for i := range file.Sources {
+		source := &file.Sources[i]
+
+		source.Addr = os.ExpandEnv(source.Addr)
+		source.Token = os.ExpandEnv(source.Token)
+
+		if err := normalizeGrpcSource(source); err != nil {
+			return nil, fmt.Errorf("source %d (%s): %w", i, source.Name, err)
+		}
+
+		if names[source.Name] {
+			return nil, fmt.Errorf("duplicate source name %s", source.Name)
+		}
+		names[source.Name] = true
+	}
+
+	return file.Sources, nil
+}
+
+func normalizeGrpcSource(source *types.GrpcConfig) error {
+	if source.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if source.Addr == "" {
+		return errors.New("address is required")
+	}
+
+	switch source.TLS {
+	case "", TLS_MODE_TLS:
+		source.TLS = TLS_MODE_TLS
+		source.InsecureConnection = false
+	case TLS_MODE_INSECURE:
+		source.InsecureConnection = true
+	default:
+		return fmt.Errorf("invalid tls mode %q", source.TLS)
+	}
+
+	switch source.Commitment {
+	case "":
+		source.Commitment = "processed"
+	case "processed", "confirmed", "finalized":
+	default:
+		return fmt.Errorf("invalid commitment %q", source.Commitment)
+	}
+
+	if len(source.Filters.AccountInclude) == 0 {
+		source.Filters.AccountInclude = []string{RAYDIUM_AMM_V4.String()}
+	}
+
+	return nil
+}
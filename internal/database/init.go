@@ -3,10 +3,9 @@ package db
 import (
 	"database/sql"
 	"fmt"
-	"log"
-	"os"
 
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/iqbalbaharum/lp-remove-tracker/migrations"
 )
 
 type Database struct {
@@ -36,8 +35,21 @@ func NewDatabase(client *sql.DB, dbName string) (*Database, error) {
 	}, nil
 }
 
+// CreateDatabaseAndTables creates the database and applies the pending migrations
 func (d *Database) CreateDatabaseAndTables() error {
+	if err := d.CreateDatabase(); err != nil {
+		return err
+	}
 
+	migrator, err := d.Migrator()
+	if err != nil {
+		return err
+	}
+
+	return migrator.Up()
+}
+
+func (d *Database) CreateDatabase() error {
 	createDatabase := `CREATE DATABASE IF NOT EXISTS ` + d.dbName
 
 	_, err := d.MysqlClient.Exec(createDatabase)
@@ -54,27 +66,10 @@ func (d *Database) CreateDatabaseAndTables() error {
 		return fmt.Errorf(fmt.Sprintf("Failed to use db %s: %v", d.dbName, err))
 	}
 
-	path := "./migrations/"
-
-	entries, err := os.ReadDir(path)
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	for _, e := range entries {
-		c, err := os.ReadFile(fmt.Sprintf(path + e.Name()))
-
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		_, err = d.MysqlClient.Exec(string(c))
-
-		if err != nil {
-			log.Fatal(err)
-		}
-	}
-
 	return nil
 }
+
+// Migrator runs the migrations embedded in the binary
+func (d *Database) Migrator() (*Migrator, error) {
+	return NewMigrator(d.MysqlClient, d.dbName, migrations.FS)
+}
@@ -0,0 +1,375 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MIGRATION_TABLE = "schema_migrations"
+	// Named lock held while migrating so two processes never apply the same version
+	MIGRATION_LOCK         = "schema_migrations"
+	MIGRATION_LOCK_TIMEOUT = 30
+)
+
+var (
+	ErrChecksumMismatch  = errors.New("applied migration was edited")
+	ErrMissingMigration  = errors.New("applied migration is missing")
+	ErrMissingDown       = errors.New("migration has no down file")
+	ErrUnknownVersion    = errors.New("unknown migration version")
+	ErrMigrationLocked   = errors.New("another process is migrating")
+	ErrInvalidMigrations = errors.New("invalid migration files")
+)
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version  uint64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type appliedMigration struct {
+	version   uint64
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// LoadMigrations reads the <version>_<name>.<up|down>.sql files of fsys in version order. The
+// checksum covers the up file, which is what was applied.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[uint64]*Migration)
+
+	for _, e := range entries {
+		match := migrationFile.FindStringSubmatch(e.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		c, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("%w: version %d is both %s and %s", ErrInvalidMigrations, version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			sum := sha256.Sum256(c)
+			migration.Up = string(c)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(c)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("%w: version %d has no up file", ErrInvalidMigrations, migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrator applies migrations over a single connection, so USE and the named lock hold for
+// every statement it runs.
+//
+// Each version runs in a transaction together with its schema_migrations row. MySQL commits
+// DDL implicitly, so a migration with DDL should hold one statement: when it fails nothing was
+// applied and nothing recorded.
+type Migrator struct {
+	client     *sql.DB
+	dbName     string
+	migrations []Migration
+}
+
+func NewMigrator(client *sql.DB, dbName string, fsys fs.FS) (*Migrator, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		client:     client,
+		dbName:     dbName,
+		migrations: migrations,
+	}, nil
+}
+
+// Up applies every pending migration
+func (m *Migrator) Up() error {
+	if len(m.migrations) == 0 {
+		return nil
+	}
+
+	return m.To(m.migrations[len(m.migrations)-1].Version)
+}
+
+// Down reverts the last steps applied migrations
+func (m *Migrator) Down(steps int) error {
+	return m.run(func(ctx context.Context, conn *sql.Conn, applied map[uint64]appliedMigration) error {
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			if _, ok := applied[m.migrations[i].Version]; !ok {
+				continue
+			}
+			if err := m.revert(ctx, conn, m.migrations[i]); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// To migrates up or down until version is the last applied migration, 0 reverts everything
+func (m *Migrator) To(version uint64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	return m.run(func(ctx context.Context, conn *sql.Conn, applied map[uint64]appliedMigration) error {
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; ok && migration.Version > version {
+				if err := m.revert(ctx, conn, migration); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; !ok && migration.Version <= version {
+				if err := m.apply(ctx, conn, migration); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Status lists every known migration and whether it is applied
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+
+	err := m.run(func(ctx context.Context, conn *sql.Conn, applied map[uint64]appliedMigration) error {
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Migration: migration}
+			if a, ok := applied[migration.Version]; ok {
+				status.Applied = true
+				status.AppliedAt = a.appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+
+	return statuses, err
+}
+
+// Take a connection and the lock, then check what was applied against the files before fn
+func (m *Migrator) run(fn func(ctx context.Context, conn *sql.Conn, applied map[uint64]appliedMigration) error) error {
+	ctx := context.Background()
+
+	conn, err := m.client.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.dbName != "" {
+		if _, err := conn.ExecContext(ctx, "USE "+m.dbName); err != nil {
+			return fmt.Errorf("failed to use db %s: %w", m.dbName, err)
+		}
+	}
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", MIGRATION_LOCK, MIGRATION_LOCK_TIMEOUT).Scan(&locked); err != nil {
+		return err
+	}
+	if locked.Int64 != 1 {
+		return ErrMigrationLocked
+	}
+	defer conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", MIGRATION_LOCK)
+
+	createTable := `
+			CREATE TABLE IF NOT EXISTS ` + MIGRATION_TABLE + ` (
+				version BIGINT UNSIGNED PRIMARY KEY,
+				name VARCHAR(255),
+				checksum CHAR(64),
+				applied_at INT
+			)
+		`
+	if _, err := conn.ExecContext(ctx, createTable); err != nil {
+		return fmt.Errorf("failed to create %s: %w", MIGRATION_TABLE, err)
+	}
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	for version, a := range applied {
+		migration := m.find(version)
+		if migration == nil {
+			return fmt.Errorf("%w: %d_%s", ErrMissingMigration, version, a.name)
+		}
+		if migration.Checksum != a.checksum {
+			return fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, version, migration.Name)
+		}
+	}
+
+	return fn(ctx, conn, applied)
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[uint64]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM "+MIGRATION_TABLE)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[uint64]appliedMigration)
+	for rows.Next() {
+		var a appliedMigration
+		var appliedAt int64
+
+		if err := rows.Scan(&a.version, &a.name, &a.checksum, &appliedAt); err != nil {
+			return nil, err
+		}
+		a.appliedAt = time.Unix(appliedAt, 0)
+		applied[a.version] = a
+	}
+
+	return applied, rows.Err()
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	err := m.exec(ctx, conn, migration.Up,
+		"INSERT INTO "+MIGRATION_TABLE+" (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)",
+		migration.Version, migration.Name, migration.Checksum, time.Now().Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to apply %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+	return nil
+}
+
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	if migration.Down == "" {
+		return fmt.Errorf("%w: %d_%s", ErrMissingDown, migration.Version, migration.Name)
+	}
+
+	err := m.exec(ctx, conn, migration.Down,
+		"DELETE FROM "+MIGRATION_TABLE+" WHERE version = ?",
+		migration.Version,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revert %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	log.Printf("Reverted migration %d_%s", migration.Version, migration.Name)
+	return nil
+}
+
+// Run the file's statements and the bookkeeping query in one transaction
+func (m *Migrator) exec(ctx context.Context, conn *sql.Conn, file string, query string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range splitStatements(file) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *Migrator) find(version uint64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// splitStatements splits a file on the semicolons ending a line, as the driver runs one
+// statement per Exec. Comment lines are dropped.
+func splitStatements(file string) []string {
+	var statements []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(file, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
@@ -0,0 +1,211 @@
+package dedup
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Deduplicator drops repeated signatures coming from several Geyser sources and
+// records which source won each race and by how much the others trailed. Races
+// are settled once the signature leaves the window, as workers may observe the
+// deliveries out of order, and deliveries are counted with their race. Lag
+// samples roll across flushes, the counters start over on each flush.
+type Deduplicator struct {
+	mutex      sync.Mutex
+	window     time.Duration
+	sampleSize int
+	seen       map[string]*arrival
+	sources    map[string]*sourceStats
+	total      uint64
+}
+
+type arrival struct {
+	first    string
+	firstAt  time.Time
+	sources  []string
+	received []time.Time
+}
+
+type sourceStats struct {
+	delivered uint64
+	wins      uint64
+	exclusive uint64
+	lags      []int64
+	next      int
+}
+
+type SourceStats struct {
+	Source    string
+	Delivered uint64
+	Wins      uint64
+	WinRate   float64
+	Exclusive uint64
+	LagP50    time.Duration
+	LagP99    time.Duration
+	Samples   int
+}
+
+// NewDeduplicator remembers each signature for window and keeps up to sampleSize
+// lag samples per source for the percentiles.
+func NewDeduplicator(window time.Duration, sampleSize int) *Deduplicator {
+	d := &Deduplicator{
+		window:     window,
+		sampleSize: sampleSize,
+		seen:       make(map[string]*arrival),
+		sources:    make(map[string]*sourceStats),
+	}
+
+	go d.sweep()
+
+	return d
+}
+
+// Observe records that source delivered signature at receivedAt. It returns true
+// only for the first delivery observed, which is the one to process. The winner
+// is the earliest receivedAt, not the first observed.
+func (d *Deduplicator) Observe(signature string, source string, receivedAt time.Time) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	entry, exists := d.seen[signature]
+	if !exists {
+		d.seen[signature] = &arrival{
+			first:    source,
+			firstAt:  receivedAt,
+			sources:  []string{source},
+			received: []time.Time{receivedAt},
+		}
+		return true
+	}
+
+	for _, s := range entry.sources {
+		if s == source {
+			return false
+		}
+	}
+
+	entry.sources = append(entry.sources, source)
+	entry.received = append(entry.received, receivedAt)
+
+	if receivedAt.Before(entry.firstAt) {
+		entry.first = source
+		entry.firstAt = receivedAt
+	}
+
+	return false
+}
+
+// Flush returns the races settled since the previous flush and starts a new
+// interval. The lag percentiles cover the last sampleSize lags of each source.
+func (d *Deduplicator) Flush() []SourceStats {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var result []SourceStats
+	for name, stats := range d.sources {
+		s := SourceStats{
+			Source:    name,
+			Delivered: stats.delivered,
+			Wins:      stats.wins,
+			Exclusive: stats.exclusive,
+			Samples:   len(stats.lags),
+		}
+
+		if d.total > 0 {
+			s.WinRate = float64(stats.wins) / float64(d.total)
+		}
+
+		if len(stats.lags) > 0 {
+			lags := make([]int64, len(stats.lags))
+			copy(lags, stats.lags)
+			sort.Slice(lags, func(i, j int) bool { return lags[i] < lags[j] })
+
+			s.LagP50 = time.Duration(percentile(lags, 0.50)) * time.Microsecond
+			s.LagP99 = time.Duration(percentile(lags, 0.99)) * time.Microsecond
+		}
+
+		result = append(result, s)
+		stats.delivered, stats.wins, stats.exclusive = 0, 0, 0
+	}
+
+	d.total = 0
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Source < result[j].Source })
+
+	return result
+}
+
+func (d *Deduplicator) source(name string) *sourceStats {
+	stats, exists := d.sources[name]
+	if !exists {
+		stats = &sourceStats{}
+		d.sources[name] = stats
+	}
+	return stats
+}
+
+// sweep settles the races of signatures older than the window and forgets them.
+// A signature that only one source ever delivered is counted as exclusive to
+// that source.
+func (d *Deduplicator) sweep() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		d.expire(now)
+	}
+}
+
+func (d *Deduplicator) expire(now time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for signature, entry := range d.seen {
+		if now.Sub(entry.firstAt) < d.window {
+			continue
+		}
+
+		d.settle(entry)
+		delete(d.seen, signature)
+	}
+}
+
+func (d *Deduplicator) settle(entry *arrival) {
+	d.total++
+	d.source(entry.first).wins++
+	for _, source := range entry.sources {
+		d.source(source).delivered++
+	}
+
+	if len(entry.sources) == 1 {
+		d.source(entry.first).exclusive++
+		return
+	}
+
+	for i, source := range entry.sources {
+		if source == entry.first {
+			continue
+		}
+		d.source(source).addLag(entry.received[i].Sub(entry.firstAt).Microseconds(), d.sampleSize)
+	}
+}
+
+func (s *sourceStats) addLag(lag int64, sampleSize int) {
+	if len(s.lags) < sampleSize {
+		s.lags = append(s.lags, lag)
+		return
+	}
+
+	s.lags[s.next] = lag
+	s.next = (s.next + 1) % sampleSize
+}
+
+func percentile(sorted []int64, p float64) int64 {
+	index := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	return sorted[index]
+}
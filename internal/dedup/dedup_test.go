@@ -0,0 +1,54 @@
+package dedup
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEarliestReceivedAtWins(t *testing.T) {
+	d := &Deduplicator{
+		window:     time.Minute,
+		sampleSize: 10,
+		seen:       make(map[string]*arrival),
+		sources:    make(map[string]*sourceStats),
+	}
+
+	start := time.Unix(1_700_000_000, 0)
+
+	// Workers observe b first although a received it earlier
+	if !d.Observe("sig1", "b", start.Add(5*time.Millisecond)) {
+		t.Fatal("first observation was not processed")
+	}
+	if d.Observe("sig1", "a", start) {
+		t.Fatal("repeated signature was processed")
+	}
+	if d.Observe("sig1", "b", start) {
+		t.Fatal("repeated source was processed")
+	}
+	d.Observe("sig2", "b", start)
+
+	if stats := d.Flush(); len(stats) != 0 {
+		t.Fatalf("unsettled races were reported: %+v", stats)
+	}
+
+	d.expire(start.Add(time.Minute))
+
+	stats := d.Flush()
+	if len(stats) != 2 {
+		t.Fatalf("got %d sources, want 2", len(stats))
+	}
+
+	a, b := stats[0], stats[1]
+	if a.Source != "a" || a.Wins != 1 || a.Delivered != 1 || a.WinRate != 0.5 || a.Samples != 0 {
+		t.Errorf("a: %+v", a)
+	}
+	if b.Source != "b" || b.Wins != 1 || b.Delivered != 2 || b.Exclusive != 1 || b.LagP50 != 5*time.Millisecond {
+		t.Errorf("b: %+v", b)
+	}
+
+	// Lags roll over to the next interval, the counters do not
+	stats = d.Flush()
+	if b := stats[1]; b.Wins != 0 || b.Delivered != 0 || b.Samples != 1 || b.LagP99 != 5*time.Millisecond {
+		t.Errorf("b after flush: %+v", b)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/gagliardetto/solana-go"
@@ -39,6 +41,11 @@ var (
 	transactionsAccountsExclude utils.ArrayFlags
 )
 
+var (
+	reconnectMinBackoff = 500 * time.Millisecond
+	reconnectMaxBackoff = 30 * time.Second
+)
+
 var kacp = keepalive.ClientParameters{
 	Time:                10 * time.Minute, // send pings every 10 seconds if there is no activity
 	Timeout:             20 * time.Second, // wait 1 second for ping ack before considering the connection dead
@@ -51,13 +58,14 @@ type MempoolTxn struct {
 	AccountKeys          []string               `json:"accountKeys"`
 	RecentBlockhash      string                 `json:"recentBlockhash"`
 	Instructions         []TxInstruction        `json:"instructions"`
-	InnerInstructions    []*pb.InnerInstruction `json:"innerInstructions"`
+	InnerInstructions    []TxInnerInstructions  `json:"innerInstructions"`
 	AddressTableLookups  []TxAddressTableLookup `json:"addressTableLookups"`
 	PreTokenBalances     []types.TxTokenBalance `json:"preTokenBalances"`
 	PostTokenBalances    []types.TxTokenBalance `json:"postTokenBalances"`
 	ComputeUnitsConsumed uint64                 `json:"computeUnitsConsumed"`
 	Slot                 uint64                 `json:"slot"`
 	Error                string                 `json:"error"`
+	ReceivedAt           time.Time              `json:"receivedAt"`
 }
 
 type TxInstruction struct {
@@ -66,6 +74,12 @@ type TxInstruction struct {
 	Data           []byte  `json:"data"`
 }
 
+// Instructions invoked through CPI by the top-level instruction at Index
+type TxInnerInstructions struct {
+	Index        uint32          `json:"index"`
+	Instructions []TxInstruction `json:"instructions"`
+}
+
 type TxAddressTableLookup struct {
 	AccountKey      string  `json:"accountKey"`
 	WritableIndexes []uint8 `json:"writableIndexes"`
@@ -79,6 +93,28 @@ type GeyserResponse struct {
 type GrpcClient struct {
 	conn   *grpc.ClientConn
 	client pb.GeyserClient
+	mutex  sync.RWMutex
+	state  SourceState
+	// Named transaction filters added to the source's, and the stream to resend them on
+	filters map[string][]string
+	base    *pb.SubscribeRequest
+	stream  pb.Geyser_SubscribeClient
+}
+
+type ConnectionState string
+
+const (
+	STATE_CONNECTING   ConnectionState = "CONNECTING"
+	STATE_CONNECTED    ConnectionState = "CONNECTED"
+	STATE_RECONNECTING ConnectionState = "RECONNECTING"
+)
+
+type SourceState struct {
+	Source     string
+	State      ConnectionState
+	Reconnects uint64
+	LastError  string
+	Since      time.Time
 }
 
 func GrpcConnect(address string, plaintext bool) (*GrpcClient, error) {
@@ -104,7 +140,7 @@ func GrpcConnect(address string, plaintext bool) (*GrpcClient, error) {
 	}
 
 	client := pb.NewGeyserClient(conn)
-	return &GrpcClient{conn, client}, nil
+	return &GrpcClient{conn: conn, client: client}, nil
 }
 
 func (g *GrpcClient) CloseConnection() error {
@@ -115,11 +151,31 @@ func (g *GrpcClient) CloseConnection() error {
 }
 
 func (g *GrpcClient) GrpcSubscribeByAddresses(sourceName string, grpcToken string, accountInclude []string, accountExclude []string, txChannel chan<- GeyserResponse) error {
+	return g.GrpcSubscribe(types.GrpcConfig{
+		Name:       sourceName,
+		Token:      grpcToken,
+		Commitment: "processed",
+		Filters: types.GrpcFilter{
+			AccountInclude: accountInclude,
+			AccountExclude: accountExclude,
+		},
+	}, txChannel)
+}
+
+// GrpcSubscribe subscribes to the transaction stream described by source and
+// keeps it alive until the process exits
+func (g *GrpcClient) GrpcSubscribe(source types.GrpcConfig, txChannel chan<- GeyserResponse) error {
 	if g.client == nil {
 		return errors.New("GRPC not connected")
 	}
 
-	defer close(txChannel)
+	sourceName := source.Name
+	grpcToken := source.Token
+
+	commitment, err := commitmentLevel(source.Commitment)
+	if err != nil {
+		return err
+	}
 
 	var subscription pb.SubscribeRequest = pb.SubscribeRequest{
 		Slots:        make(map[string]*pb.SubscribeRequestFilterSlots),
@@ -128,22 +184,17 @@ func (g *GrpcClient) GrpcSubscribeByAddresses(sourceName string, grpcToken strin
 		Accounts:     make(map[string]*pb.SubscribeRequestFilterAccounts),
 		Transactions: make(map[string]*pb.SubscribeRequestFilterTransactions),
 		Entry:        make(map[string]*pb.SubscribeRequestFilterEntry),
-		Commitment:   pb.CommitmentLevel_PROCESSED.Enum(),
+		Commitment:   commitment.Enum(),
 	}
 
-	// subscription.Slots["slots"] = &pb.SubscribeRequestFilterSlots{}
-	subscription.Blocks = make(map[string]*pb.SubscribeRequestFilterBlocks)
-	subscription.BlocksMeta = make(map[string]*pb.SubscribeRequestFilterBlocksMeta)
-	subscription.Accounts = make(map[string]*pb.SubscribeRequestFilterAccounts)
-	subscription.Transactions = make(map[string]*pb.SubscribeRequestFilterTransactions)
-
 	// Subscribe to generic transaction stream
-	if len(accountInclude) > 0 {
-		subscription.Transactions[accountInclude[0]] = &pb.SubscribeRequestFilterTransactions{
-			Vote:           utils.BoolPointer(false),
-			Failed:         utils.BoolPointer(false),
-			AccountInclude: accountInclude,
-			AccountExclude: accountExclude,
+	if len(source.Filters.AccountInclude) > 0 {
+		subscription.Transactions[sourceName] = &pb.SubscribeRequestFilterTransactions{
+			Vote:            utils.BoolPointer(source.Filters.Vote),
+			Failed:          utils.BoolPointer(source.Filters.Failed),
+			AccountInclude:  source.Filters.AccountInclude,
+			AccountExclude:  source.Filters.AccountExclude,
+			AccountRequired: source.Filters.AccountRequired,
 		}
 	}
 
@@ -154,77 +205,212 @@ func (g *GrpcClient) GrpcSubscribeByAddresses(sourceName string, grpcToken strin
 	}
 	log.Printf("Subscription request: %s", string(subscriptionJson))
 
-	// Set up the subscription request
 	ctx := context.Background()
 	if grpcToken != "" {
 		md := metadata.New(map[string]string{"x-token": grpcToken})
 		ctx = metadata.NewOutgoingContext(ctx, md)
 	}
 
+	// Supervise the stream: on any error, resend the same subscription request
+	// after an exponential backoff. txChannel is shared with the other sources
+	// and is never closed here.
+	backoff := reconnectMinBackoff
+	for {
+		g.setState(sourceName, STATE_CONNECTING, nil)
+
+		received, err := g.subscribe(ctx, sourceName, &subscription, txChannel)
+		if received {
+			backoff = reconnectMinBackoff
+		}
+
+		wait := withJitter(backoff)
+		log.Printf("%s | Stream error: %v. Reconnecting in %s", sourceName, err, wait)
+		g.setState(sourceName, STATE_RECONNECTING, err)
+
+		time.Sleep(wait)
+		backoff = min(backoff*2, reconnectMaxBackoff)
+	}
+}
+
+// subscribe opens a single stream and pumps transactions into txChannel until
+// the stream fails. It reports whether any update was received, so the caller
+// can reset its backoff after a healthy connection.
+func (g *GrpcClient) subscribe(parent context.Context, sourceName string, subscription *pb.SubscribeRequest, txChannel chan<- GeyserResponse) (bool, error) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
 	stream, err := g.client.Subscribe(ctx,
 		grpc.MaxCallRecvMsgSize(100<<20),
 	)
-
 	if err != nil {
-		log.Fatalf("%v", err)
-		return err
+		return false, err
 	}
 
-	err = stream.Send(&subscription)
+	g.mutex.Lock()
+	g.base = subscription
+	g.stream = stream
+	err = stream.Send(g.request())
+	g.mutex.Unlock()
+
+	defer func() {
+		g.mutex.Lock()
+		g.stream = nil
+		g.mutex.Unlock()
+	}()
+
 	if err != nil {
-		log.Fatalf("%v", err)
-		return err
+		return false, err
 	}
 
-	for {
-		resp, _ := stream.Recv()
+	g.setState(sourceName, STATE_CONNECTED, nil)
 
+	received := false
+	for {
+		resp, err := stream.Recv()
 		if err == io.EOF {
-			// return
+			return received, errors.New("stream closed by server")
 		}
 
 		if err != nil {
-			log.Printf("Error occurred in receiving update: %v", err)
-			break
+			return received, err
 		}
 
+		received = true
+
 		if resp.GetTransaction() != nil {
+			txChannel <- *newGeyserResponse(sourceName, resp.GetTransaction())
+		}
+	}
+}
+
+// SetAccountFilter adds, or replaces, a named filter streaming the transactions touching any of
+// the accounts. A live stream is resubscribed with it and reconnects keep it, no accounts
+// removes it.
+func (g *GrpcClient) SetAccountFilter(name string, accounts []string) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
 
-			message := resp.GetTransaction().Transaction.Transaction.Message
-			meta := resp.GetTransaction().Transaction.Meta
+	if g.filters == nil {
+		g.filters = make(map[string][]string)
+	}
 
-			var errorString string
+	if len(accounts) == 0 {
+		delete(g.filters, name)
+	} else {
+		g.filters[name] = accounts
+	}
 
-			if meta.Err != nil {
-				if len(meta.Err.Err) > 9 {
-					relevantByte := meta.Err.Err[9]
-					errorString = fmt.Sprintf("0x%x", relevantByte)
-				} else {
-					errorString = "ERR"
-				}
-			}
+	if g.stream == nil {
+		return nil
+	}
 
-			response := &GeyserResponse{
-				MempoolTxns: MempoolTxn{
-					Source:               sourceName,
-					Signature:            base58.Encode(resp.GetTransaction().Transaction.Signature),
-					AccountKeys:          convertAccountKeys(message.AccountKeys),
-					RecentBlockhash:      base58.Encode(message.RecentBlockhash),
-					Instructions:         convertInstructions(message.Instructions),
-					AddressTableLookups:  convertAddressTableLookups(message.AddressTableLookups),
-					PreTokenBalances:     convertTokenBalances(meta.PreTokenBalances),
-					PostTokenBalances:    convertTokenBalances(meta.PostTokenBalances),
-					ComputeUnitsConsumed: *resp.GetTransaction().Transaction.GetMeta().ComputeUnitsConsumed,
-					Slot:                 resp.GetTransaction().Slot,
-					Error:                errorString,
-				},
-			}
+	return g.stream.Send(g.request())
+}
+
+// The source's subscription with the named filters, must be called with the mutex held
+func (g *GrpcClient) request() *pb.SubscribeRequest {
+	transactions := make(map[string]*pb.SubscribeRequestFilterTransactions, len(g.base.Transactions)+len(g.filters))
+	for name, filter := range g.base.Transactions {
+		transactions[name] = filter
+	}
 
-			txChannel <- *response
+	for name, accounts := range g.filters {
+		transactions[name] = &pb.SubscribeRequestFilterTransactions{
+			Vote:           utils.BoolPointer(false),
+			Failed:         utils.BoolPointer(false),
+			AccountInclude: accounts,
 		}
 	}
 
-	return nil
+	return &pb.SubscribeRequest{
+		Slots:        g.base.Slots,
+		Blocks:       g.base.Blocks,
+		BlocksMeta:   g.base.BlocksMeta,
+		Accounts:     g.base.Accounts,
+		Transactions: transactions,
+		Entry:        g.base.Entry,
+		Commitment:   g.base.Commitment,
+	}
+}
+
+func commitmentLevel(commitment string) (pb.CommitmentLevel, error) {
+	switch commitment {
+	case "", "processed":
+		return pb.CommitmentLevel_PROCESSED, nil
+	case "confirmed":
+		return pb.CommitmentLevel_CONFIRMED, nil
+	case "finalized":
+		return pb.CommitmentLevel_FINALIZED, nil
+	default:
+		return 0, fmt.Errorf("invalid commitment %q", commitment)
+	}
+}
+
+func newGeyserResponse(sourceName string, update *pb.SubscribeUpdateTransaction) *GeyserResponse {
+	message := update.Transaction.Transaction.Message
+	meta := update.Transaction.Meta
+
+	var errorString string
+
+	if meta.Err != nil {
+		if len(meta.Err.Err) > 9 {
+			relevantByte := meta.Err.Err[9]
+			errorString = fmt.Sprintf("0x%x", relevantByte)
+		} else {
+			errorString = "ERR"
+		}
+	}
+
+	return &GeyserResponse{
+		MempoolTxns: MempoolTxn{
+			Source:               sourceName,
+			Signature:            base58.Encode(update.Transaction.Signature),
+			AccountKeys:          convertAccountKeys(message.AccountKeys),
+			RecentBlockhash:      base58.Encode(message.RecentBlockhash),
+			Instructions:         convertInstructions(message.Instructions),
+			InnerInstructions:    convertInnerInstructions(meta.InnerInstructions),
+			AddressTableLookups:  convertAddressTableLookups(message.AddressTableLookups),
+			PreTokenBalances:     convertTokenBalances(meta.PreTokenBalances),
+			PostTokenBalances:    convertTokenBalances(meta.PostTokenBalances),
+			ComputeUnitsConsumed: meta.GetComputeUnitsConsumed(),
+			Slot:                 update.Slot,
+			Error:                errorString,
+			ReceivedAt:           time.Now(),
+		},
+	}
+}
+
+func (g *GrpcClient) setState(sourceName string, state ConnectionState, err error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if state == STATE_RECONNECTING {
+		g.state.Reconnects++
+	}
+
+	g.state.Source = sourceName
+	g.state.State = state
+	g.state.Since = time.Now()
+	if err != nil {
+		g.state.LastError = err.Error()
+	}
+}
+
+// State returns the connection state of the source subscribed on this client
+func (g *GrpcClient) State() SourceState {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	return g.state
+}
+
+// withJitter returns a random duration in [d/2, d)
+func withJitter(d time.Duration) time.Duration {
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + time.Duration(rand.Int63n(int64(half)))
 }
 
 func convertAccountKeys(accountKeys [][]byte) []string {
@@ -247,6 +433,26 @@ func convertInstructions(instructions []*pb.CompiledInstruction) []TxInstruction
 	return convertedInstructions
 }
 
+func convertInnerInstructions(innerInstructions []*pb.InnerInstructions) []TxInnerInstructions {
+	convertedInnerInstructions := make([]TxInnerInstructions, len(innerInstructions))
+	for i, inner := range innerInstructions {
+		instructions := make([]TxInstruction, len(inner.Instructions))
+		for j, instr := range inner.Instructions {
+			instructions[j] = TxInstruction{
+				ProgramIdIndex: instr.ProgramIdIndex,
+				Accounts:       instr.Accounts,
+				Data:           instr.Data,
+			}
+		}
+
+		convertedInnerInstructions[i] = TxInnerInstructions{
+			Index:        inner.Index,
+			Instructions: instructions,
+		}
+	}
+	return convertedInnerInstructions
+}
+
 func convertAddressTableLookups(lookups []*pb.MessageAddressTableLookup) []TxAddressTableLookup {
 	convertedLookups := make([]TxAddressTableLookup, len(lookups))
 	for i, lookup := range lookups {
@@ -263,10 +469,11 @@ func convertTokenBalances(tokenBalances []*pb.TokenBalance) []types.TxTokenBalan
 	convertedBalances := make([]types.TxTokenBalance, len(tokenBalances))
 	for i, balance := range tokenBalances {
 		convertedBalances[i] = types.TxTokenBalance{
-			Mint:    balance.Mint,
-			Owner:   balance.Owner,
-			Amount:  balance.UiTokenAmount.Amount,
-			Decimal: balance.UiTokenAmount.Decimals,
+			AccountIndex: balance.AccountIndex,
+			Mint:         balance.Mint,
+			Owner:        balance.Owner,
+			Amount:       balance.UiTokenAmount.Amount,
+			Decimal:      balance.UiTokenAmount.Decimals,
 		}
 	}
 	return convertedBalances
@@ -5,55 +5,60 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
 type WSClient struct {
-	Conn *websocket.Conn
-	url  string
-	auth string
-	done chan struct{}
+	// Guards conn, which both the reader and writers replace on reconnect, and serializes writes
+	mutex  sync.Mutex
+	conn   *websocket.Conn
+	closed bool
+	url    string
+	auth   string
+	// Closed once the reader stops
+	done     chan struct{}
+	doneOnce sync.Once
 }
 
 func NewWSClient(url string, auth string) (*WSClient, error) {
 
-	Conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{
 		"Authorization": {auth},
 	})
-	defer Conn.Close()
-
 	if err != nil {
 		return nil, err
 	}
 
 	client := &WSClient{
-		Conn: Conn,
+		conn: conn,
 		url:  url,
 		auth: auth,
 		done: make(chan struct{}),
 	}
 
-	// go client.listenMessages()
-
 	return client, nil
 }
 
-func (c *WSClient) listenMessages() {
-	defer close(c.done)
-	for {
-		_, message, err := c.Conn.ReadMessage()
-		if err != nil {
-			log.Println("Error reading message:", err)
-			return
-		}
-		log.Printf("Received: %s", message)
-	}
+func (c *WSClient) current() (*websocket.Conn, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.conn, c.closed
 }
 
-func (c *WSClient) reConnect() error {
-	Conn, _, err := websocket.DefaultDialer.Dial(c.url, http.Header{
+// reConnect replaces stale unless the other side already replaced it. The caller holds the mutex.
+func (c *WSClient) reConnect(stale *websocket.Conn) error {
+	if c.closed {
+		return websocket.ErrCloseSent
+	}
+
+	if c.conn != stale {
+		return nil
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(c.url, http.Header{
 		"Authorization": {c.auth},
 	})
 
@@ -61,20 +66,25 @@ func (c *WSClient) reConnect() error {
 		return err
 	}
 
-	c.Conn = Conn
+	stale.Close()
+	c.conn = conn
 
 	return nil
 }
 
 func (c *WSClient) SendMessage(message string) error {
-	err := c.Conn.WriteMessage(websocket.TextMessage, []byte(message))
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	conn := c.conn
+	err := conn.WriteMessage(websocket.TextMessage, []byte(message))
 	if err != nil {
-		if err := c.reConnect(); err != nil {
+		if err := c.reConnect(conn); err != nil {
 			return err
 		}
 
 		// Retry sending the message after reConnecting
-		err = c.Conn.WriteMessage(websocket.TextMessage, []byte(message))
+		err = c.conn.WriteMessage(websocket.TextMessage, []byte(message))
 		if err != nil {
 			return err
 		}
@@ -82,14 +92,29 @@ func (c *WSClient) SendMessage(message string) error {
 	return nil
 }
 
+// ReadMessages reads until the client is closed or a reconnect fails. Only one reader may run.
 func (c *WSClient) ReadMessages(messageChan chan<- []byte) {
+	defer c.doneOnce.Do(func() { close(c.done) })
+
 	for {
-		_, message, err := c.Conn.ReadMessage()
+		conn, closed := c.current()
+		if closed {
+			return
+		}
+
+		_, message, err := conn.ReadMessage()
 		if err != nil {
+			// A writer reconnected and closed the connection under the reader
+			if next, closed := c.current(); !closed && next != conn {
+				continue
+			}
+
 			// If the connection is closed unexpectedly, try to reconnect
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("Error: %v. Attempting to reconnect...", err)
-				err := c.reConnect()
+				c.mutex.Lock()
+				err := c.reConnect(conn)
+				c.mutex.Unlock()
 				if err != nil {
 					log.Printf("Reconnection failed: %v", err)
 					return
@@ -107,16 +132,25 @@ func (c *WSClient) ReadMessages(messageChan chan<- []byte) {
 	}
 }
 
+// Close sends a close frame and waits up to a second for the reader to stop
 func (c *WSClient) Close() error {
-	err := c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-	if err != nil {
-		return err
+	c.mutex.Lock()
+	c.closed = true
+	conn := c.conn
+	err := conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	c.mutex.Unlock()
+
+	if err == nil {
+		select {
+		case <-c.done:
+		case <-time.After(time.Second):
+		}
 	}
-	select {
-	case <-c.done:
-	case <-time.After(time.Second):
+
+	if closeErr := conn.Close(); err == nil {
+		err = closeErr
 	}
-	return c.Conn.Close()
+	return err
 }
 
 // WaitForInterrupt waits for an interrupt signal to gracefully close the WebSocket Connection.
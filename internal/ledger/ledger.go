@@ -0,0 +1,220 @@
+package ledger
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/adapter"
+	bot "github.com/iqbalbaharum/lp-remove-tracker/internal/library"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/liquidity"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/storage"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
+)
+
+// How often the unrealized PnL of open lots is written to the day's row
+const LEDGER_SNAPSHOT_INTERVAL = 1 * time.Minute
+
+// Ledger books the fills of one strategy. Buys open lots at their cost including fees, sells
+// consume them first in first out and realize the proceeds minus the cost of what they sold.
+type Ledger struct {
+	mutex    sync.Mutex
+	strategy string
+	paper    bool
+}
+
+func NewLedger(strategy string, paper bool) *Ledger {
+	return &Ledger{
+		strategy: strategy,
+		paper:    paper,
+	}
+}
+
+// RecordFill stores the fill, its lot changes and the daily rollup in one transaction. The
+// realized PnL of a sell is set on the fill.
+func (l *Ledger) RecordFill(fill *types.Fill) error {
+	db, err := adapter.GetMySQLClient()
+	if err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	fill.Strategy = l.strategy
+	fill.Paper = l.paper
+	if fill.BlockTime == 0 {
+		fill.BlockTime = time.Now().Unix()
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	s := storage.NewLedgerStorageTx(tx)
+
+	buys, sells := 0, 0
+	var lots []types.Lot
+
+	switch fill.Action {
+	case bot.TRADE_BUY:
+		buys = 1
+	case bot.TRADE_SELL:
+		sells = 1
+
+		open, err := s.GetOpenLots(l.strategy, l.paper, *fill.Mint)
+		if err != nil {
+			return err
+		}
+
+		var basis uint64
+		lots, basis, fill.TokenUnmatched = matchLots(open, fill.TokenOut)
+		if fill.TokenUnmatched > 0 {
+			log.Printf("%s | Sold %d tokens more than the open lots hold", fill.AmmId, fill.TokenUnmatched)
+		}
+
+		fill.RealizedPnl = realizedPnl(fill, basis)
+	default:
+		return fmt.Errorf("unknown fill action %q", fill.Action)
+	}
+
+	fill.Id, err = s.SetFill(fill)
+	if err != nil {
+		return err
+	}
+
+	if fill.Action == bot.TRADE_BUY {
+		err = s.SetLot(&types.Lot{
+			FillId:         fill.Id,
+			Strategy:       l.strategy,
+			Paper:          l.paper,
+			AmmId:          fill.AmmId,
+			Mint:           fill.Mint,
+			TokenRemaining: fill.TokenIn,
+			CostRemaining:  fill.SolIn + fill.Costs(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range lots {
+		if err := s.UpdateLot(&lots[i]); err != nil {
+			return err
+		}
+	}
+
+	day := time.Unix(fill.BlockTime, 0).UTC().Format(time.DateOnly)
+	if err := s.AddDailyPnl(l.strategy, l.paper, day, fill.RealizedPnl, fill.Costs(), buys, sells); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Unrealized values every open lot at what selling it into the pool's latest reserves would
+// return, minus the lot's remaining cost. Lots of pools without a cached reserve count at cost.
+func (l *Ledger) Unrealized() (int64, error) {
+	db, err := adapter.GetMySQLClient()
+	if err != nil {
+		return 0, err
+	}
+
+	lots, err := storage.NewLedgerStorage(db).GetAllOpenLots(l.strategy, l.paper)
+	if err != nil {
+		return 0, err
+	}
+
+	var unrealized int64
+	for _, lot := range lots {
+		reserve, ok := liquidity.GetCachedPoolReserve(*lot.AmmId)
+		if !ok {
+			continue
+		}
+
+		value := uint64(0)
+		quote, err := liquidity.ComputeAmountOut(reserve.PoolInfo(), lot.TokenRemaining, reserve.Direction(*lot.Mint))
+		if err == nil {
+			value = quote.AmountOut
+		}
+
+		unrealized += int64(value) - int64(lot.CostRemaining)
+	}
+
+	return unrealized, nil
+}
+
+// Run snapshots the unrealized PnL into today's row
+func (l *Ledger) Run() {
+	for range time.Tick(LEDGER_SNAPSHOT_INTERVAL) {
+		unrealized, err := l.Unrealized()
+		if err != nil {
+			log.Printf("Failed to value open lots of %s: %v", l.strategy, err)
+			continue
+		}
+
+		db, err := adapter.GetMySQLClient()
+		if err != nil {
+			continue
+		}
+
+		day := time.Now().UTC().Format(time.DateOnly)
+		if err := storage.NewLedgerStorage(db).SetDailyUnrealized(l.strategy, l.paper, day, unrealized); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+}
+
+// realizedPnl is the proceeds of the matched tokens minus the fill's costs and the basis of the
+// lots it consumed. Unmatched tokens have no basis, so their share of the proceeds is left out.
+func realizedPnl(fill *types.Fill, basis uint64) int64 {
+	proceeds := fill.SolOut
+	if fill.TokenUnmatched > 0 && fill.TokenOut > 0 {
+		matched := fill.TokenOut - min(fill.TokenUnmatched, fill.TokenOut)
+		proceeds = new(big.Int).Div(
+			new(big.Int).Mul(new(big.Int).SetUint64(fill.SolOut), new(big.Int).SetUint64(matched)),
+			new(big.Int).SetUint64(fill.TokenOut),
+		).Uint64()
+	}
+
+	return int64(proceeds) - int64(fill.Costs()) - int64(basis)
+}
+
+// matchLots takes amount tokens from the lots in order, each at its share of the lot's cost.
+// It returns the changed lots, the cost basis of what was taken and what no lot could cover.
+func matchLots(lots []types.Lot, amount uint64) ([]types.Lot, uint64, uint64) {
+	var changed []types.Lot
+	var basis uint64
+
+	for _, lot := range lots {
+		if amount == 0 {
+			break
+		}
+		if lot.TokenRemaining == 0 {
+			continue
+		}
+
+		take := min(amount, lot.TokenRemaining)
+
+		cost := lot.CostRemaining
+		if take < lot.TokenRemaining {
+			cost = new(big.Int).Div(
+				new(big.Int).Mul(new(big.Int).SetUint64(lot.CostRemaining), new(big.Int).SetUint64(take)),
+				new(big.Int).SetUint64(lot.TokenRemaining),
+			).Uint64()
+		}
+
+		lot.TokenRemaining -= take
+		lot.CostRemaining -= cost
+		basis += cost
+		amount -= take
+
+		changed = append(changed, lot)
+	}
+
+	return changed, basis, amount
+}
@@ -0,0 +1,117 @@
+package ledger
+
+import (
+	"testing"
+
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
+)
+
+func TestMatchLots(t *testing.T) {
+	tests := []struct {
+		name      string
+		lots      []types.Lot
+		amount    uint64
+		changed   []types.Lot
+		basis     uint64
+		unmatched uint64
+	}{
+		{
+			name:    "whole lot",
+			lots:    []types.Lot{{Id: 1, TokenRemaining: 100, CostRemaining: 1000}},
+			amount:  100,
+			changed: []types.Lot{{Id: 1, TokenRemaining: 0, CostRemaining: 0}},
+			basis:   1000,
+		},
+		{
+			name:    "partial lot",
+			lots:    []types.Lot{{Id: 1, TokenRemaining: 100, CostRemaining: 1000}},
+			amount:  40,
+			changed: []types.Lot{{Id: 1, TokenRemaining: 60, CostRemaining: 600}},
+			basis:   400,
+		},
+		{
+			name: "oldest lot first across lots",
+			lots: []types.Lot{
+				{Id: 1, TokenRemaining: 50, CostRemaining: 500},
+				{Id: 2, TokenRemaining: 100, CostRemaining: 3000},
+				{Id: 3, TokenRemaining: 10, CostRemaining: 10},
+			},
+			amount: 75,
+			changed: []types.Lot{
+				{Id: 1, TokenRemaining: 0, CostRemaining: 0},
+				{Id: 2, TokenRemaining: 75, CostRemaining: 2250},
+			},
+			basis: 1250,
+		},
+		{
+			name: "empty lots are skipped",
+			lots: []types.Lot{
+				{Id: 1, TokenRemaining: 0, CostRemaining: 0},
+				{Id: 2, TokenRemaining: 10, CostRemaining: 100},
+			},
+			amount:  5,
+			changed: []types.Lot{{Id: 2, TokenRemaining: 5, CostRemaining: 50}},
+			basis:   50,
+		},
+		{
+			name:    "partial cost rounds down, the remainder stays on the lot",
+			lots:    []types.Lot{{Id: 1, TokenRemaining: 3, CostRemaining: 100}},
+			amount:  1,
+			changed: []types.Lot{{Id: 1, TokenRemaining: 2, CostRemaining: 67}},
+			basis:   33,
+		},
+		{
+			name:      "more than the lots hold",
+			lots:      []types.Lot{{Id: 1, TokenRemaining: 10, CostRemaining: 100}},
+			amount:    25,
+			changed:   []types.Lot{{Id: 1, TokenRemaining: 0, CostRemaining: 0}},
+			basis:     100,
+			unmatched: 15,
+		},
+		{
+			name:      "no lots",
+			amount:    25,
+			unmatched: 25,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed, basis, unmatched := matchLots(tt.lots, tt.amount)
+
+			if basis != tt.basis || unmatched != tt.unmatched {
+				t.Errorf("got basis %d unmatched %d, want basis %d unmatched %d", basis, unmatched, tt.basis, tt.unmatched)
+			}
+
+			if len(changed) != len(tt.changed) {
+				t.Fatalf("got %d changed lots, want %d", len(changed), len(tt.changed))
+			}
+			for i := range changed {
+				if changed[i] != tt.changed[i] {
+					t.Errorf("lot %d: got %+v, want %+v", i, changed[i], tt.changed[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRealizedPnl(t *testing.T) {
+	tests := []struct {
+		name  string
+		fill  types.Fill
+		basis uint64
+		want  int64
+	}{
+		{"fully matched", types.Fill{SolOut: 2000, TokenOut: 100, Fee: 5000}, 1000, -4000},
+		{"unmatched proceeds are left out", types.Fill{SolOut: 3000, TokenOut: 150, TokenUnmatched: 50}, 1000, 1000},
+		{"nothing matched", types.Fill{SolOut: 3000, TokenOut: 150, TokenUnmatched: 150, Tip: 10}, 0, -10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := realizedPnl(&tt.fill, tt.basis); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
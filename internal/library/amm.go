@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/adapter"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/storage"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
+)
+
+// Reserves change on every swap, a pool's snapshot is written at most this often
+const AMM_RESERVE_SNAPSHOT_INTERVAL = 30 * time.Second
+
+var (
+	reserveSnapshotMutex  sync.Mutex
+	reserveSnapshots      = make(map[solana.PublicKey]time.Time)
+	reserveSnapshotPruned time.Time
+)
+
+func SetAmm(amm *types.Amm) error {
+	db, err := adapter.GetMySQLClient()
+	if err != nil {
+		return err
+	}
+
+	return storage.NewAmmStorage(db).SetAmm(amm, time.Now().Unix())
+}
+
+// SetAmmPoolKeys stores the pool as its keys describe it
+func SetAmmPoolKeys(pKey *types.RaydiumPoolKeys) error {
+	return SetAmm(&types.Amm{
+		AmmId:           &pKey.ID,
+		BaseMint:        &pKey.BaseMint,
+		QuoteMint:       &pKey.QuoteMint,
+		LpMint:          &pKey.LpMint,
+		BaseVault:       &pKey.BaseVault,
+		QuoteVault:      &pKey.QuoteVault,
+		MarketId:        &pKey.MarketID,
+		MarketProgramId: &pKey.MarketProgramID,
+		BaseDecimals:    &pKey.BaseDecimals,
+		QuoteDecimals:   &pKey.QuoteDecimals,
+		LpDecimals:      &pKey.LpDecimals,
+	})
+}
+
+// SnapshotAmmReserve writes the pool's reserves in the background, unless its last snapshot
+// is more recent than AMM_RESERVE_SNAPSHOT_INTERVAL
+func SnapshotAmmReserve(ammId solana.PublicKey, baseMint solana.PublicKey, quoteMint solana.PublicKey, base uint64, quote uint64, slot uint64) {
+	now := time.Now()
+
+	reserveSnapshotMutex.Lock()
+	if now.Sub(reserveSnapshots[ammId]) < AMM_RESERVE_SNAPSHOT_INTERVAL {
+		reserveSnapshotMutex.Unlock()
+		return
+	}
+	reserveSnapshots[ammId] = now
+
+	// Entries past the interval no longer throttle anything
+	if now.Sub(reserveSnapshotPruned) >= AMM_RESERVE_SNAPSHOT_INTERVAL {
+		for id, at := range reserveSnapshots {
+			if now.Sub(at) >= AMM_RESERVE_SNAPSHOT_INTERVAL {
+				delete(reserveSnapshots, id)
+			}
+		}
+		reserveSnapshotPruned = now
+	}
+	reserveSnapshotMutex.Unlock()
+
+	go func() {
+		db, err := adapter.GetMySQLClient()
+		if err != nil {
+			return
+		}
+
+		if err := storage.NewAmmStorage(db).SetAmmReserve(ammId, baseMint, quoteMint, base, quote, slot, now.Unix()); err != nil {
+			log.Printf("%s | %v", ammId, err)
+		}
+	}()
+}
+
+func SetAmmStatus(ammId *solana.PublicKey, status string) error {
+	db, err := adapter.GetMySQLClient()
+	if err != nil {
+		return err
+	}
+
+	return storage.NewAmmStorage(db).SetAmmStatus(*ammId, status, time.Now().Unix())
+}
+
+func GetAmmCreator(ammId *solana.PublicKey) (*solana.PublicKey, error) {
+	db, err := adapter.GetMySQLClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.NewAmmStorage(db).GetAmmCreator(*ammId)
+}
+
+func SetAmmLpStatus(ammId *solana.PublicKey, state types.LpState) error {
+	db, err := adapter.GetMySQLClient()
+	if err != nil {
+		return err
+	}
+
+	return storage.NewAmmStorage(db).SetAmmLpStatus(*ammId, state, time.Now().Unix())
+}
+
+func GetAmmLpStatus(ammId *solana.PublicKey) (*types.LpState, error) {
+	db, err := adapter.GetMySQLClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.NewAmmStorage(db).GetAmmLpStatus(*ammId, time.Now().Unix())
+}
+
+func DropAmmLpCreatorStatus(ammId *solana.PublicKey, signature string, slot uint64) error {
+	db, err := adapter.GetMySQLClient()
+	if err != nil {
+		return err
+	}
+
+	return storage.NewAmmStorage(db).DropAmmLpCreatorStatus(*ammId, signature, slot, time.Now().Unix())
+}
+
+func GetLpMints(limit int) ([]solana.PublicKey, error) {
+	db, err := adapter.GetMySQLClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.NewAmmStorage(db).GetLpMints(limit)
+}
+
+func GetAmmIdByLpMint(lpMint solana.PublicKey) (*solana.PublicKey, error) {
+	db, err := adapter.GetMySQLClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.NewAmmStorage(db).GetAmmIdByLpMint(lpMint)
+}
@@ -58,6 +58,8 @@ func SetLookupTable(ammId solana.PublicKey, lookup *addresslookuptable.AddressLo
 	return nil
 }
 
+// Loaded addresses follow the static account keys: every table's writable
+// indexes first, then every table's readonly indexes
 func GenerateTableLookup(addressTableLookups []generators.TxAddressTableLookup) []LookupIndex {
 	var lookupIndexes []LookupIndex
 
@@ -68,6 +70,9 @@ func GenerateTableLookup(addressTableLookups []generators.TxAddressTableLookup)
 				LookupTableKey:   lookup.AccountKey,
 			})
 		}
+	}
+
+	for _, lookup := range addressTableLookups {
 		for _, index := range lookup.ReadonlyIndexes {
 			lookupIndexes = append(lookupIndexes, LookupIndex{
 				LookupTableIndex: index,
@@ -0,0 +1,38 @@
+package bot
+
+import (
+	"log"
+	"time"
+
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/adapter"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/storage"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
+)
+
+// SetPaperTrade records the trade and sets its id
+func SetPaperTrade(trade *types.PaperTrade) error {
+	db, err := adapter.GetMySQLClient()
+	if err != nil {
+		log.Printf("Failed to get initialize mysql instance: %v", err)
+		return err
+	}
+
+	trade.Timestamp = time.Now().Unix()
+	id, err := storage.NewPaperTradeStorage(db).SetPaperTrade(trade)
+	if err != nil {
+		return err
+	}
+
+	trade.Id = id
+
+	return nil
+}
+
+func MarkPaperTrade(trade *types.PaperTrade) error {
+	db, err := adapter.GetMySQLClient()
+	if err != nil {
+		return err
+	}
+
+	return storage.NewPaperTradeStorage(db).MarkPaperTrade(trade.Id, trade.MarkSlot, trade.MarkValue, trade.Pnl)
+}
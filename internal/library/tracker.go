@@ -1,7 +1,9 @@
 package bot
 
 import (
+	"errors"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/gagliardetto/solana-go"
@@ -14,48 +16,124 @@ func trackedInit() {
 
 }
 
-func TrackedAmm(ammId *solana.PublicKey) {
-	redisClient, err := adapter.GetRedisClient(4)
-	if err != nil {
-		log.Fatalf("Failed to get initialize redis instance: %v", err)
-	}
+var trackerMutex sync.Mutex
 
-	var tracker types.Tracker = types.Tracker{}
-	tracker.AmmId = ammId
+// TrackedAmm tracks the pool for triggers, a pool tracked for both keeps its status
+func TrackedAmm(ammId *solana.PublicKey, cause types.TrackerCause) error {
+	return transitionAmm(ammId, nil, func(current types.TrackerStatus) types.TrackerStatus {
+		if current == storage.TRACKED_BOTH {
+			return storage.TRACKED_BOTH
+		}
+		return storage.TRACKED_TRIGGER_ONLY
+	}, cause)
+}
 
-	tracker.Status = storage.TRACKED_TRIGGER_ONLY
+func PauseAmmTracking(ammId *solana.PublicKey, cause types.TrackerCause) error {
+	return TransitionAmm(ammId, storage.PAUSE, cause)
+}
+
+func UntrackedAmm(ammId *solana.PublicKey, cause types.TrackerCause) error {
+	return TransitionAmm(ammId, storage.NOT_TRACKED, cause)
+}
+
+var ErrTrackerChanged = errors.New("tracker changed status since it was read")
+
+// TransitionAmm moves the pool's tracker to status if the state machine allows it, appends the
+// transition to the pool's history and mirrors the status to its amms row
+func TransitionAmm(ammId *solana.PublicKey, status types.TrackerStatus, cause types.TrackerCause) error {
+	return transitionAmm(ammId, nil, always(status), cause)
+}
 
-	storage.SetTracked(redisClient, ammId.String(), tracker)
+// TransitionAmmFrom is TransitionAmm for a decision taken on an earlier read, it fails with
+// ErrTrackerChanged when the tracker is no longer in status from
+func TransitionAmmFrom(ammId *solana.PublicKey, from types.TrackerStatus, status types.TrackerStatus, cause types.TrackerCause) error {
+	return transitionAmm(ammId, &from, always(status), cause)
 }
 
-func PauseAmmTracking(ammId *solana.PublicKey) {
+func always(status types.TrackerStatus) func(types.TrackerStatus) types.TrackerStatus {
+	return func(types.TrackerStatus) types.TrackerStatus { return status }
+}
+
+// The history row is written first, a transition missing from the history never happens
+func transitionAmm(ammId *solana.PublicKey, from *types.TrackerStatus, target func(current types.TrackerStatus) types.TrackerStatus, cause types.TrackerCause) error {
 	redisClient, err := adapter.GetRedisClient(4)
 	if err != nil {
 		log.Fatalf("Failed to get initialize redis instance: %v", err)
 	}
 
-	var tracker types.Tracker = types.Tracker{
+	trackerMutex.Lock()
+	defer trackerMutex.Unlock()
+
+	current, err := storage.GetTracked(redisClient, ammId.String())
+	if err != nil {
+		return err
+	}
+
+	if from != nil && current.Status != *from {
+		return ErrTrackerChanged
+	}
+
+	status := target(current.Status)
+
+	if err := storage.CanTransition(current.Status, status); err != nil {
+		log.Printf("%s | Rejected %s by %s | %v", ammId, status, cause.Reason, err)
+		return err
+	}
+
+	transition := &types.TrackerTransition{
+		AmmId:     ammId,
+		From:      current.Status,
+		To:        status,
+		Reason:    cause.Reason,
+		Signature: cause.Signature,
+		Slot:      cause.Slot,
+		Timestamp: time.Now().Unix(),
+	}
+
+	tracker := types.Tracker{
 		AmmId:       ammId,
-		Status:      storage.PAUSE,
-		LastUpdated: time.Now().Unix(),
+		Status:      status,
+		LastUpdated: transition.Timestamp,
+		Reason:      cause.Reason,
+		Signature:   cause.Signature,
+		Slot:        cause.Slot,
 	}
 
-	storage.SetTracked(redisClient, ammId.String(), tracker)
-}
+	db, err := adapter.GetMySQLClient()
+	if err != nil {
+		return err
+	}
 
-func UntrackedAmm(ammId *solana.PublicKey) {
-	redisClient, err := adapter.GetRedisClient(4)
+	history := storage.NewTrackerHistoryStorage(db)
+	id, err := history.SetTransition(transition)
 	if err != nil {
-		log.Fatalf("Failed to get initialize redis instance: %v", err)
+		return err
 	}
 
-	var tracker types.Tracker = types.Tracker{
-		AmmId:       ammId,
-		Status:      storage.NOT_TRACKED,
-		LastUpdated: time.Now().Unix(),
+	if err := storage.SetTracked(redisClient, ammId.String(), tracker); err != nil {
+		if err := history.DeleteTransition(id); err != nil {
+			log.Printf("%s | %v", ammId, err)
+		}
+		return err
+	}
+
+	log.Printf("%s | %s -> %s | %s | %s (Slot %d)", ammId, transition.From, transition.To, cause.Reason, cause.Signature, cause.Slot)
+
+	if err := SetAmmStatus(ammId, string(status)); err != nil {
+		log.Printf("%s | %v", ammId, err)
+	}
+
+	return nil
+}
+
+// GetAmmTrackingHistory returns every transition of the pool, oldest first
+func GetAmmTrackingHistory(ammId *solana.PublicKey) ([]types.TrackerTransition, error) {
+	db, err := adapter.GetMySQLClient()
+	if err != nil {
+		return nil, err
 	}
 
-	storage.SetTracked(redisClient, ammId.String(), tracker)
+	return storage.NewTrackerHistoryStorage(db).GetTransitions(*ammId)
 }
 
 func GetAmmTrackingStatus(ammId *solana.PublicKey) (*types.Tracker, error) {
@@ -85,3 +163,65 @@ func GetAllTrackedAmm() (*[]types.Tracker, error) {
 
 	return trackers, nil
 }
+
+func GetTrackedAmmPage(status types.TrackerStatus, cursor *storage.TrackerCursor, count int64) ([]types.Tracker, *storage.TrackerCursor, error) {
+	redisClient, err := adapter.GetRedisClient(4)
+	if err != nil {
+		log.Fatalf("Failed to get initialize redis instance: %v", err)
+	}
+
+	return storage.GetTrackedPage(redisClient, status, cursor, count)
+}
+
+func GetTrackedAmmBefore(status types.TrackerStatus, before int64, count int64) ([]string, error) {
+	redisClient, err := adapter.GetRedisClient(4)
+	if err != nil {
+		log.Fatalf("Failed to get initialize redis instance: %v", err)
+	}
+
+	return storage.GetTrackedBefore(redisClient, status, before, count)
+}
+
+func DeleteTrackedAmm(ammId *solana.PublicKey) error {
+	redisClient, err := adapter.GetRedisClient(4)
+	if err != nil {
+		log.Fatalf("Failed to get initialize redis instance: %v", err)
+	}
+
+	return storage.DeleteTracked(redisClient, ammId.String())
+}
+
+func CountTrackedAmm() (map[types.TrackerStatus]int64, error) {
+	redisClient, err := adapter.GetRedisClient(4)
+	if err != nil {
+		log.Fatalf("Failed to get initialize redis instance: %v", err)
+	}
+
+	return storage.CountTracked(redisClient)
+}
+
+// MigrateTrackerIndex builds the tracker index from the stored trackers, once
+func MigrateTrackerIndex() error {
+	redisClient, err := adapter.GetRedisClient(4)
+	if err != nil {
+		log.Fatalf("Failed to get initialize redis instance: %v", err)
+	}
+
+	indexed, err := storage.MigrateTrackedIndex(redisClient)
+	if err != nil {
+		return err
+	}
+
+	if indexed > 0 {
+		log.Printf("Indexed %d trackers", indexed)
+	}
+
+	counts, err := storage.CountTracked(redisClient)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Trackers | %v", counts)
+
+	return nil
+}
@@ -8,6 +8,24 @@ import (
 	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
 )
 
+const (
+	TRADE_BUY  = "BUY"
+	TRADE_SELL = "SELL"
+)
+
+// GetSwapDirection takes the pool vault deltas (pre - post) returned by GetBalanceFromTransaction.
+// The pool paying out tokens while receiving SOL is a buy, the opposite is a sell.
+func GetSwapDirection(amount *big.Int, amountSol *big.Int) (string, bool) {
+	switch {
+	case amount.Sign() == 1 && amountSol.Sign() == -1:
+		return TRADE_BUY, true
+	case amount.Sign() == -1 && amountSol.Sign() == 1:
+		return TRADE_SELL, true
+	default:
+		return "", false
+	}
+}
+
 func GetBalanceFromTransaction(preTokenBalances, postTokenBalances []types.TxTokenBalance, mint solana.PublicKey) *big.Int {
 	var tokenPreAccount, tokenPostAccount *types.TxTokenBalance
 
@@ -0,0 +1,31 @@
+package liquidity
+
+import (
+	"math/big"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/rpc"
+)
+
+// GetLpShareBps returns the share of the LP supply amount moved at slot was. removed is set for
+// withdraws and burns, a supply read at or after their slot no longer holds the amount.
+func GetLpShareBps(lpMint solana.PublicKey, amount uint64, slot uint64, removed bool) (uint64, error) {
+	supply, supplySlot, err := rpc.GetTokenSupply(lpMint)
+	if err != nil {
+		return 0, err
+	}
+
+	if removed && supplySlot >= slot {
+		supply += amount
+	}
+	if supply == 0 {
+		return BPS_DENOMINATOR, nil
+	}
+
+	bps := new(big.Int).Div(
+		new(big.Int).Mul(new(big.Int).SetUint64(amount), big.NewInt(BPS_DENOMINATOR)),
+		new(big.Int).SetUint64(supply),
+	)
+
+	return min(bps.Uint64(), BPS_DENOMINATOR), nil
+}
@@ -1,12 +1,16 @@
 package liquidity
 
 type LiquidityPoolInfo struct {
-	Status       uint64
-	BaseDecimals int
-	QuoteDecimals int
-	LpDecimals   int
-	BaseReserve  uint64
-	QuoteReserve uint64
-	LpSupply     uint64
-	StartTime    uint64
+	Status              uint64
+	BaseDecimals        int
+	QuoteDecimals       int
+	LpDecimals          int
+	BaseReserve         uint64
+	QuoteReserve        uint64
+	LpSupply            uint64
+	StartTime           uint64
+	TradeFeeNumerator   uint64
+	TradeFeeDenominator uint64
+	SwapFeeNumerator    uint64
+	SwapFeeDenominator  uint64
 }
@@ -1,20 +1,31 @@
 package liquidity
 
 import (
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"log"
+	"sync"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/iqbalbaharum/lp-remove-tracker/internal/adapter"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/coder"
 	"github.com/iqbalbaharum/lp-remove-tracker/internal/config"
+	bot "github.com/iqbalbaharum/lp-remove-tracker/internal/library"
 	"github.com/iqbalbaharum/lp-remove-tracker/internal/rpc"
 	"github.com/iqbalbaharum/lp-remove-tracker/internal/storage"
 	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
 )
 
+// Pools written to the amms table by this process
+var ammsBackfilled sync.Map
+
 // Return pool keys from storage if available, otherwise fetch from RPC and store in storage
 func GetPoolKeys(ammId *solana.PublicKey) (*types.RaydiumPoolKeys, error) {
 	redisClient, err := adapter.GetRedisClient(4)
+	if err != nil {
+		return nil, err
+	}
 
 	storedPoolKey, err := storage.GetPoolKeys(redisClient, ammId)
 
@@ -24,17 +35,21 @@ func GetPoolKeys(ammId *solana.PublicKey) (*types.RaydiumPoolKeys, error) {
 	}
 
 	if !storedPoolKey.ID.IsZero() {
+		// Pools cached before the amms table was written get their row on first use
+		if _, stored := ammsBackfilled.LoadOrStore(*ammId, true); !stored {
+			go storeAmm(storedPoolKey)
+		}
 		return storedPoolKey, nil
 	}
 
 	state, err := rpc.GetLiquidityState(ammId)
 	if err != nil {
-		return &types.RaydiumPoolKeys{}, err
+		return nil, err
 	}
 
 	authority, err := getAssociatedAuthority(config.RAYDIUM_AMM_V4)
 	if err != nil {
-		return &types.RaydiumPoolKeys{}, err
+		return nil, err
 	}
 
 	pKey := &types.RaydiumPoolKeys{
@@ -52,29 +67,65 @@ func GetPoolKeys(ammId *solana.PublicKey) (*types.RaydiumPoolKeys, error) {
 		Version:            3,
 		MarketProgramID:    state.MarketProgramId,
 		MarketID:           state.MarketId,
-		MarketAuthority:    authority,
 		WithdrawQueue:      state.WithdrawQueue,
 		LpVault:            state.LpVault,
 		LookupTableAccount: solana.PublicKey{},
 	}
 
-	marketInfo, err := rpc.GetMarketState(&state.MarketId)
+	marketInfo, err := rpc.GetMarketState(&state.MarketId, state.MarketProgramId)
+	if err != nil {
+		return nil, err
+	}
 
+	marketAuthority, err := getMarketAuthority(state.MarketId, state.MarketProgramId, marketInfo.VaultSignerNonce)
 	if err != nil {
-		return &types.RaydiumPoolKeys{}, err
+		return nil, err
 	}
 
+	pKey.MarketAuthority = marketAuthority
 	pKey.MarketBaseVault = marketInfo.BaseVault
 	pKey.MarketQuoteVault = marketInfo.QuoteVault
 	pKey.MarketBids = marketInfo.Bids
 	pKey.MarketAsks = marketInfo.Asks
 	pKey.MarketEventQueue = marketInfo.EventQueue
 
-	storage.SetPoolKeys(redisClient, pKey)
+	if err := validatePoolKeys(pKey, marketInfo); err != nil {
+		return nil, err
+	}
+
+	if err := storage.SetPoolKeys(redisClient, pKey); err != nil {
+		log.Printf("%s | Failed to cache pool keys: %v", ammId, err)
+	}
+
+	ammsBackfilled.Store(*ammId, true)
+	go storeAmm(pKey)
 
 	return pKey, nil
 }
 
+func storeAmm(pKey *types.RaydiumPoolKeys) {
+	if err := bot.SetAmmPoolKeys(pKey); err != nil {
+		log.Printf("%s | %v", pKey.ID, err)
+	}
+}
+
+// Cross check the pool against its market so a mismatched account never gets cached
+func validatePoolKeys(pKey *types.RaydiumPoolKeys, market *coder.MarketStateLayoutV3) error {
+	if pKey.BaseMint.IsZero() || pKey.QuoteMint.IsZero() || pKey.BaseVault.IsZero() || pKey.QuoteVault.IsZero() {
+		return fmt.Errorf("%s: pool keys are missing mints or vaults", pKey.ID)
+	}
+
+	if market.OwnAddress != pKey.MarketID {
+		return fmt.Errorf("%s: market %s reports address %s", pKey.ID, pKey.MarketID, market.OwnAddress)
+	}
+
+	if market.BaseMint != pKey.BaseMint || market.QuoteMint != pKey.QuoteMint {
+		return fmt.Errorf("%s: market mints do not match the pool", pKey.ID)
+	}
+
+	return nil
+}
+
 func GetMint(pKey *types.RaydiumPoolKeys) (solana.PublicKey, bool, error) {
 
 	var mint solana.PublicKey = solana.PublicKey{}
@@ -122,3 +173,10 @@ func getAssociatedAuthority(programId solana.PublicKey) (solana.PublicKey, error
 	}
 	return programAddress, nil
 }
+
+// The market vault signer is derived from the market id and its nonce, not the amm authority
+func getMarketAuthority(marketId solana.PublicKey, marketProgramId solana.PublicKey, nonce uint64) (solana.PublicKey, error) {
+	seed := make([]byte, 8)
+	binary.LittleEndian.PutUint64(seed, nonce)
+	return solana.CreateProgramAddress([][]byte{marketId.Bytes(), seed}, marketProgramId)
+}
@@ -0,0 +1,207 @@
+package liquidity
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/coder"
+)
+
+type SwapDirection int
+
+const (
+	// Coin2PC in the program, base (coin) in and quote (pc) out
+	BASE_TO_QUOTE SwapDirection = iota
+	// PC2Coin in the program, quote (pc) in and base (coin) out
+	QUOTE_TO_BASE
+)
+
+const BPS_DENOMINATOR = 10000
+
+// Every AMM v4 pool is created with a 0.25% swap fee
+const (
+	DEFAULT_SWAP_FEE_NUMERATOR   = 25
+	DEFAULT_SWAP_FEE_DENOMINATOR = 10000
+)
+
+var (
+	ErrZeroAmount            = errors.New("amount must be greater than zero")
+	ErrInsufficientLiquidity = errors.New("insufficient liquidity")
+	ErrInvalidFee            = errors.New("invalid swap fee")
+)
+
+type Quote struct {
+	AmountIn  uint64
+	AmountOut uint64
+	Fee       uint64
+	// Price impact of the curve alone, as a fraction of the spot price
+	PriceImpact float64
+}
+
+// NewLiquidityPoolInfo builds the pool info from the AMM state and the vault
+// balances. The pnl owed to the protocol is not swappable, so it is taken out
+// of the reserves the same way the program does.
+func NewLiquidityPoolInfo(state *coder.LiquidityState, baseVaultAmount uint64, quoteVaultAmount uint64) (*LiquidityPoolInfo, error) {
+	if baseVaultAmount < state.BaseNeedTakePnl || quoteVaultAmount < state.QuoteNeedTakePnl {
+		return nil, fmt.Errorf("%w: vault balance below pnl to take", ErrInsufficientLiquidity)
+	}
+
+	return &LiquidityPoolInfo{
+		Status:              state.Status,
+		BaseDecimals:        int(state.BaseDecimal),
+		QuoteDecimals:       int(state.QuoteDecimal),
+		BaseReserve:         baseVaultAmount - state.BaseNeedTakePnl,
+		QuoteReserve:        quoteVaultAmount - state.QuoteNeedTakePnl,
+		LpSupply:            state.LpReserve,
+		StartTime:           state.PoolOpenTime,
+		TradeFeeNumerator:   state.TradeFeeNumerator,
+		TradeFeeDenominator: state.TradeFeeDenominator,
+		SwapFeeNumerator:    state.SwapFeeNumerator,
+		SwapFeeDenominator:  state.SwapFeeDenominator,
+	}, nil
+}
+
+// ComputeAmountOut quotes a SwapBaseIn: the exact amountOut received for amountIn.
+// The swap fee is the one the swap instruction charges, the trade fee only
+// applies to orderbook fills.
+func ComputeAmountOut(info *LiquidityPoolInfo, amountIn uint64, direction SwapDirection) (*Quote, error) {
+	if amountIn == 0 {
+		return nil, ErrZeroAmount
+	}
+
+	if info.SwapFeeDenominator == 0 || info.SwapFeeNumerator >= info.SwapFeeDenominator {
+		return nil, ErrInvalidFee
+	}
+
+	reserveIn, reserveOut := info.reserves(direction)
+	if reserveIn == 0 || reserveOut == 0 {
+		return nil, ErrInsufficientLiquidity
+	}
+
+	in := new(big.Int).SetUint64(amountIn)
+
+	// swap_fee = ceil(amount_in * swap_fee_numerator / swap_fee_denominator)
+	fee := ceilDiv(
+		new(big.Int).Mul(in, new(big.Int).SetUint64(info.SwapFeeNumerator)),
+		new(big.Int).SetUint64(info.SwapFeeDenominator),
+	)
+	inAfterFee := new(big.Int).Sub(in, fee)
+
+	// amount_out = reserve_out * amount_in / (reserve_in + amount_in)
+	rIn := new(big.Int).SetUint64(reserveIn)
+	rOut := new(big.Int).SetUint64(reserveOut)
+	out := new(big.Int).Mul(rOut, inAfterFee)
+	out.Quo(out, new(big.Int).Add(rIn, inAfterFee))
+
+	return &Quote{
+		AmountIn:    amountIn,
+		AmountOut:   out.Uint64(),
+		Fee:         fee.Uint64(),
+		PriceImpact: priceImpact(inAfterFee, out, rIn, rOut),
+	}, nil
+}
+
+// ComputeAmountIn quotes a SwapBaseOut: the exact amountIn, fee included, needed
+// to receive amountOut.
+func ComputeAmountIn(info *LiquidityPoolInfo, amountOut uint64, direction SwapDirection) (*Quote, error) {
+	if amountOut == 0 {
+		return nil, ErrZeroAmount
+	}
+
+	if info.SwapFeeDenominator == 0 || info.SwapFeeNumerator >= info.SwapFeeDenominator {
+		return nil, ErrInvalidFee
+	}
+
+	reserveIn, reserveOut := info.reserves(direction)
+	if reserveIn == 0 || amountOut >= reserveOut {
+		return nil, ErrInsufficientLiquidity
+	}
+
+	out := new(big.Int).SetUint64(amountOut)
+	rIn := new(big.Int).SetUint64(reserveIn)
+	rOut := new(big.Int).SetUint64(reserveOut)
+
+	// amount_in = ceil(reserve_in * amount_out / (reserve_out - amount_out))
+	inBeforeFee := ceilDiv(
+		new(big.Int).Mul(rIn, out),
+		new(big.Int).Sub(rOut, out),
+	)
+
+	// amount_in_with_fee = ceil(amount_in * swap_fee_denominator / (swap_fee_denominator - swap_fee_numerator))
+	inAfterFee := ceilDiv(
+		new(big.Int).Mul(inBeforeFee, new(big.Int).SetUint64(info.SwapFeeDenominator)),
+		new(big.Int).SetUint64(info.SwapFeeDenominator-info.SwapFeeNumerator),
+	)
+
+	if !inAfterFee.IsUint64() {
+		return nil, ErrInsufficientLiquidity
+	}
+
+	return &Quote{
+		AmountIn:    inAfterFee.Uint64(),
+		AmountOut:   amountOut,
+		Fee:         new(big.Int).Sub(inAfterFee, inBeforeFee).Uint64(),
+		PriceImpact: priceImpact(inBeforeFee, out, rIn, rOut),
+	}, nil
+}
+
+// MinimumAmountOut is the SwapBaseIn minimum_amount_out for a slippage in basis points
+func MinimumAmountOut(amountOut uint64, slippageBps uint64) uint64 {
+	if slippageBps >= BPS_DENOMINATOR {
+		return 0
+	}
+
+	out := new(big.Int).Mul(new(big.Int).SetUint64(amountOut), new(big.Int).SetUint64(BPS_DENOMINATOR-slippageBps))
+	return out.Quo(out, big.NewInt(BPS_DENOMINATOR)).Uint64()
+}
+
+// MaximumAmountIn is the SwapBaseOut max_amount_in for a slippage in basis points
+func MaximumAmountIn(amountIn uint64, slippageBps uint64) uint64 {
+	in := new(big.Int).Mul(new(big.Int).SetUint64(amountIn), new(big.Int).SetUint64(BPS_DENOMINATOR+slippageBps))
+	in = ceilDiv(in, big.NewInt(BPS_DENOMINATOR))
+	if !in.IsUint64() {
+		return ^uint64(0)
+	}
+	return in.Uint64()
+}
+
+func (info *LiquidityPoolInfo) reserves(direction SwapDirection) (uint64, uint64) {
+	if direction == BASE_TO_QUOTE {
+		return info.BaseReserve, info.QuoteReserve
+	}
+	return info.QuoteReserve, info.BaseReserve
+}
+
+// ceilDiv mirrors the program's CheckedCeilDiv for U128: a quotient of zero is
+// rounded half up instead of always up, anything else is rounded up.
+func ceilDiv(numerator *big.Int, denominator *big.Int) *big.Int {
+	quotient, remainder := new(big.Int).QuoRem(numerator, denominator, new(big.Int))
+
+	if quotient.Sign() == 0 {
+		if new(big.Int).Lsh(numerator, 1).Cmp(denominator) >= 0 {
+			return big.NewInt(1)
+		}
+		return big.NewInt(0)
+	}
+
+	if remainder.Sign() > 0 {
+		quotient.Add(quotient, big.NewInt(1))
+	}
+
+	return quotient
+}
+
+// priceImpact compares the execution price against the spot price reserveOut/reserveIn
+func priceImpact(amountIn *big.Int, amountOut *big.Int, reserveIn *big.Int, reserveOut *big.Int) float64 {
+	if amountIn.Sign() == 0 {
+		return 0
+	}
+
+	// 1 - (amountOut * reserveIn) / (amountIn * reserveOut)
+	execution := new(big.Float).SetInt(new(big.Int).Mul(amountOut, reserveIn))
+	spot := new(big.Float).SetInt(new(big.Int).Mul(amountIn, reserveOut))
+
+	ratio, _ := new(big.Float).Quo(execution, spot).Float64()
+	return 1 - ratio
+}
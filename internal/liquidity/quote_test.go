@@ -0,0 +1,124 @@
+package liquidity
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/coder"
+)
+
+// Expected amounts follow the program's swap math in u128: the fee through
+// CheckedCeilDiv, then the constant product with the quotient floored.
+func pool(base uint64, quote uint64) *LiquidityPoolInfo {
+	return &LiquidityPoolInfo{
+		BaseReserve:        base,
+		QuoteReserve:       quote,
+		SwapFeeNumerator:   DEFAULT_SWAP_FEE_NUMERATOR,
+		SwapFeeDenominator: DEFAULT_SWAP_FEE_DENOMINATOR,
+	}
+}
+
+func TestComputeAmountOut(t *testing.T) {
+	tests := []struct {
+		name      string
+		info      *LiquidityPoolInfo
+		amountIn  uint64
+		direction SwapDirection
+		amountOut uint64
+		fee       uint64
+	}{
+		{"buy 1 SOL", pool(2_000_000_000_000_000, 150_000_000_000), 1_000_000_000, QUOTE_TO_BASE, 13_212_139_273_829, 2_500_000},
+		{"sell into SOL", pool(2_000_000_000_000_000, 150_000_000_000), 10_000_000_000_000, BASE_TO_QUOTE, 744_412_243, 25_000_000_000},
+		{"product above u64", pool(18_000_000_000_000_000_000, 5_000_000_000_000), 123_456_789_000_000, BASE_TO_QUOTE, 34_207_584, 308_641_972_500},
+		{"fee quotient zero rounds down", pool(1_000_000, 1_000_000), 100, BASE_TO_QUOTE, 99, 0},
+		{"fee quotient zero rounds half up", pool(1_000_000, 1_000_000), 200, BASE_TO_QUOTE, 198, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote, err := ComputeAmountOut(tt.info, tt.amountIn, tt.direction)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if quote.AmountOut != tt.amountOut || quote.Fee != tt.fee {
+				t.Errorf("got out %d fee %d, want out %d fee %d", quote.AmountOut, quote.Fee, tt.amountOut, tt.fee)
+			}
+		})
+	}
+}
+
+func TestComputeAmountIn(t *testing.T) {
+	tests := []struct {
+		name      string
+		info      *LiquidityPoolInfo
+		amountOut uint64
+		direction SwapDirection
+		amountIn  uint64
+		fee       uint64
+	}{
+		{"buy tokens", pool(2_000_000_000_000_000, 150_000_000_000), 13_000_000_000_000, QUOTE_TO_BASE, 983_838_561, 2_459_597},
+		{"smallest out", pool(1_000_000, 1_000_000), 1, BASE_TO_QUOTE, 3, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote, err := ComputeAmountIn(tt.info, tt.amountOut, tt.direction)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if quote.AmountIn != tt.amountIn || quote.Fee != tt.fee {
+				t.Errorf("got in %d fee %d, want in %d fee %d", quote.AmountIn, quote.Fee, tt.amountIn, tt.fee)
+			}
+		})
+	}
+
+	if _, err := ComputeAmountIn(pool(1_000_000, 1_000_000), 1_000_000, BASE_TO_QUOTE); !errors.Is(err, ErrInsufficientLiquidity) {
+		t.Errorf("draining the pool returned %v", err)
+	}
+}
+
+func TestComputeAmountOutTakesPnlAndPoolFee(t *testing.T) {
+	state := &coder.LiquidityState{
+		SwapFeeNumerator:   30,
+		SwapFeeDenominator: 10000,
+		BaseNeedTakePnl:    1_500_000,
+		QuoteNeedTakePnl:   2_000,
+	}
+
+	info, err := NewLiquidityPoolInfo(state, 80_000_000_000, 900_000_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quote, err := ComputeAmountOut(info, 500_000_000, BASE_TO_QUOTE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.AmountOut != 5_573_499_621 || quote.Fee != 1_500_000 {
+		t.Errorf("got out %d fee %d", quote.AmountOut, quote.Fee)
+	}
+}
+
+func TestCeilDiv(t *testing.T) {
+	tests := []struct {
+		numerator   int64
+		denominator int64
+		want        int64
+	}{
+		{0, 10, 0},
+		{4, 10, 0},
+		{5, 10, 1},
+		{9, 10, 1},
+		{10, 10, 1},
+		{11, 10, 2},
+		{20, 10, 2},
+	}
+
+	for _, tt := range tests {
+		got := ceilDiv(big.NewInt(tt.numerator), big.NewInt(tt.denominator))
+		if got.Int64() != tt.want {
+			t.Errorf("ceilDiv(%d, %d) = %s, want %d", tt.numerator, tt.denominator, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package liquidity
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/coder"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/rpc"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
+)
+
+type PoolReserve struct {
+	AmmId     solana.PublicKey
+	BaseMint  solana.PublicKey
+	QuoteMint solana.PublicKey
+	Base      uint64
+	Quote     uint64
+	Slot      uint64
+	UpdatedAt time.Time
+	// Nil until the pool's state has been read
+	State *PoolState
+}
+
+// The PnL owed to the protocol only grows with the pool's volume, the state of a pool being
+// quoted is reread this often
+const POOL_STATE_REFRESH_INTERVAL = time.Minute
+
+const (
+	// A pool no longer quoted for this long stops being refreshed
+	POOL_STATE_WANTED_TTL = 10 * time.Minute
+	// One batch of states is read this often, a single worker keeps the RPC load bounded
+	POOL_STATE_BATCH_INTERVAL = time.Second
+	// Reserves the stream has not updated for this long are forgotten
+	POOL_RESERVE_TTL = time.Hour
+)
+
+// PoolState is what quoting needs from the AMM state besides the vault balances. It is
+// replaced on refresh, never modified.
+type PoolState struct {
+	SwapFeeNumerator   uint64
+	SwapFeeDenominator uint64
+	BaseNeedTakePnl    uint64
+	QuoteNeedTakePnl   uint64
+	FetchedAt          time.Time
+}
+
+var (
+	reserveMutex sync.RWMutex
+	reserves     = make(map[solana.PublicKey]*PoolReserve)
+	// When each pool was last asked for a fresh state
+	stateWanted   = make(map[solana.PublicKey]time.Time)
+	refresherOnce sync.Once
+)
+
+// UpdatePoolReserve records the vault balances of a pool as of slot. Either side
+// may be nil when the transaction did not touch that vault. Updates older than
+// the stored slot are dropped since sources deliver out of order.
+func UpdatePoolReserve(pKey *types.RaydiumPoolKeys, base *uint64, quote *uint64, slot uint64) (PoolReserve, bool) {
+	reserveMutex.Lock()
+	defer reserveMutex.Unlock()
+
+	reserve, exists := reserves[pKey.ID]
+	if !exists {
+		// A pool is only usable once both sides are known
+		if base == nil || quote == nil {
+			return PoolReserve{}, false
+		}
+
+		reserve = &PoolReserve{
+			AmmId:     pKey.ID,
+			BaseMint:  pKey.BaseMint,
+			QuoteMint: pKey.QuoteMint,
+		}
+		reserves[pKey.ID] = reserve
+	} else if slot < reserve.Slot {
+		return *reserve, false
+	}
+
+	if base != nil {
+		reserve.Base = *base
+	}
+
+	if quote != nil {
+		reserve.Quote = *quote
+	}
+
+	reserve.Slot = slot
+	reserve.UpdatedAt = time.Now()
+
+	return *reserve, true
+}
+
+// WantPoolState asks for the pool's state to be read, and kept fresh while the pool keeps
+// being asked for. Only pools a trade or route is quoted on are read, in batches.
+func WantPoolState(ammId solana.PublicKey) {
+	refresherOnce.Do(func() {
+		go refreshPoolStates()
+	})
+
+	reserveMutex.Lock()
+	defer reserveMutex.Unlock()
+
+	stateWanted[ammId] = time.Now()
+}
+
+func refreshPoolStates() {
+	pruned := time.Now()
+
+	for now := range time.Tick(POOL_STATE_BATCH_INTERVAL) {
+		if ammIds := staleWantedPools(now); len(ammIds) > 0 {
+			states, errs := rpc.GetLiquidityStates(ammIds)
+			setPoolStates(ammIds, states, errs, now)
+		}
+
+		if now.Sub(pruned) >= POOL_STATE_REFRESH_INTERVAL {
+			pruneReserves(now)
+			pruned = now
+		}
+	}
+}
+
+// Up to one getMultipleAccounts call of the wanted pools whose state is missing or stale
+func staleWantedPools(now time.Time) []solana.PublicKey {
+	reserveMutex.Lock()
+	defer reserveMutex.Unlock()
+
+	var ammIds []solana.PublicKey
+	for ammId, wantedAt := range stateWanted {
+		if now.Sub(wantedAt) > POOL_STATE_WANTED_TTL {
+			delete(stateWanted, ammId)
+			continue
+		}
+
+		reserve, exists := reserves[ammId]
+		if !exists || (reserve.State != nil && now.Sub(reserve.State.FetchedAt) < POOL_STATE_REFRESH_INTERVAL) {
+			continue
+		}
+
+		ammIds = append(ammIds, ammId)
+		if len(ammIds) == rpc.MULTIPLE_ACCOUNTS_LIMIT {
+			break
+		}
+	}
+
+	return ammIds
+}
+
+func setPoolStates(ammIds []solana.PublicKey, states []*coder.LiquidityState, errs []error, now time.Time) {
+	reserveMutex.Lock()
+	defer reserveMutex.Unlock()
+
+	for i, ammId := range ammIds {
+		reserve, exists := reserves[ammId]
+		if !exists {
+			continue
+		}
+
+		if errs[i] != nil {
+			log.Printf("%s | %v", ammId, errs[i])
+			// Retried after the refresh interval rather than on every batch
+			if reserve.State != nil {
+				state := *reserve.State
+				state.FetchedAt = now
+				reserve.State = &state
+			}
+			continue
+		}
+
+		reserve.State = &PoolState{
+			SwapFeeNumerator:   states[i].SwapFeeNumerator,
+			SwapFeeDenominator: states[i].SwapFeeDenominator,
+			BaseNeedTakePnl:    states[i].BaseNeedTakePnl,
+			QuoteNeedTakePnl:   states[i].QuoteNeedTakePnl,
+			FetchedAt:          now,
+		}
+	}
+}
+
+func pruneReserves(now time.Time) {
+	reserveMutex.Lock()
+	defer reserveMutex.Unlock()
+
+	for ammId, reserve := range reserves {
+		if now.Sub(reserve.UpdatedAt) > POOL_RESERVE_TTL {
+			delete(reserves, ammId)
+		}
+	}
+}
+
+// PoolInfo turns the streamed reserve into quotable pool info. The PnL owed to the protocol
+// is taken out of the vaults as the program does, and the pool's own swap fee applies. Until
+// the state is read the default fee is assumed.
+func (r PoolReserve) PoolInfo() *LiquidityPoolInfo {
+	if r.State == nil {
+		return &LiquidityPoolInfo{
+			BaseReserve:        r.Base,
+			QuoteReserve:       r.Quote,
+			SwapFeeNumerator:   DEFAULT_SWAP_FEE_NUMERATOR,
+			SwapFeeDenominator: DEFAULT_SWAP_FEE_DENOMINATOR,
+		}
+	}
+
+	return &LiquidityPoolInfo{
+		BaseReserve:        r.Base - min(r.Base, r.State.BaseNeedTakePnl),
+		QuoteReserve:       r.Quote - min(r.Quote, r.State.QuoteNeedTakePnl),
+		SwapFeeNumerator:   r.State.SwapFeeNumerator,
+		SwapFeeDenominator: r.State.SwapFeeDenominator,
+	}
+}
+
+// Direction of a swap that spends inputMint in this pool
+func (r PoolReserve) Direction(inputMint solana.PublicKey) SwapDirection {
+	if inputMint == r.BaseMint {
+		return BASE_TO_QUOTE
+	}
+	return QUOTE_TO_BASE
+}
+
+// GetCachedPoolReserve returns the last reserve seen on the transaction stream
+func GetCachedPoolReserve(ammId solana.PublicKey) (PoolReserve, bool) {
+	reserveMutex.RLock()
+	defer reserveMutex.RUnlock()
+
+	reserve, exists := reserves[ammId]
+	if !exists {
+		return PoolReserve{}, false
+	}
+
+	return *reserve, true
+}
+
+// GetPoolReserve returns the cached reserve, and only reads the vaults over RPC
+// for a pool that has never been seen on the stream
+func GetPoolReserve(pKey *types.RaydiumPoolKeys) (PoolReserve, error) {
+	if reserve, exists := GetCachedPoolReserve(pKey.ID); exists {
+		return reserve, nil
+	}
+
+	base, baseSlot, err := rpc.GetTokenAccountBalance(pKey.BaseVault)
+	if err != nil {
+		return PoolReserve{}, err
+	}
+
+	quote, quoteSlot, err := rpc.GetTokenAccountBalance(pKey.QuoteVault)
+	if err != nil {
+		return PoolReserve{}, err
+	}
+
+	reserve, _ := UpdatePoolReserve(pKey, &base, &quote, min(baseSlot, quoteSlot))
+
+	return reserve, nil
+}
+
+// GetPoolSolReserve returns the WSOL side of the pool's reserve
+func GetPoolSolReserve(pKey *types.RaydiumPoolKeys) (uint64, error) {
+	_, swap, err := GetMint(pKey)
+	if err != nil {
+		return 0, err
+	}
+
+	reserve, err := GetPoolReserve(pKey)
+	if err != nil {
+		return 0, err
+	}
+
+	if swap {
+		return reserve.Base, nil
+	}
+
+	return reserve.Quote, nil
+}
@@ -1,7 +1,6 @@
 package pool
 
 import (
-	"log"
 	"sync"
 
 	"github.com/gagliardetto/solana-go"
@@ -11,6 +10,7 @@ import (
 type BloxRoutePoolStream struct {
 	transaction   *solana.Transaction
 	useStakedFlag bool
+	result        chan rpc.BloxRouteResult
 }
 
 type BloxRoutePool struct {
@@ -25,6 +25,9 @@ func NewBloxRoutePool(numClients int) (*BloxRoutePool, error) {
 	for i := 0; i < numClients; i++ {
 		client, err := rpc.NewBloxRouteRpc()
 		if err != nil {
+			for _, opened := range clients[:i] {
+				opened.Close()
+			}
 			return nil, err
 		}
 		clients[i] = client
@@ -46,21 +49,29 @@ func NewBloxRoutePool(numClients int) (*BloxRoutePool, error) {
 func (p *BloxRoutePool) worker(client *rpc.BloxRouteRpc) {
 	defer p.wg.Done()
 	for stream := range p.taskCh {
-		err := client.StreamBloxRouteTransaction(stream.transaction, stream.useStakedFlag)
-		if err != nil {
-			log.Println("Failed to send transaction:", err)
-		}
+		stream.result <- client.StreamBloxRouteTransaction(stream.transaction, stream.useStakedFlag)
 	}
 }
 
-func (p *BloxRoutePool) SendTransaction(transaction *solana.Transaction, useStakedFlag bool) {
+// SendTransaction queues the transaction on the next free client. The returned channel receives
+// exactly one result once bloXroute acknowledges or rejects the submission.
+func (p *BloxRoutePool) SendTransaction(transaction *solana.Transaction, useStakedFlag bool) <-chan rpc.BloxRouteResult {
+	result := make(chan rpc.BloxRouteResult, 1)
+
 	p.taskCh <- &BloxRoutePoolStream{
 		transaction:   transaction,
 		useStakedFlag: useStakedFlag,
+		result:        result,
 	}
+
+	return result
 }
 
 func (p *BloxRoutePool) Close() {
 	close(p.taskCh)
 	p.wg.Wait()
+
+	for _, client := range p.clients {
+		client.Close()
+	}
 }
@@ -0,0 +1,251 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/config"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/generators"
+)
+
+const (
+	BLOXROUTE_SUBMIT_PATH     = "/api/v2/submit"
+	BLOXROUTE_REQUEST_TIMEOUT = 10 * time.Second
+)
+
+var ErrBloxRouteTimeout = errors.New("bloxroute request timed out")
+
+type BloxRouteSubmitOptions struct {
+	FrontRunningProtection bool
+	UseStakedRPCs          bool
+	SkipPreFlight          bool
+}
+
+// BloxRouteResult is the outcome of a single PostSubmit
+type BloxRouteResult struct {
+	Signature string
+	Latency   time.Duration
+	Err       error
+}
+
+type bloxRouteSubmitParams struct {
+	Transaction struct {
+		Content string `json:"content"`
+	} `json:"transaction"`
+	FrontRunningProtection bool `json:"frontRunningProtection"`
+	UseStakedRPCs          bool `json:"useStakedRPCs"`
+	SkipPreFlight          bool `json:"skipPreFlight"`
+}
+
+type bloxRouteResponse struct {
+	ID     int `json:"id"`
+	Result *struct {
+		Signature string `json:"signature"`
+	} `json:"result"`
+	Error *RPCError `json:"error"`
+	// The HTTP interface returns the signature at the top level
+	Signature string `json:"signature"`
+	Message   string `json:"message"`
+}
+
+// BloxRouteRpc submits transactions through the bloXroute Trader API websocket
+type BloxRouteRpc struct {
+	wsClient *generators.WSClient
+	mutex    sync.Mutex
+	nextId   int
+	pending  map[int]chan bloxRouteResponse
+}
+
+func NewBloxRouteRpc() (*BloxRouteRpc, error) {
+	wsClient, err := generators.NewWSClient(config.BloxRouteWsUrl, config.BloxRouteAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &BloxRouteRpc{
+		wsClient: wsClient,
+		pending:  make(map[int]chan bloxRouteResponse),
+	}
+
+	go client.readResponses()
+
+	return client, nil
+}
+
+// StreamBloxRouteTransaction submits the transaction with the configured front running protection
+func (b *BloxRouteRpc) StreamBloxRouteTransaction(transaction *solana.Transaction, useStakedFlag bool) BloxRouteResult {
+	return b.PostSubmit(transaction, BloxRouteSubmitOptions{
+		FrontRunningProtection: config.BloxRouteFrontRunningProtection,
+		UseStakedRPCs:          useStakedFlag,
+		SkipPreFlight:          true,
+	})
+}
+
+// PostSubmit sends the transaction over the websocket and waits for bloXroute to acknowledge it
+func (b *BloxRouteRpc) PostSubmit(transaction *solana.Transaction, opts BloxRouteSubmitOptions) BloxRouteResult {
+	start := time.Now()
+
+	params, err := newBloxRouteSubmitParams(transaction, opts)
+	if err != nil {
+		return BloxRouteResult{Err: err}
+	}
+
+	responseChan := make(chan bloxRouteResponse, 1)
+
+	b.mutex.Lock()
+	b.nextId++
+	id := b.nextId
+	b.pending[id] = responseChan
+
+	request, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  "PostSubmit",
+		"params":  params,
+	})
+	if err == nil {
+		err = b.wsClient.SendMessage(string(request))
+	}
+	b.mutex.Unlock()
+
+	if err != nil {
+		b.forget(id)
+		return BloxRouteResult{Latency: time.Since(start), Err: err}
+	}
+
+	select {
+	case response := <-responseChan:
+		result := response.result()
+		result.Latency = time.Since(start)
+		return result
+	case <-time.After(BLOXROUTE_REQUEST_TIMEOUT):
+		b.forget(id)
+		return BloxRouteResult{Latency: time.Since(start), Err: ErrBloxRouteTimeout}
+	}
+}
+
+// PostSubmitHttp sends the transaction through the HTTP interface, for callers without a websocket
+func PostSubmitHttp(transaction *solana.Transaction, opts BloxRouteSubmitOptions) BloxRouteResult {
+	start := time.Now()
+
+	params, err := newBloxRouteSubmitParams(transaction, opts)
+	if err != nil {
+		return BloxRouteResult{Err: err}
+	}
+
+	body, err := json.Marshal(params)
+	if err != nil {
+		return BloxRouteResult{Err: err}
+	}
+
+	req, err := http.NewRequest("POST", config.BloxRouteHttpUrl+BLOXROUTE_SUBMIT_PATH, bytes.NewBuffer(body))
+	if err != nil {
+		return BloxRouteResult{Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", config.BloxRouteAuth)
+
+	client := &http.Client{Timeout: BLOXROUTE_REQUEST_TIMEOUT}
+	resp, err := client.Do(req)
+	if err != nil {
+		return BloxRouteResult{Latency: time.Since(start), Err: err}
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return BloxRouteResult{Latency: time.Since(start), Err: err}
+	}
+
+	var response bloxRouteResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return BloxRouteResult{Latency: time.Since(start), Err: fmt.Errorf("status %d: %s", resp.StatusCode, data)}
+	}
+
+	result := response.result()
+	if result.Err == nil && resp.StatusCode != http.StatusOK {
+		result.Err = fmt.Errorf("status %d: %s", resp.StatusCode, data)
+	}
+	result.Latency = time.Since(start)
+
+	return result
+}
+
+func (b *BloxRouteRpc) Close() error {
+	return b.wsClient.Close()
+}
+
+func (b *BloxRouteRpc) readResponses() {
+	messageChan := make(chan []byte)
+
+	go func() {
+		b.wsClient.ReadMessages(messageChan)
+		close(messageChan)
+	}()
+
+	for message := range messageChan {
+		var response bloxRouteResponse
+		if err := json.Unmarshal(message, &response); err != nil {
+			continue
+		}
+
+		b.mutex.Lock()
+		responseChan, ok := b.pending[response.ID]
+		delete(b.pending, response.ID)
+		b.mutex.Unlock()
+
+		if ok {
+			responseChan <- response
+		}
+	}
+}
+
+func (b *BloxRouteRpc) forget(id int) {
+	b.mutex.Lock()
+	delete(b.pending, id)
+	b.mutex.Unlock()
+}
+
+func newBloxRouteSubmitParams(transaction *solana.Transaction, opts BloxRouteSubmitOptions) (bloxRouteSubmitParams, error) {
+	var params bloxRouteSubmitParams
+
+	data, err := transaction.MarshalBinary()
+	if err != nil {
+		return params, err
+	}
+
+	params.Transaction.Content = base64.StdEncoding.EncodeToString(data)
+	params.FrontRunningProtection = opts.FrontRunningProtection
+	params.UseStakedRPCs = opts.UseStakedRPCs
+	params.SkipPreFlight = opts.SkipPreFlight
+
+	return params, nil
+}
+
+func (r bloxRouteResponse) result() BloxRouteResult {
+	if r.Error != nil {
+		return BloxRouteResult{Err: fmt.Errorf("bloxroute: %s (%d)", r.Error.Message, r.Error.Code)}
+	}
+
+	if r.Result != nil && r.Result.Signature != "" {
+		return BloxRouteResult{Signature: r.Result.Signature}
+	}
+
+	if r.Signature != "" {
+		return BloxRouteResult{Signature: r.Signature}
+	}
+
+	if r.Message != "" {
+		return BloxRouteResult{Err: fmt.Errorf("bloxroute: %s", r.Message)}
+	}
+
+	return BloxRouteResult{Err: errors.New("bloxroute: empty response")}
+}
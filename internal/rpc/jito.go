@@ -0,0 +1,270 @@
+package rpc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+const (
+	MAX_BUNDLE_SIZE    = 5
+	JITO_BUNDLES_PATH  = "/api/v1/bundles"
+	BUNDLE_POLL_PERIOD = 500 * time.Millisecond
+	BUNDLE_TIMEOUT     = 30 * time.Second
+	// How long a bundle the block engine does not know yet is still waited for
+	BUNDLE_UNKNOWN_GRACE = 10 * time.Second
+)
+
+type BundleStatus string
+
+const (
+	BUNDLE_PENDING BundleStatus = "pending"
+	BUNDLE_LANDED  BundleStatus = "landed"
+	BUNDLE_FAILED  BundleStatus = "failed"
+	BUNDLE_DROPPED BundleStatus = "dropped"
+	// Missing or invalid in flight, the block engine may not have indexed the bundle yet
+	BUNDLE_UNKNOWN BundleStatus = "unknown"
+)
+
+var (
+	ErrEmptyBundle    = errors.New("bundle has no transactions")
+	ErrBundleTooLarge = fmt.Errorf("bundle exceeds %d transactions", MAX_BUNDLE_SIZE)
+)
+
+type BundleResult struct {
+	BundleId           string
+	Status             BundleStatus
+	Slot               uint64
+	ConfirmationStatus string
+	Transactions       []string
+	Err                string
+}
+
+type JitoClient struct {
+	url          string
+	PollInterval time.Duration
+	Timeout      time.Duration
+	UnknownGrace time.Duration
+}
+
+// Bundle client for a block engine, e.g. config.BLOCKENGINE_URL
+func NewJitoClient(blockEngineUrl string) *JitoClient {
+	return &JitoClient{
+		url:          blockEngineUrl + JITO_BUNDLES_PATH,
+		PollInterval: BUNDLE_POLL_PERIOD,
+		Timeout:      BUNDLE_TIMEOUT,
+		UnknownGrace: BUNDLE_UNKNOWN_GRACE,
+	}
+}
+
+// SendBundle submits the signed transactions to be executed atomically and in order, returning the bundle id
+func (c *JitoClient) SendBundle(txs []*solana.Transaction) (string, error) {
+	if len(txs) == 0 {
+		return "", ErrEmptyBundle
+	}
+
+	if len(txs) > MAX_BUNDLE_SIZE {
+		return "", ErrBundleTooLarge
+	}
+
+	encoded := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		data, err := tx.MarshalBinary()
+		if err != nil {
+			return "", err
+		}
+		encoded = append(encoded, base64.StdEncoding.EncodeToString(data))
+	}
+
+	params := []interface{}{
+		encoded,
+		map[string]interface{}{
+			"encoding": "base64",
+		},
+	}
+
+	response, err := CallRPC("sendBundle", params, c.url)
+	if err != nil {
+		return "", err
+	}
+
+	var bundleId string
+	if err := json.Unmarshal(response.Result, &bundleId); err != nil {
+		return "", err
+	}
+
+	return bundleId, nil
+}
+
+type bundleStatusesResult struct {
+	Value []*struct {
+		BundleId           string          `json:"bundle_id"`
+		Transactions       []string        `json:"transactions"`
+		Slot               uint64          `json:"slot"`
+		ConfirmationStatus string          `json:"confirmation_status"`
+		Err                json.RawMessage `json:"err"`
+	} `json:"value"`
+}
+
+// GetBundleStatuses returns the outcome of bundles that have landed. Bundles the block engine
+// has no record of are reported as pending, use GetInflightBundleStatuses to tell them apart.
+func (c *JitoClient) GetBundleStatuses(bundleIds []string) ([]BundleResult, error) {
+	response, err := CallRPC("getBundleStatuses", []interface{}{bundleIds}, c.url)
+	if err != nil {
+		return nil, err
+	}
+
+	var result bundleStatusesResult
+	if err := json.Unmarshal(response.Result, &result); err != nil {
+		return nil, err
+	}
+
+	results := make([]BundleResult, len(bundleIds))
+	for i, bundleId := range bundleIds {
+		results[i] = BundleResult{BundleId: bundleId, Status: BUNDLE_PENDING}
+	}
+
+	for _, value := range result.Value {
+		if value == nil {
+			continue
+		}
+
+		for i := range results {
+			if results[i].BundleId != value.BundleId {
+				continue
+			}
+
+			results[i].Status = BUNDLE_LANDED
+			results[i].Slot = value.Slot
+			results[i].ConfirmationStatus = value.ConfirmationStatus
+			results[i].Transactions = value.Transactions
+
+			if bundleErr := bundleError(value.Err); bundleErr != "" {
+				results[i].Status = BUNDLE_FAILED
+				results[i].Err = bundleErr
+			}
+		}
+	}
+
+	return results, nil
+}
+
+type inflightBundleStatusesResult struct {
+	Value []*struct {
+		BundleId   string  `json:"bundle_id"`
+		Status     string  `json:"status"`
+		LandedSlot *uint64 `json:"landed_slot"`
+	} `json:"value"`
+}
+
+// GetInflightBundleStatuses returns the state of bundles submitted in the last five minutes
+func (c *JitoClient) GetInflightBundleStatuses(bundleIds []string) ([]BundleResult, error) {
+	response, err := CallRPC("getInflightBundleStatuses", []interface{}{bundleIds}, c.url)
+	if err != nil {
+		return nil, err
+	}
+
+	var result inflightBundleStatusesResult
+	if err := json.Unmarshal(response.Result, &result); err != nil {
+		return nil, err
+	}
+
+	results := make([]BundleResult, len(bundleIds))
+	for i, bundleId := range bundleIds {
+		// Unknown to the block engine, not indexed yet, never accepted or expired
+		results[i] = BundleResult{BundleId: bundleId, Status: BUNDLE_UNKNOWN}
+	}
+
+	for _, value := range result.Value {
+		if value == nil {
+			continue
+		}
+
+		for i := range results {
+			if results[i].BundleId != value.BundleId {
+				continue
+			}
+
+			switch value.Status {
+			case "Pending":
+				results[i].Status = BUNDLE_PENDING
+			case "Landed":
+				results[i].Status = BUNDLE_LANDED
+			case "Failed":
+				results[i].Status = BUNDLE_FAILED
+			default:
+				results[i].Status = BUNDLE_UNKNOWN
+			}
+
+			if value.LandedSlot != nil {
+				results[i].Slot = *value.LandedSlot
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// WaitForBundle polls the block engine until the bundle lands, fails or is dropped. A bundle
+// still unknown after the grace period, or pending after the timeout, is reported as dropped.
+func (c *JitoClient) WaitForBundle(bundleId string) (BundleResult, error) {
+	start := time.Now()
+	deadline := start.Add(c.Timeout)
+
+	for {
+		inflight, err := c.GetInflightBundleStatuses([]string{bundleId})
+		if err != nil {
+			return BundleResult{}, err
+		}
+
+		result := inflight[0]
+
+		switch result.Status {
+		case BUNDLE_LANDED:
+			landed, err := c.GetBundleStatuses([]string{bundleId})
+			if err != nil {
+				return result, err
+			}
+
+			// The landed status can show up in flight before the bundle status is queryable
+			if landed[0].Status != BUNDLE_PENDING {
+				return landed[0], nil
+			}
+			return result, nil
+		case BUNDLE_FAILED, BUNDLE_DROPPED:
+			return result, nil
+		case BUNDLE_UNKNOWN:
+			if time.Since(start) >= c.UnknownGrace {
+				result.Status = BUNDLE_DROPPED
+				return result, nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			result.Status = BUNDLE_DROPPED
+			return result, nil
+		}
+
+		time.Sleep(c.PollInterval)
+	}
+}
+
+// The block engine reports success as {"Ok": null}
+func bundleError(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err == nil {
+		if _, ok := fields["Ok"]; ok {
+			return ""
+		}
+	}
+
+	return string(raw)
+}
@@ -0,0 +1,249 @@
+package rpc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/config"
+)
+
+// LocalBlockEngine is an offline stand-in for the block engine bundle API. Serve it with
+// http.ListenAndServe or httptest.NewServer and point a JitoClient at its address.
+type LocalBlockEngine struct {
+	mutex   sync.Mutex
+	bundles map[string]*localBundle
+	slot    uint64
+	// Outcome decides how a bundle resolves. By default bundles that pay a tip account land
+	// and the rest are dropped, like the real block engine does.
+	Outcome func(txs []*solana.Transaction) BundleStatus
+	// UnindexedPolls is how many in-flight polls leave a new bundle out, as the block engine
+	// does until it has indexed it, then PendingPolls report it pending before its outcome
+	UnindexedPolls int
+	PendingPolls   int
+}
+
+type localBundle struct {
+	signatures []string
+	status     BundleStatus
+	polls      int
+	slot       uint64
+}
+
+func NewLocalBlockEngine() *LocalBlockEngine {
+	return &LocalBlockEngine{
+		bundles:      make(map[string]*localBundle),
+		slot:         1,
+		Outcome:      tippedBundleOutcome,
+		PendingPolls: 1,
+	}
+}
+
+func tippedBundleOutcome(txs []*solana.Transaction) BundleStatus {
+	for _, tx := range txs {
+		for _, key := range tx.Message.AccountKeys {
+			if config.IsJitoTipAddress(key) {
+				return BUNDLE_LANDED
+			}
+		}
+	}
+	return BUNDLE_DROPPED
+}
+
+func (e *LocalBlockEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ID     int               `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var result interface{}
+	var err error
+
+	switch request.Method {
+	case "sendBundle":
+		result, err = e.sendBundle(request.Params)
+	case "getBundleStatuses":
+		result, err = e.getBundleStatuses(request.Params)
+	case "getInflightBundleStatuses":
+		result, err = e.getInflightBundleStatuses(request.Params)
+	default:
+		err = fmt.Errorf("method %s not found", request.Method)
+	}
+
+	response := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      request.ID,
+	}
+
+	if err != nil {
+		response["error"] = RPCError{Code: -32602, Message: err.Error()}
+	} else {
+		response["result"] = result
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (e *LocalBlockEngine) sendBundle(params []json.RawMessage) (interface{}, error) {
+	if len(params) == 0 {
+		return nil, fmt.Errorf("missing bundle")
+	}
+
+	var encoded []string
+	if err := json.Unmarshal(params[0], &encoded); err != nil {
+		return nil, err
+	}
+
+	if len(encoded) == 0 || len(encoded) > MAX_BUNDLE_SIZE {
+		return nil, fmt.Errorf("bundle must contain between 1 and %d transactions", MAX_BUNDLE_SIZE)
+	}
+
+	txs := make([]*solana.Transaction, 0, len(encoded))
+	signatures := make([]string, 0, len(encoded))
+	hash := sha256.New()
+
+	for _, data := range encoded {
+		raw, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, err
+		}
+
+		tx, err := solana.TransactionFromBytes(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(tx.Signatures) == 0 {
+			return nil, fmt.Errorf("transaction is not signed")
+		}
+
+		txs = append(txs, tx)
+		signatures = append(signatures, tx.Signatures[0].String())
+		hash.Write(tx.Signatures[0][:])
+	}
+
+	bundleId := hex.EncodeToString(hash.Sum(nil))
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if _, ok := e.bundles[bundleId]; ok {
+		return nil, fmt.Errorf("bundle %s already submitted", bundleId)
+	}
+
+	e.bundles[bundleId] = &localBundle{
+		signatures: signatures,
+		status:     e.Outcome(txs),
+	}
+
+	return bundleId, nil
+}
+
+func (e *LocalBlockEngine) getInflightBundleStatuses(params []json.RawMessage) (interface{}, error) {
+	bundleIds, err := bundleIdsParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	value := make([]interface{}, 0, len(bundleIds))
+	for _, bundleId := range bundleIds {
+		bundle, ok := e.bundles[bundleId]
+		if !ok {
+			continue
+		}
+
+		bundle.polls++
+		if bundle.polls <= e.UnindexedPolls {
+			continue
+		}
+		if bundle.polls <= e.UnindexedPolls+e.PendingPolls {
+			value = append(value, map[string]interface{}{"bundle_id": bundleId, "status": "Pending", "landed_slot": nil})
+			continue
+		}
+
+		// Dropped bundles are forgotten, the real block engine reports them as Invalid
+		status := "Invalid"
+		switch bundle.status {
+		case BUNDLE_LANDED:
+			status = "Landed"
+			if bundle.slot == 0 {
+				e.slot++
+				bundle.slot = e.slot
+			}
+		case BUNDLE_FAILED:
+			status = "Failed"
+		}
+
+		var landedSlot interface{}
+		if bundle.slot > 0 {
+			landedSlot = bundle.slot
+		}
+
+		value = append(value, map[string]interface{}{"bundle_id": bundleId, "status": status, "landed_slot": landedSlot})
+	}
+
+	return map[string]interface{}{
+		"context": map[string]uint64{"slot": e.slot},
+		"value":   value,
+	}, nil
+}
+
+func (e *LocalBlockEngine) getBundleStatuses(params []json.RawMessage) (interface{}, error) {
+	bundleIds, err := bundleIdsParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	value := make([]interface{}, 0, len(bundleIds))
+	for _, bundleId := range bundleIds {
+		bundle, ok := e.bundles[bundleId]
+		if !ok || bundle.slot == 0 {
+			value = append(value, nil)
+			continue
+		}
+
+		value = append(value, map[string]interface{}{
+			"bundle_id":           bundleId,
+			"transactions":        bundle.signatures,
+			"slot":                bundle.slot,
+			"confirmation_status": "confirmed",
+			"err":                 map[string]interface{}{"Ok": nil},
+		})
+	}
+
+	return map[string]interface{}{
+		"context": map[string]uint64{"slot": e.slot},
+		"value":   value,
+	}, nil
+}
+
+func bundleIdsParam(params []json.RawMessage) ([]string, error) {
+	if len(params) == 0 {
+		return nil, fmt.Errorf("missing bundle ids")
+	}
+
+	var bundleIds []string
+	if err := json.Unmarshal(params[0], &bundleIds); err != nil {
+		return nil, err
+	}
+
+	return bundleIds, nil
+}
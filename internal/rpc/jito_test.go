@@ -0,0 +1,94 @@
+package rpc
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/config"
+)
+
+func signedTransfer(t *testing.T, to solana.PublicKey) *solana.Transaction {
+	payer := solana.NewWallet()
+
+	tx, err := solana.NewTransaction(
+		[]solana.Instruction{system.NewTransferInstruction(1000, payer.PublicKey(), to).Build()},
+		solana.Hash{1},
+		solana.TransactionPayer(payer.PublicKey()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
+		return &payer.PrivateKey
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tx
+}
+
+func TestWaitForBundle(t *testing.T) {
+	tests := []struct {
+		name           string
+		to             solana.PublicKey
+		outcome        func([]*solana.Transaction) BundleStatus
+		unindexedPolls int
+		pendingPolls   int
+		status         BundleStatus
+	}{
+		{"pending then landed", config.JITO_TIP_ACCOUNTS[0], nil, 0, 3, BUNDLE_LANDED},
+		{"unindexed then landed within the grace", config.JITO_TIP_ACCOUNTS[1], nil, 3, 1, BUNDLE_LANDED},
+		{"untipped is dropped after the grace", solana.NewWallet().PublicKey(), nil, 0, 1, BUNDLE_DROPPED},
+		{"failed", config.JITO_TIP_ACCOUNTS[2], func([]*solana.Transaction) BundleStatus { return BUNDLE_FAILED }, 0, 1, BUNDLE_FAILED},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := NewLocalBlockEngine()
+			engine.UnindexedPolls = tt.unindexedPolls
+			engine.PendingPolls = tt.pendingPolls
+			if tt.outcome != nil {
+				engine.Outcome = tt.outcome
+			}
+
+			server := httptest.NewServer(engine)
+			defer server.Close()
+
+			client := NewJitoClient(server.URL)
+			client.PollInterval = time.Millisecond
+			client.UnknownGrace = 100 * time.Millisecond
+
+			tx := signedTransfer(t, tt.to)
+			bundleId, err := client.SendBundle([]*solana.Transaction{tx})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			result, err := client.WaitForBundle(bundleId)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.Status != tt.status {
+				t.Fatalf("got %s, want %s", result.Status, tt.status)
+			}
+
+			switch tt.status {
+			case BUNDLE_LANDED:
+				if result.Slot == 0 || len(result.Transactions) != 1 || result.Transactions[0] != tx.Signatures[0].String() {
+					t.Errorf("landed bundle without its slot or transactions: %+v", result)
+				}
+			case BUNDLE_DROPPED:
+				if time.Since(start) < client.UnknownGrace {
+					t.Errorf("dropped after %s, before the grace", time.Since(start))
+				}
+			}
+		})
+	}
+}
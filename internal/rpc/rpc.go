@@ -6,14 +6,19 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"math/big"
 	"net/http"
+	"strconv"
 
 	bin "github.com/gagliardetto/binary"
 	"github.com/gagliardetto/solana-go"
 	addresslookuptable "github.com/gagliardetto/solana-go/programs/address-lookup-table"
+	"github.com/gagliardetto/solana-go/programs/token"
 	"github.com/gagliardetto/solana-go/rpc"
 	"github.com/iqbalbaharum/lp-remove-tracker/internal/coder"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/config"
 )
 
 type AccountInfo struct {
@@ -49,6 +54,11 @@ type ResponseBody struct {
 	Error   *RPCError       `json:"error"`
 }
 
+var (
+	ErrAccountNotFound     = errors.New("account not found")
+	ErrTransactionNotFound = errors.New("transaction not found")
+)
+
 type RPCError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -179,6 +189,45 @@ func GetAccountInfo(publicKey solana.PublicKey, dataSlice *rpc.DataSlice) (*Acco
 	return &accountInfo, nil
 }
 
+type MultipleAccounts struct {
+	Value []*AccountInfoValue `json:"value"`
+}
+
+// The most accounts getMultipleAccounts takes in one call
+const MULTIPLE_ACCOUNTS_LIMIT = 100
+
+// GetMultipleAccounts reads up to MULTIPLE_ACCOUNTS_LIMIT accounts in one call, a missing
+// account is nil in the result
+func GetMultipleAccounts(publicKeys []solana.PublicKey) ([]*AccountInfoValue, error) {
+	if len(publicKeys) > MULTIPLE_ACCOUNTS_LIMIT {
+		return nil, fmt.Errorf("%d accounts requested, at most %d", len(publicKeys), MULTIPLE_ACCOUNTS_LIMIT)
+	}
+
+	reqParams := []interface{}{
+		publicKeys,
+		map[string]interface{}{
+			"encoding":   "base64",
+			"commitment": "confirmed",
+		},
+	}
+
+	response, err := CallRPC("getMultipleAccounts", reqParams)
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts MultipleAccounts
+	if err := json.Unmarshal(response.Result, &accounts); err != nil {
+		return nil, err
+	}
+
+	if len(accounts.Value) != len(publicKeys) {
+		return nil, fmt.Errorf("%d accounts returned for %d requested", len(accounts.Value), len(publicKeys))
+	}
+
+	return accounts.Value, nil
+}
+
 func GetBalance(publicKey solana.PublicKey) (uint64, error) {
 	params := map[string]interface{}{
 		"commitment": "processed",
@@ -202,6 +251,102 @@ func GetBalance(publicKey solana.PublicKey) (uint64, error) {
 	return balance.Value, nil
 }
 
+type TokenAccountBalance struct {
+	Context struct {
+		Slot uint64 `json:"slot"`
+	} `json:"context"`
+	Value struct {
+		Amount   string `json:"amount"`
+		Decimals uint8  `json:"decimals"`
+	} `json:"value"`
+}
+
+// GetTokenAccountBalance returns the raw token amount held by a token account and the slot it was read at
+func GetTokenAccountBalance(publicKey solana.PublicKey) (uint64, uint64, error) {
+	params := map[string]interface{}{
+		"commitment": "processed",
+	}
+
+	reqParams := []interface{}{
+		publicKey,
+		params,
+	}
+
+	response, err := CallRPC("getTokenAccountBalance", reqParams)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var balance TokenAccountBalance
+	if err := json.Unmarshal(response.Result, &balance); err != nil {
+		return 0, 0, err
+	}
+
+	amount, err := strconv.ParseUint(balance.Value.Amount, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return amount, balance.Context.Slot, nil
+}
+
+// GetTokenSupply returns the mint's supply and the slot it was read at
+func GetTokenSupply(mint solana.PublicKey) (uint64, uint64, error) {
+	params := map[string]interface{}{
+		"commitment": "processed",
+	}
+
+	reqParams := []interface{}{
+		mint,
+		params,
+	}
+
+	response, err := CallRPC("getTokenSupply", reqParams)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var supply TokenAccountBalance
+	if err := json.Unmarshal(response.Result, &supply); err != nil {
+		return 0, 0, err
+	}
+
+	amount, err := strconv.ParseUint(supply.Value.Amount, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return amount, supply.Context.Slot, nil
+}
+
+// GetMint decodes an SPL Token or Token-2022 mint, extensions past the base layout are ignored
+func GetMint(mint solana.PublicKey) (*token.Mint, error) {
+	resp, err := GetAccountInfo(mint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp == nil || resp.Value == nil || len(resp.Value.Data) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrAccountNotFound, mint)
+	}
+
+	if resp.Value.Owner != solana.TokenProgramID.String() && resp.Value.Owner != solana.Token2022ProgramID.String() {
+		return nil, fmt.Errorf("%w: %s is owned by %s", coder.ErrWrongOwner, mint, resp.Value.Owner)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(resp.Value.Data[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var state token.Mint
+	if err := state.UnmarshalWithDecoder(bin.NewBinDecoder(data)); err != nil {
+		return nil, fmt.Errorf("%s: %w", mint, err)
+	}
+
+	return &state, nil
+}
+
 func GetLookupTable(addr solana.PublicKey) (addresslookuptable.AddressLookupTableState, error) {
 	resp, err := GetAccountInfo(addr, nil)
 
@@ -232,49 +377,322 @@ func GetLookupTable(addr solana.PublicKey) (addresslookuptable.AddressLookupTabl
 // Liquidity State
 
 func GetLiquidityState(ammId *solana.PublicKey) (*coder.LiquidityState, error) {
-	resp, err := GetAccountInfo(*ammId, nil)
+	data, err := getOwnedAccountData(*ammId, config.RAYDIUM_AMM_V4)
+	if err != nil {
+		return nil, err
+	}
 
 	c := coder.NewRaydiumLiquidityCoder()
 
-	if resp == nil || resp.Value == nil {
-		return &coder.LiquidityState{}, nil
+	state, err := c.RaydiumLiquidityDecode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ammId, err)
 	}
 
-	// Decode base64 encoded data
-	data, err := base64.StdEncoding.DecodeString(resp.Value.Data[0])
+	return &state, nil
+}
 
+// GetLiquidityStates reads the pools' states in batches of MULTIPLE_ACCOUNTS_LIMIT. The
+// states and errors line up with ammIds, a pool fails on its own with the error
+// GetLiquidityState would return and a failed batch fails each of its pools.
+func GetLiquidityStates(ammIds []solana.PublicKey) ([]*coder.LiquidityState, []error) {
+	states := make([]*coder.LiquidityState, len(ammIds))
+	errs := make([]error, len(ammIds))
+
+	c := coder.NewRaydiumLiquidityCoder()
+
+	for start := 0; start < len(ammIds); start += MULTIPLE_ACCOUNTS_LIMIT {
+		end := min(start+MULTIPLE_ACCOUNTS_LIMIT, len(ammIds))
+
+		accounts, err := GetMultipleAccounts(ammIds[start:end])
+		if err != nil {
+			for i := start; i < end; i++ {
+				errs[i] = err
+			}
+			continue
+		}
+
+		for i, account := range accounts {
+			ammId := ammIds[start+i]
+
+			data, err := ownedAccountData(ammId, account, config.RAYDIUM_AMM_V4)
+			if err != nil {
+				errs[start+i] = err
+				continue
+			}
+
+			state, err := c.RaydiumLiquidityDecode(data)
+			if err != nil {
+				errs[start+i] = fmt.Errorf("%s: %w", ammId, err)
+				continue
+			}
+			states[start+i] = &state
+		}
+	}
+
+	return states, errs
+}
+
+// GetMarketStates reads the markets owned by their programs the way GetLiquidityStates
+// reads pools, the states and errors line up with marketIds
+func GetMarketStates(marketIds []solana.PublicKey, marketProgramIds []solana.PublicKey) ([]*coder.MarketStateLayoutV3, []error) {
+	states := make([]*coder.MarketStateLayoutV3, len(marketIds))
+	errs := make([]error, len(marketIds))
+
+	c := coder.NewRaydiumMarketCoder()
+
+	for start := 0; start < len(marketIds); start += MULTIPLE_ACCOUNTS_LIMIT {
+		end := min(start+MULTIPLE_ACCOUNTS_LIMIT, len(marketIds))
+
+		accounts, err := GetMultipleAccounts(marketIds[start:end])
+		if err != nil {
+			for i := start; i < end; i++ {
+				errs[i] = err
+			}
+			continue
+		}
+
+		for i, account := range accounts {
+			marketId := marketIds[start+i]
+
+			data, err := ownedAccountData(marketId, account, marketProgramIds[start+i])
+			if err != nil {
+				errs[start+i] = err
+				continue
+			}
+
+			state, err := c.RaydiumMarketDecode(data)
+			if err != nil {
+				errs[start+i] = fmt.Errorf("%s: %w", marketId, err)
+				continue
+			}
+			states[start+i] = &state
+		}
+	}
+
+	return states, errs
+}
+
+func GetMarketState(marketId *solana.PublicKey, marketProgramId solana.PublicKey) (*coder.MarketStateLayoutV3, error) {
+	data, err := getOwnedAccountData(*marketId, marketProgramId)
 	if err != nil {
-		return &coder.LiquidityState{}, err
+		return nil, err
 	}
 
-	state, err := c.RaydiumLiquidityDecode(data)
+	c := coder.NewRaydiumMarketCoder()
+
+	state, err := c.RaydiumMarketDecode(data)
 	if err != nil {
-		return &coder.LiquidityState{}, err
+		return nil, fmt.Errorf("%s: %w", marketId, err)
 	}
 
 	return &state, nil
 }
 
-func GetMarketState(marketId *solana.PublicKey) (*coder.MarketStateLayoutV3, error) {
-	resp, err := GetAccountInfo(*marketId, nil)
+// getOwnedAccountData fetches the account and returns its decoded data, failing
+// when the account does not exist or is not owned by the expected program
+func getOwnedAccountData(addr solana.PublicKey, owner solana.PublicKey) ([]byte, error) {
+	resp, err := GetAccountInfo(addr, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp == nil {
+		return nil, fmt.Errorf("%w: %s", ErrAccountNotFound, addr)
+	}
 
-	c := coder.NewRaydiumMarketCoder()
+	return ownedAccountData(addr, resp.Value, owner)
+}
 
-	if resp == nil || resp.Value == nil {
-		return &coder.MarketStateLayoutV3{}, nil
+func ownedAccountData(addr solana.PublicKey, account *AccountInfoValue, owner solana.PublicKey) ([]byte, error) {
+	if account == nil || len(account.Data) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrAccountNotFound, addr)
+	}
+
+	if account.Owner != owner.String() {
+		return nil, fmt.Errorf("%w: %s is owned by %s, expected %s", coder.ErrWrongOwner, addr, account.Owner, owner)
 	}
 
 	// Decode base64 encoded data
-	data, err := base64.StdEncoding.DecodeString(resp.Value.Data[0])
+	data, err := base64.StdEncoding.DecodeString(account.Data[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
 
+// Transactions
+
+// SendTransaction submits a signed transaction without preflight, retries are left to the caller
+func SendTransaction(tx *solana.Transaction) (solana.Signature, error) {
+	data, err := tx.MarshalBinary()
 	if err != nil {
-		return &coder.MarketStateLayoutV3{}, err
+		return solana.Signature{}, err
 	}
 
-	state, err := c.RaydiumMarketDecode(data)
+	params := []interface{}{
+		base64.StdEncoding.EncodeToString(data),
+		map[string]interface{}{
+			"encoding":      "base64",
+			"skipPreflight": true,
+			"maxRetries":    0,
+		},
+	}
+
+	response, err := CallRPC("sendTransaction", params)
 	if err != nil {
-		return &coder.MarketStateLayoutV3{}, err
+		return solana.Signature{}, err
 	}
 
-	return &state, nil
+	var signature string
+	if err := json.Unmarshal(response.Result, &signature); err != nil {
+		return solana.Signature{}, err
+	}
+
+	return solana.SignatureFromBase58(signature)
+}
+
+type SignatureStatus struct {
+	Slot               uint64          `json:"slot"`
+	Confirmations      *uint64         `json:"confirmations"`
+	Err                json.RawMessage `json:"err"`
+	ConfirmationStatus string          `json:"confirmationStatus"`
+}
+
+type signatureStatusesResult struct {
+	Value []*SignatureStatus `json:"value"`
+}
+
+// GetSignatureStatuses returns the status of each signature, nil for signatures the node has not seen
+func GetSignatureStatuses(signatures []solana.Signature) ([]*SignatureStatus, error) {
+	encoded := make([]string, len(signatures))
+	for i, signature := range signatures {
+		encoded[i] = signature.String()
+	}
+
+	params := []interface{}{
+		encoded,
+		map[string]interface{}{
+			"searchTransactionHistory": false,
+		},
+	}
+
+	response, err := CallRPC("getSignatureStatuses", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var result signatureStatusesResult
+	if err := json.Unmarshal(response.Result, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Value, nil
+}
+
+func IsBlockhashValid(blockhash solana.Hash) (bool, error) {
+	params := []interface{}{
+		blockhash.String(),
+		map[string]interface{}{
+			"commitment": "processed",
+		},
+	}
+
+	response, err := CallRPC("isBlockhashValid", params)
+	if err != nil {
+		return false, err
+	}
+
+	var result struct {
+		Value bool `json:"value"`
+	}
+	if err := json.Unmarshal(response.Result, &result); err != nil {
+		return false, err
+	}
+
+	return result.Value, nil
+}
+
+type TransactionTokenBalance struct {
+	AccountIndex  uint32 `json:"accountIndex"`
+	Mint          string `json:"mint"`
+	Owner         string `json:"owner"`
+	UiTokenAmount struct {
+		Amount string `json:"amount"`
+	} `json:"uiTokenAmount"`
+}
+
+type TransactionMeta struct {
+	Err               json.RawMessage           `json:"err"`
+	Fee               uint64                    `json:"fee"`
+	PreBalances       []uint64                  `json:"preBalances"`
+	PostBalances      []uint64                  `json:"postBalances"`
+	PreTokenBalances  []TransactionTokenBalance `json:"preTokenBalances"`
+	PostTokenBalances []TransactionTokenBalance `json:"postTokenBalances"`
+}
+
+type TransactionResult struct {
+	Slot      uint64          `json:"slot"`
+	BlockTime *int64          `json:"blockTime"`
+	Meta      TransactionMeta `json:"meta"`
+}
+
+// GetTransaction returns the slot, block time and balance changes of a confirmed transaction
+func GetTransaction(signature solana.Signature) (*TransactionResult, error) {
+	params := []interface{}{
+		signature.String(),
+		map[string]interface{}{
+			"encoding":                       "json",
+			"commitment":                     "confirmed",
+			"maxSupportedTransactionVersion": 0,
+		},
+	}
+
+	response, err := CallRPC("getTransaction", params)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(response.Result) == "null" {
+		return nil, fmt.Errorf("%w: %s", ErrTransactionNotFound, signature)
+	}
+
+	var result TransactionResult
+	if err := json.Unmarshal(response.Result, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// TokenBalanceChange is the post minus pre amount of mint held by owner in the transaction.
+// Amounts are u64, so the change is summed as a big.Int.
+func (m *TransactionMeta) TokenBalanceChange(owner solana.PublicKey, mint solana.PublicKey) (*big.Int, error) {
+	sum := func(balances []TransactionTokenBalance) (*big.Int, error) {
+		total := new(big.Int)
+		for _, balance := range balances {
+			if balance.Owner != owner.String() || balance.Mint != mint.String() {
+				continue
+			}
+			amount, err := strconv.ParseUint(balance.UiTokenAmount.Amount, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s balance %q: %w", mint, balance.UiTokenAmount.Amount, err)
+			}
+			total.Add(total, new(big.Int).SetUint64(amount))
+		}
+		return total, nil
+	}
+
+	post, err := sum(m.PostTokenBalances)
+	if err != nil {
+		return nil, err
+	}
+
+	pre, err := sum(m.PreTokenBalances)
+	if err != nil {
+		return nil, err
+	}
+
+	return post.Sub(post, pre), nil
 }
@@ -0,0 +1,160 @@
+package rpc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/coder"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/config"
+)
+
+var ErrSimulationFailed = errors.New("simulation failed")
+
+type SimulationResult struct {
+	// Err is nil when the transaction would succeed, otherwise a *SimulationError
+	Err               error
+	Logs              []string
+	UnitsConsumed     uint64
+	Accounts          []*AccountInfoValue
+	InnerInstructions json.RawMessage
+}
+
+// SimulationError is the transaction error reported by the simulation. Raydium custom errors
+// unwrap to a coder.AmmError so callers can use errors.Is(err, coder.AMM_ERROR_EXCEEDED_SLIPPAGE).
+type SimulationError struct {
+	Raw string
+	// InstructionIndex is -1 for errors that are not raised by an instruction
+	InstructionIndex int
+	ProgramId        solana.PublicKey
+	Custom           *uint32
+	cause            error
+}
+
+func (e *SimulationError) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: instruction %d: %v", ErrSimulationFailed, e.InstructionIndex, e.cause)
+	}
+	return fmt.Sprintf("%s: %s", ErrSimulationFailed, e.Raw)
+}
+
+func (e *SimulationError) Unwrap() []error {
+	if e.cause != nil {
+		return []error{ErrSimulationFailed, e.cause}
+	}
+	return []error{ErrSimulationFailed}
+}
+
+type simulateTransactionResult struct {
+	Value struct {
+		Err               json.RawMessage     `json:"err"`
+		Logs              []string            `json:"logs"`
+		Accounts          []*AccountInfoValue `json:"accounts"`
+		UnitsConsumed     uint64              `json:"unitsConsumed"`
+		InnerInstructions json.RawMessage     `json:"innerInstructions"`
+	} `json:"value"`
+}
+
+// SimulateTransaction runs the transaction against the latest blockhash without checking signatures,
+// returning inner instructions and the post-simulation state of accounts
+func SimulateTransaction(tx *solana.Transaction, accounts []solana.PublicKey) (*SimulationResult, error) {
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	options := map[string]interface{}{
+		"encoding":               "base64",
+		"commitment":             "processed",
+		"sigVerify":              false,
+		"replaceRecentBlockhash": true,
+		"innerInstructions":      true,
+	}
+
+	if len(accounts) > 0 {
+		addresses := make([]string, len(accounts))
+		for i, account := range accounts {
+			addresses[i] = account.String()
+		}
+
+		options["accounts"] = map[string]interface{}{
+			"encoding":  "base64",
+			"addresses": addresses,
+		}
+	}
+
+	params := []interface{}{
+		base64.StdEncoding.EncodeToString(data),
+		options,
+	}
+
+	response, err := CallRPC("simulateTransaction", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var result simulateTransactionResult
+	if err := json.Unmarshal(response.Result, &result); err != nil {
+		return nil, err
+	}
+
+	simulation := &SimulationResult{
+		Logs:              result.Value.Logs,
+		UnitsConsumed:     result.Value.UnitsConsumed,
+		Accounts:          result.Value.Accounts,
+		InnerInstructions: result.Value.InnerInstructions,
+	}
+
+	if len(result.Value.Err) > 0 && string(result.Value.Err) != "null" {
+		simulation.Err = parseSimulationError(tx, result.Value.Err)
+	}
+
+	return simulation, nil
+}
+
+// Transaction errors come as a plain string, e.g. "BlockhashNotFound", or as
+// {"InstructionError":[index, "GenericError" | {"Custom":code}]}
+func parseSimulationError(tx *solana.Transaction, raw json.RawMessage) *SimulationError {
+	simErr := &SimulationError{Raw: string(raw), InstructionIndex: -1}
+
+	var txErr struct {
+		InstructionError []json.RawMessage `json:"InstructionError"`
+	}
+	if err := json.Unmarshal(raw, &txErr); err != nil || len(txErr.InstructionError) != 2 {
+		return simErr
+	}
+
+	if err := json.Unmarshal(txErr.InstructionError[0], &simErr.InstructionIndex); err != nil {
+		simErr.InstructionIndex = -1
+		return simErr
+	}
+
+	if simErr.InstructionIndex >= 0 && simErr.InstructionIndex < len(tx.Message.Instructions) {
+		programIndex := tx.Message.Instructions[simErr.InstructionIndex].ProgramIDIndex
+		if int(programIndex) < len(tx.Message.AccountKeys) {
+			simErr.ProgramId = tx.Message.AccountKeys[programIndex]
+		}
+	}
+
+	var custom struct {
+		Custom *uint32 `json:"Custom"`
+	}
+	if err := json.Unmarshal(txErr.InstructionError[1], &custom); err == nil && custom.Custom != nil {
+		simErr.Custom = custom.Custom
+		if simErr.ProgramId == config.RAYDIUM_AMM_V4 {
+			simErr.cause = coder.AmmError(*custom.Custom)
+		} else {
+			simErr.cause = fmt.Errorf("program %s custom error %d", simErr.ProgramId, *custom.Custom)
+		}
+		return simErr
+	}
+
+	var name string
+	if err := json.Unmarshal(txErr.InstructionError[1], &name); err == nil {
+		simErr.cause = fmt.Errorf("program %s: %s", simErr.ProgramId, name)
+	}
+
+	return simErr
+}
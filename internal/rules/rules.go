@@ -0,0 +1,298 @@
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type Action string
+
+const (
+	ACTION_TRACK  Action = "track"
+	ACTION_IGNORE Action = "ignore"
+)
+
+// Inputs a condition can test
+const (
+	FIELD_RESERVE_LAMPORTS      = "reserve_lamports"
+	FIELD_LP_WITHDRAWN_BPS      = "lp_withdrawn_bps"
+	FIELD_POOL_AGE_SECONDS      = "pool_age_seconds"
+	FIELD_WITHDRAWER_IS_CREATOR = "withdrawer_is_creator"
+	FIELD_MINT_AUTHORITY_SET    = "mint_authority_set"
+	FIELD_FREEZE_AUTHORITY_SET  = "freeze_authority_set"
+	FIELD_LP_STATUS             = "lp_status"
+	FIELD_LP_UNLOCK_IN_SECONDS  = "lp_unlock_in_seconds"
+)
+
+var numericFields = map[string]bool{
+	FIELD_RESERVE_LAMPORTS:     true,
+	FIELD_LP_WITHDRAWN_BPS:     true,
+	FIELD_POOL_AGE_SECONDS:     true,
+	FIELD_LP_UNLOCK_IN_SECONDS: true,
+}
+
+var boolFields = map[string]bool{
+	FIELD_WITHDRAWER_IS_CREATOR: true,
+	FIELD_MINT_AUTHORITY_SET:    true,
+	FIELD_FREEZE_AUTHORITY_SET:  true,
+}
+
+var stringFields = map[string]bool{
+	FIELD_LP_STATUS: true,
+}
+
+var numericOps = map[string]func(a, b uint64) bool{
+	"lt":  func(a, b uint64) bool { return a < b },
+	"lte": func(a, b uint64) bool { return a <= b },
+	"gt":  func(a, b uint64) bool { return a > b },
+	"gte": func(a, b uint64) bool { return a >= b },
+	"eq":  func(a, b uint64) bool { return a == b },
+	"neq": func(a, b uint64) bool { return a != b },
+}
+
+var ErrInvalidRule = errors.New("invalid rule")
+
+// Condition compares one input against a value, e.g. {"field": "reserve_lamports", "op": "lte", "value": 1000000000}.
+// Numeric fields take lt, lte, gt, gte, eq and neq, boolean and string fields eq and neq.
+type Condition struct {
+	Field string          `json:"field"`
+	Op    string          `json:"op"`
+	Value json.RawMessage `json:"value"`
+
+	number  uint64
+	boolean bool
+	text    string
+}
+
+// Rule matches when all of its All conditions and, if it has any, one of its Any conditions hold
+type Rule struct {
+	Name   string      `json:"name"`
+	All    []Condition `json:"all"`
+	Any    []Condition `json:"any"`
+	Action Action      `json:"action"`
+}
+
+// RuleSet is evaluated in order, the first matching rule decides and Default applies when none do
+type RuleSet struct {
+	Rules   []Rule `json:"rules"`
+	Default Action `json:"default"`
+}
+
+// DefaultWithdrawRules tracks a pool left with at most 1 SOL, as the bot always has
+func DefaultWithdrawRules() *RuleSet {
+	rules := &RuleSet{
+		Rules: []Rule{
+			{
+				Name:   "low-reserve",
+				All:    []Condition{{Field: FIELD_RESERVE_LAMPORTS, Op: "lte", Value: json.RawMessage("1000000000")}},
+				Action: ACTION_TRACK,
+			},
+		},
+		Default: ACTION_IGNORE,
+	}
+
+	if err := rules.compile(); err != nil {
+		panic(err)
+	}
+
+	return rules
+}
+
+func LoadRules(path string) (*RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules RuleSet
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	if err := rules.compile(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &rules, nil
+}
+
+// Check every rule and parse the condition values once
+func (r *RuleSet) compile() error {
+	if r.Default == "" {
+		r.Default = ACTION_IGNORE
+	}
+	if !validAction(r.Default) {
+		return fmt.Errorf("%w: default action %q", ErrInvalidRule, r.Default)
+	}
+
+	names := make(map[string]bool)
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("%w: duplicate rule name %s", ErrInvalidRule, rule.Name)
+		}
+		names[rule.Name] = true
+
+		if !validAction(rule.Action) {
+			return fmt.Errorf("%w: %s has action %q", ErrInvalidRule, rule.Name, rule.Action)
+		}
+		if len(rule.All) == 0 && len(rule.Any) == 0 {
+			return fmt.Errorf("%w: %s has no conditions", ErrInvalidRule, rule.Name)
+		}
+
+		for _, conditions := range [][]Condition{rule.All, rule.Any} {
+			for j := range conditions {
+				if err := conditions[j].compile(); err != nil {
+					return fmt.Errorf("%w: %s: %v", ErrInvalidRule, rule.Name, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *Condition) compile() error {
+	switch {
+	case numericFields[c.Field]:
+		if _, ok := numericOps[c.Op]; !ok {
+			return fmt.Errorf("%s does not take %q", c.Field, c.Op)
+		}
+		if err := json.Unmarshal(c.Value, &c.number); err != nil {
+			return fmt.Errorf("%s needs an unsigned integer: %v", c.Field, err)
+		}
+	case boolFields[c.Field]:
+		if c.Op != "eq" && c.Op != "neq" {
+			return fmt.Errorf("%s does not take %q", c.Field, c.Op)
+		}
+		if err := json.Unmarshal(c.Value, &c.boolean); err != nil {
+			return fmt.Errorf("%s needs a boolean: %v", c.Field, err)
+		}
+	case stringFields[c.Field]:
+		if c.Op != "eq" && c.Op != "neq" {
+			return fmt.Errorf("%s does not take %q", c.Field, c.Op)
+		}
+		if err := json.Unmarshal(c.Value, &c.text); err != nil {
+			return fmt.Errorf("%s needs a string: %v", c.Field, err)
+		}
+	default:
+		return fmt.Errorf("unknown field %q", c.Field)
+	}
+
+	return nil
+}
+
+func validAction(action Action) bool {
+	return action == ACTION_TRACK || action == ACTION_IGNORE
+}
+
+// Needs reports whether any rule reads the field, so inputs that cost an RPC call are only
+// fetched when used
+func (r *RuleSet) Needs(field string) bool {
+	for _, rule := range r.Rules {
+		for _, conditions := range [][]Condition{rule.All, rule.Any} {
+			for _, c := range conditions {
+				if c.Field == field {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Input holds the value of each field, a field left out is unknown and fails its conditions
+type Input map[string]interface{}
+
+func (in Input) String() string {
+	parts := make([]string, 0, len(in))
+	for _, field := range []string{FIELD_RESERVE_LAMPORTS, FIELD_LP_WITHDRAWN_BPS, FIELD_POOL_AGE_SECONDS, FIELD_WITHDRAWER_IS_CREATOR, FIELD_MINT_AUTHORITY_SET, FIELD_FREEZE_AUTHORITY_SET, FIELD_LP_STATUS, FIELD_LP_UNLOCK_IN_SECONDS} {
+		if value, ok := in[field]; ok {
+			parts = append(parts, fmt.Sprintf("%s=%v", field, value))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Decision is the action taken and every rule's outcome up to the one that matched
+type Decision struct {
+	Action  Action
+	Rule    string
+	Results []RuleResult
+}
+
+type RuleResult struct {
+	Rule    string
+	Matched bool
+}
+
+func (d Decision) String() string {
+	parts := make([]string, 0, len(d.Results))
+	for _, result := range d.Results {
+		parts = append(parts, fmt.Sprintf("%s=%t", result.Rule, result.Matched))
+	}
+
+	rule := d.Rule
+	if rule == "" {
+		rule = "default"
+	}
+
+	return fmt.Sprintf("%s by %s [%s]", d.Action, rule, strings.Join(parts, " "))
+}
+
+func (r *RuleSet) Evaluate(in Input) Decision {
+	decision := Decision{Action: r.Default}
+
+	for _, rule := range r.Rules {
+		matched := rule.matches(in)
+		decision.Results = append(decision.Results, RuleResult{Rule: rule.Name, Matched: matched})
+
+		if matched {
+			decision.Action = rule.Action
+			decision.Rule = rule.Name
+			break
+		}
+	}
+
+	return decision
+}
+
+func (rule *Rule) matches(in Input) bool {
+	for _, c := range rule.All {
+		if !c.matches(in) {
+			return false
+		}
+	}
+
+	if len(rule.Any) == 0 {
+		return true
+	}
+
+	for _, c := range rule.Any {
+		if c.matches(in) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Condition) matches(in Input) bool {
+	switch value := in[c.Field].(type) {
+	case uint64:
+		return numericOps[c.Op](value, c.number)
+	case bool:
+		return (value == c.boolean) == (c.Op == "eq")
+	case string:
+		return (value == c.text) == (c.Op == "eq")
+	default:
+		return false
+	}
+}
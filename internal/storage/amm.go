@@ -0,0 +1,275 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
+)
+
+// LP statuses, ordered from the weakest. A pool's status only moves to a stronger one.
+const (
+	LP_STATUS_CREATOR types.LpStatus = "creator"
+	LP_STATUS_LOCKED  types.LpStatus = "locked"
+	LP_STATUS_BURNED  types.LpStatus = "burned"
+)
+
+// Reported, never stored. Unknown is a pool with no recorded LP status, unlocked a lock
+// past its unlock time.
+const (
+	LP_STATUS_UNKNOWN  types.LpStatus = "unknown"
+	LP_STATUS_UNLOCKED types.LpStatus = "unlocked"
+)
+
+// The statuses as MySQL's FIELD() ranks them, a NULL status ranks 0
+const lpStatusRank = `'creator', 'locked', 'burned'`
+
+type AmmStorage struct {
+	client *sql.DB
+}
+
+func NewAmmStorage(db *sql.DB) *AmmStorage {
+	return &AmmStorage{client: db}
+}
+
+// SetAmm upserts the pool, keeping stored values for the fields the amm leaves nil. The first
+// slot the pool was seen at is never moved.
+func (s *AmmStorage) SetAmm(amm *types.Amm, now int64) error {
+	query := `
+			INSERT INTO ` + TABLE_NAME_AMM + ` (amm_id, baseMint, quoteMint, lp_mint, base_vault, quote_vault, market_id, market_program_id,
+				base_decimals, quote_decimals, lp_decimals, open_time, init_pc_amount, init_coin_amount, creator, first_seen_slot, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE
+				baseMint = COALESCE(VALUES(baseMint), baseMint),
+				quoteMint = COALESCE(VALUES(quoteMint), quoteMint),
+				lp_mint = COALESCE(VALUES(lp_mint), lp_mint),
+				base_vault = COALESCE(VALUES(base_vault), base_vault),
+				quote_vault = COALESCE(VALUES(quote_vault), quote_vault),
+				market_id = COALESCE(VALUES(market_id), market_id),
+				market_program_id = COALESCE(VALUES(market_program_id), market_program_id),
+				base_decimals = COALESCE(VALUES(base_decimals), base_decimals),
+				quote_decimals = COALESCE(VALUES(quote_decimals), quote_decimals),
+				lp_decimals = COALESCE(VALUES(lp_decimals), lp_decimals),
+				open_time = COALESCE(VALUES(open_time), open_time),
+				init_pc_amount = COALESCE(VALUES(init_pc_amount), init_pc_amount),
+				init_coin_amount = COALESCE(VALUES(init_coin_amount), init_coin_amount),
+				creator = COALESCE(VALUES(creator), creator),
+				first_seen_slot = COALESCE(first_seen_slot, VALUES(first_seen_slot))
+		`
+	_, err := s.client.Exec(
+		query,
+		amm.AmmId.String(),
+		nullKey(amm.BaseMint),
+		nullKey(amm.QuoteMint),
+		nullKey(amm.LpMint),
+		nullKey(amm.BaseVault),
+		nullKey(amm.QuoteVault),
+		nullKey(amm.MarketId),
+		nullKey(amm.MarketProgramId),
+		amm.BaseDecimals,
+		amm.QuoteDecimals,
+		amm.LpDecimals,
+		amm.OpenTime,
+		amm.InitPcAmount,
+		amm.InitCoinAmount,
+		nullKey(amm.Creator),
+		amm.FirstSeenSlot,
+		now,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to upsert amm %s: %w", amm.AmmId, err)
+	}
+
+	return nil
+}
+
+// SetAmmReserve snapshots the vault balances, dropping snapshots older than the stored one.
+// reserve_slot is assigned last as MySQL evaluates the assignments in order.
+func (s *AmmStorage) SetAmmReserve(ammId solana.PublicKey, baseMint solana.PublicKey, quoteMint solana.PublicKey, base uint64, quote uint64, slot uint64, now int64) error {
+	query := `
+			INSERT INTO ` + TABLE_NAME_AMM + ` (amm_id, baseMint, quoteMint, base, quote, reserve_slot, reserve_updated_at, first_seen_slot, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE
+				base = IF(VALUES(reserve_slot) >= COALESCE(reserve_slot, 0), VALUES(base), base),
+				quote = IF(VALUES(reserve_slot) >= COALESCE(reserve_slot, 0), VALUES(quote), quote),
+				reserve_updated_at = IF(VALUES(reserve_slot) >= COALESCE(reserve_slot, 0), VALUES(reserve_updated_at), reserve_updated_at),
+				first_seen_slot = COALESCE(first_seen_slot, VALUES(first_seen_slot)),
+				reserve_slot = GREATEST(COALESCE(reserve_slot, 0), VALUES(reserve_slot))
+		`
+	_, err := s.client.Exec(query, ammId.String(), baseMint.String(), quoteMint.String(), base, quote, slot, now, slot, now)
+	if err != nil {
+		return fmt.Errorf("failed to snapshot reserve of %s: %w", ammId, err)
+	}
+
+	return nil
+}
+
+func (s *AmmStorage) SetAmmStatus(ammId solana.PublicKey, status string, now int64) error {
+	query := `
+			INSERT INTO ` + TABLE_NAME_AMM + ` (amm_id, status, status_updated_at, created_at)
+			VALUES (?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE status = VALUES(status), status_updated_at = VALUES(status_updated_at)
+		`
+	if _, err := s.client.Exec(query, ammId.String(), status, now, now); err != nil {
+		return fmt.Errorf("failed to set status of %s: %w", ammId, err)
+	}
+
+	return nil
+}
+
+// GetAmmCreator returns the wallet that initialized the pool, nil when it was never seen
+func (s *AmmStorage) GetAmmCreator(ammId solana.PublicKey) (*solana.PublicKey, error) {
+	var creator sql.NullString
+
+	err := s.client.QueryRow(`SELECT creator FROM `+TABLE_NAME_AMM+` WHERE amm_id = ?`, ammId.String()).Scan(&creator)
+	if err == sql.ErrNoRows || (err == nil && !creator.Valid) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get creator of %s: %w", ammId, err)
+	}
+
+	key, err := solana.PublicKeyFromBase58(creator.String)
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// SetAmmLpStatus records the LP status unless the pool already has a stronger one, a lock past
+// its unlock time no longer counts. MySQL evaluates the assignments in order, so lp_status and
+// lp_unlock_time come last and the unlock time follows whether the signature was taken.
+func (s *AmmStorage) SetAmmLpStatus(ammId solana.PublicKey, state types.LpState, now int64) error {
+	stronger := `(FIELD(VALUES(lp_status), ` + lpStatusRank + `) >= FIELD(lp_status, ` + lpStatusRank + `)
+				OR (lp_status = '` + string(LP_STATUS_LOCKED) + `' AND lp_unlock_time <= VALUES(lp_status_updated_at)))`
+	taken := `(lp_status_signature <=> VALUES(lp_status_signature) AND lp_status_slot <=> VALUES(lp_status_slot))`
+	query := `
+			INSERT INTO ` + TABLE_NAME_AMM + ` (amm_id, lp_status, lp_unlock_time, lp_status_signature, lp_status_slot, lp_status_updated_at, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE
+				lp_status_signature = IF(` + stronger + `, VALUES(lp_status_signature), lp_status_signature),
+				lp_status_slot = IF(` + stronger + `, VALUES(lp_status_slot), lp_status_slot),
+				lp_status_updated_at = IF(` + stronger + `, VALUES(lp_status_updated_at), lp_status_updated_at),
+				lp_status = IF(` + stronger + `, VALUES(lp_status), lp_status),
+				lp_unlock_time = IF(` + taken + `, VALUES(lp_unlock_time), lp_unlock_time)
+		`
+	_, err := s.client.Exec(query, ammId.String(), state.Status, state.UnlockTime, state.Signature, state.Slot, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to set LP status of %s: %w", ammId, err)
+	}
+
+	return nil
+}
+
+// DropAmmLpCreatorStatus forgets the creator status once the creator moved the LP away, a
+// stronger status is kept
+func (s *AmmStorage) DropAmmLpCreatorStatus(ammId solana.PublicKey, signature string, slot uint64, now int64) error {
+	query := `
+			UPDATE ` + TABLE_NAME_AMM + `
+			SET lp_status = NULL, lp_unlock_time = NULL, lp_status_signature = ?, lp_status_slot = ?, lp_status_updated_at = ?
+			WHERE amm_id = ? AND lp_status = ?
+		`
+	_, err := s.client.Exec(query, signature, slot, now, ammId.String(), LP_STATUS_CREATOR)
+	if err != nil {
+		return fmt.Errorf("failed to drop LP creator status of %s: %w", ammId, err)
+	}
+
+	return nil
+}
+
+// GetAmmLpStatus returns the pool's LP state as of now, nil when none was recorded. A lock
+// past its unlock time is reported unlocked.
+func (s *AmmStorage) GetAmmLpStatus(ammId solana.PublicKey, now int64) (*types.LpState, error) {
+	var (
+		status     sql.NullString
+		unlockTime sql.NullInt64
+		signature  sql.NullString
+		slot       sql.NullInt64
+	)
+
+	query := `SELECT lp_status, lp_unlock_time, lp_status_signature, lp_status_slot FROM ` + TABLE_NAME_AMM + ` WHERE amm_id = ?`
+	err := s.client.QueryRow(query, ammId.String()).Scan(&status, &unlockTime, &signature, &slot)
+	if err == sql.ErrNoRows || (err == nil && !status.Valid) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get LP status of %s: %w", ammId, err)
+	}
+
+	state := &types.LpState{
+		Status:    types.LpStatus(status.String),
+		Signature: signature.String,
+		Slot:      uint64(slot.Int64),
+	}
+	if unlockTime.Valid {
+		state.UnlockTime = &unlockTime.Int64
+	}
+
+	if state.Status == LP_STATUS_LOCKED && state.UnlockTime != nil && *state.UnlockTime <= now {
+		state.Status = LP_STATUS_UNLOCKED
+	}
+
+	return state, nil
+}
+
+// GetLpMints returns the LP mints of the most recently seen pools whose LP can still move,
+// burned LP is final
+func (s *AmmStorage) GetLpMints(limit int) ([]solana.PublicKey, error) {
+	query := `
+			SELECT lp_mint FROM ` + TABLE_NAME_AMM + `
+			WHERE lp_mint IS NOT NULL AND (lp_status IS NULL OR lp_status <> ?)
+			ORDER BY first_seen_slot DESC
+			LIMIT ?
+		`
+	rows, err := s.client.Query(query, LP_STATUS_BURNED, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list LP mints: %w", err)
+	}
+	defer rows.Close()
+
+	var mints []solana.PublicKey
+	for rows.Next() {
+		var lpMint string
+		if err := rows.Scan(&lpMint); err != nil {
+			return nil, err
+		}
+
+		mint, err := solana.PublicKeyFromBase58(lpMint)
+		if err != nil {
+			continue
+		}
+		mints = append(mints, mint)
+	}
+
+	return mints, rows.Err()
+}
+
+// GetAmmIdByLpMint returns the pool minting the LP, nil when the mint is not a known pool's
+func (s *AmmStorage) GetAmmIdByLpMint(lpMint solana.PublicKey) (*solana.PublicKey, error) {
+	var ammId string
+
+	err := s.client.QueryRow(`SELECT amm_id FROM `+TABLE_NAME_AMM+` WHERE lp_mint = ? LIMIT 1`, lpMint.String()).Scan(&ammId)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get amm of LP mint %s: %w", lpMint, err)
+	}
+
+	key, err := solana.PublicKeyFromBase58(ammId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func nullKey(key *solana.PublicKey) interface{} {
+	if key == nil {
+		return nil
+	}
+	return key.String()
+}
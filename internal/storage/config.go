@@ -1,10 +1,17 @@
 package storage
 
 const (
-	KEY_POOLKEYS   = "storage::pool_keys"
+	KEY_POOLKEYS   = "storage::pool_keys::v2"
 	KEY_LOOKUP     = "storage::lookup"
 	KEY_TRACKEDAMM = "storage::tracked_amm"
 	KEY_CHUNK      = "storage::chunk"
+
+	// Pool keys cached before the market authority was derived from the market, never read
+	KEY_POOLKEYS_V1 = "storage::pool_keys"
+
+	// Sorted set per status of the tracked pools, scored by LastUpdated
+	KEY_TRACKER_INDEX          = "storage::tracker_index"
+	KEY_TRACKER_INDEX_MIGRATED = "storage::tracker_index::migrated"
 )
 
 const (
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
+)
+
+// Both *sql.DB and *sql.Tx, so the ledger can run its writes in one transaction
+type sqlClient interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+type LedgerStorage struct {
+	client sqlClient
+}
+
+func NewLedgerStorage(db *sql.DB) *LedgerStorage {
+	return &LedgerStorage{client: db}
+}
+
+func NewLedgerStorageTx(tx *sql.Tx) *LedgerStorage {
+	return &LedgerStorage{client: tx}
+}
+
+func (s *LedgerStorage) SetFill(fill *types.Fill) (int64, error) {
+	query := `
+			INSERT INTO fills (strategy, paper, signature, amm_id, mint, action, sol_in, sol_out, token_in, token_out,
+				token_unmatched, fee, priority_fee, tip, realized_pnl, slot, block_time)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`
+	result, err := s.client.Exec(
+		query,
+		fill.Strategy,
+		fill.Paper,
+		fill.Signature,
+		fill.AmmId.String(),
+		fill.Mint.String(),
+		fill.Action,
+		fill.SolIn,
+		fill.SolOut,
+		fill.TokenIn,
+		fill.TokenOut,
+		fill.TokenUnmatched,
+		fill.Fee,
+		fill.PriorityFee,
+		fill.Tip,
+		fill.RealizedPnl,
+		fill.Slot,
+		fill.BlockTime,
+	)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert fill: %w", err)
+	}
+
+	return result.LastInsertId()
+}
+
+func (s *LedgerStorage) SetLot(lot *types.Lot) error {
+	query := `
+			INSERT INTO lots (fill_id, strategy, paper, amm_id, mint, token_remaining, cost_remaining)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`
+	_, err := s.client.Exec(query, lot.FillId, lot.Strategy, lot.Paper, lot.AmmId.String(), lot.Mint.String(), lot.TokenRemaining, lot.CostRemaining)
+	if err != nil {
+		return fmt.Errorf("failed to insert lot: %w", err)
+	}
+
+	return nil
+}
+
+func (s *LedgerStorage) UpdateLot(lot *types.Lot) error {
+	query := `UPDATE lots SET token_remaining = ?, cost_remaining = ? WHERE id = ?`
+
+	if _, err := s.client.Exec(query, lot.TokenRemaining, lot.CostRemaining, lot.Id); err != nil {
+		return fmt.Errorf("failed to update lot %d: %w", lot.Id, err)
+	}
+
+	return nil
+}
+
+// GetOpenLots returns the unsold lots of a mint oldest first, locked for the rest of the transaction
+func (s *LedgerStorage) GetOpenLots(strategy string, paper bool, mint solana.PublicKey) ([]types.Lot, error) {
+	query := `
+			SELECT id, fill_id, strategy, paper, amm_id, mint, token_remaining, cost_remaining FROM lots
+			WHERE strategy = ? AND paper = ? AND mint = ? AND token_remaining > 0
+			ORDER BY id FOR UPDATE
+		`
+	return s.queryLots(query, strategy, paper, mint.String())
+}
+
+// GetAllOpenLots returns every unsold lot of the strategy
+func (s *LedgerStorage) GetAllOpenLots(strategy string, paper bool) ([]types.Lot, error) {
+	query := `
+			SELECT id, fill_id, strategy, paper, amm_id, mint, token_remaining, cost_remaining FROM lots
+			WHERE strategy = ? AND paper = ? AND token_remaining > 0
+			ORDER BY id
+		`
+	return s.queryLots(query, strategy, paper)
+}
+
+func (s *LedgerStorage) queryLots(query string, args ...interface{}) ([]types.Lot, error) {
+	rows, err := s.client.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lots: %w", err)
+	}
+	defer rows.Close()
+
+	var lots []types.Lot
+	for rows.Next() {
+		var lot types.Lot
+		var ammId, mint string
+
+		if err := rows.Scan(&lot.Id, &lot.FillId, &lot.Strategy, &lot.Paper, &ammId, &mint, &lot.TokenRemaining, &lot.CostRemaining); err != nil {
+			return nil, err
+		}
+
+		ammKey, err := solana.PublicKeyFromBase58(ammId)
+		if err != nil {
+			return nil, err
+		}
+		mintKey, err := solana.PublicKeyFromBase58(mint)
+		if err != nil {
+			return nil, err
+		}
+
+		lot.AmmId = &ammKey
+		lot.Mint = &mintKey
+		lots = append(lots, lot)
+	}
+
+	return lots, rows.Err()
+}
+
+// AddDailyPnl rolls a fill into its strategy's row for day (YYYY-MM-DD)
+func (s *LedgerStorage) AddDailyPnl(strategy string, paper bool, day string, realized int64, fees uint64, buys int, sells int) error {
+	query := `
+			INSERT INTO pnl_daily (strategy, paper, day, realized_pnl, fees, buys, sells)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE
+				realized_pnl = realized_pnl + VALUES(realized_pnl),
+				fees = fees + VALUES(fees),
+				buys = buys + VALUES(buys),
+				sells = sells + VALUES(sells)
+		`
+	if _, err := s.client.Exec(query, strategy, paper, day, realized, fees, buys, sells); err != nil {
+		return fmt.Errorf("failed to roll up pnl: %w", err)
+	}
+
+	return nil
+}
+
+func (s *LedgerStorage) SetDailyUnrealized(strategy string, paper bool, day string, unrealized int64) error {
+	query := `
+			INSERT INTO pnl_daily (strategy, paper, day, unrealized_pnl)
+			VALUES (?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE unrealized_pnl = VALUES(unrealized_pnl)
+		`
+	if _, err := s.client.Exec(query, strategy, paper, day, unrealized); err != nil {
+		return fmt.Errorf("failed to set unrealized pnl: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
+)
+
+type PaperTradeStorage struct {
+	client *sql.DB
+}
+
+func NewPaperTradeStorage(db *sql.DB) *PaperTradeStorage {
+	return &PaperTradeStorage{client: db}
+}
+
+func (s *PaperTradeStorage) SetPaperTrade(trade *types.PaperTrade) (int64, error) {
+	query := `
+			INSERT INTO paper_trades (strategy, amm_id, mint, action, amount_in, amount_out, minimum_amount_out, price_impact, cost,
+				trigger_signature, trigger_slot, landing_slot, mark_slot, mark_value, pnl, transaction, timestamp)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`
+	result, err := s.client.Exec(
+		query,
+		trade.Strategy,
+		trade.AmmId.String(),
+		trade.Mint.String(),
+		trade.Action,
+		trade.AmountIn,
+		trade.AmountOut,
+		trade.MinimumAmountOut,
+		trade.PriceImpact,
+		trade.CostLamports,
+		trade.TriggerSignature,
+		trade.TriggerSlot,
+		trade.LandingSlot,
+		trade.MarkSlot,
+		trade.MarkValue,
+		trade.Pnl,
+		trade.Transaction,
+		trade.Timestamp,
+	)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert paper trade: %w", err)
+	}
+
+	return result.LastInsertId()
+}
+
+func (s *PaperTradeStorage) MarkPaperTrade(id int64, slot uint64, value uint64, pnl int64) error {
+	query := `UPDATE paper_trades SET mark_slot = ?, mark_value = ?, pnl = ? WHERE id = ?`
+
+	if _, err := s.client.Exec(query, slot, value, pnl, id); err != nil {
+		return fmt.Errorf("failed to mark paper trade %d: %w", id, err)
+	}
+
+	return nil
+}
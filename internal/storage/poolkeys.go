@@ -23,7 +23,12 @@ func SetPoolKeys(client *redis.Client, pKey *types.RaydiumPoolKeys) error {
 		return err
 	}
 
-	if err := client.HSet(ctx, pKey.ID.String(), KEY_POOLKEYS, data).Err(); err != nil {
+	_, err = client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, pKey.ID.String(), KEY_POOLKEYS, data)
+		pipe.HDel(ctx, pKey.ID.String(), KEY_POOLKEYS_V1)
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
+)
+
+// TrackerHistoryStorage appends tracker transitions, rows are never updated
+type TrackerHistoryStorage struct {
+	client *sql.DB
+}
+
+func NewTrackerHistoryStorage(db *sql.DB) *TrackerHistoryStorage {
+	return &TrackerHistoryStorage{client: db}
+}
+
+// SetTransition appends the transition and returns its row id
+func (s *TrackerHistoryStorage) SetTransition(transition *types.TrackerTransition) (int64, error) {
+	query := `
+			INSERT INTO tracker_history (amm_id, from_status, to_status, reason, signature, slot, timestamp)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`
+	result, err := s.client.Exec(
+		query,
+		transition.AmmId.String(),
+		transition.From,
+		transition.To,
+		transition.Reason,
+		transition.Signature,
+		transition.Slot,
+		transition.Timestamp,
+	)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert tracker transition: %w", err)
+	}
+
+	return result.LastInsertId()
+}
+
+// DeleteTransition removes a transition whose status change could not be stored
+func (s *TrackerHistoryStorage) DeleteTransition(id int64) error {
+	if _, err := s.client.Exec(`DELETE FROM tracker_history WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete tracker transition %d: %w", id, err)
+	}
+
+	return nil
+}
+
+// GetTransitions returns the pool's history oldest first
+func (s *TrackerHistoryStorage) GetTransitions(ammId solana.PublicKey) ([]types.TrackerTransition, error) {
+	query := `
+			SELECT from_status, to_status, reason, signature, slot, timestamp FROM tracker_history
+			WHERE amm_id = ? ORDER BY id
+		`
+	rows, err := s.client.Query(query, ammId.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tracker history: %w", err)
+	}
+	defer rows.Close()
+
+	var transitions []types.TrackerTransition
+	for rows.Next() {
+		transition := types.TrackerTransition{AmmId: &ammId}
+		if err := rows.Scan(&transition.From, &transition.To, &transition.Reason, &transition.Signature, &transition.Slot, &transition.Timestamp); err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, transition)
+	}
+
+	return transitions, rows.Err()
+}
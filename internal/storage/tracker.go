@@ -4,6 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
 	"github.com/redis/go-redis/v9"
@@ -14,17 +17,76 @@ type TrackedAmmStorage struct {
 }
 
 const (
-	TRACKED_TRIGGER_ONLY = "TRACKED_TRIGGER_ONLY"
-	TRACKED_BOTH         = "TRACKED_BOTH"
-	PAUSE                = "PAUSE"
-	NOT_TRACKED          = "NOT_TRACKED"
+	TRACKED_TRIGGER_ONLY types.TrackerStatus = "TRACKED_TRIGGER_ONLY"
+	TRACKED_BOTH         types.TrackerStatus = "TRACKED_BOTH"
+	PAUSE                types.TrackerStatus = "PAUSE"
+	NOT_TRACKED          types.TrackerStatus = "NOT_TRACKED"
 )
 
+// Reasons recorded with a transition
+const (
+	REASON_LP_WITHDRAWN    = "lp-withdrawn"
+	REASON_INITIALIZE2     = "initialize2"
+	REASON_EXPIRED         = "expired"
+	REASON_POOL_CLOSED     = "pool-closed"
+	REASON_POOL_DISABLED   = "pool-disabled"
+	REASON_MARKET_CLOSED   = "market-closed"
+	REASON_LP_PULLED       = "lp-pulled"
+	REASON_DEAD            = "dead"
+	REASON_LIQUIDITY_ADDED = "liquidity-added"
+)
+
+var TRACKER_STATUSES = []types.TrackerStatus{TRACKED_TRIGGER_ONLY, TRACKED_BOTH, PAUSE, NOT_TRACKED}
+
+const TRACKER_PAGE_SIZE = 500
+
+var (
+	ErrInvalidStatus     = errors.New("invalid tracking status")
+	ErrInvalidTransition = errors.New("invalid tracking transition")
+)
+
+// The moves the bot makes. Withdraws track a pool, or refresh it in its tracked status, a
+// deposit promotes it, an Initialize2 pauses it and only a deposit seen after the pause
+// resumes it. Expiry and the evaluator drop it.
+var trackerTransitions = map[types.TrackerStatus][]types.TrackerStatus{
+	NOT_TRACKED:          {TRACKED_TRIGGER_ONLY},
+	TRACKED_TRIGGER_ONLY: {TRACKED_TRIGGER_ONLY, TRACKED_BOTH, PAUSE, NOT_TRACKED},
+	TRACKED_BOTH:         {TRACKED_BOTH, PAUSE, NOT_TRACKED},
+	PAUSE:                {TRACKED_TRIGGER_ONLY, NOT_TRACKED},
+}
+
+func ValidTrackerStatus(status types.TrackerStatus) bool {
+	_, ok := trackerTransitions[status]
+	return ok
+}
+
+// CanTransition reports whether a tracker in status from may move to status to
+func CanTransition(from types.TrackerStatus, to types.TrackerStatus) error {
+	allowed, ok := trackerTransitions[from]
+	if !ok || !ValidTrackerStatus(to) {
+		return ErrInvalidStatus
+	}
+
+	for _, status := range allowed {
+		if status == to {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, from, to)
+}
+
+func trackerIndexKey(status types.TrackerStatus) string {
+	return KEY_TRACKER_INDEX + "::" + string(status)
+}
+
+// SetTracked stores the tracker and moves the pool to its status' index in one MULTI, so the
+// index never lists a pool under two statuses
 func SetTracked(client *redis.Client, ammId string, tracker types.Tracker) error {
 	ctx := context.Background()
 
-	if tracker.Status != TRACKED_TRIGGER_ONLY && tracker.Status != TRACKED_BOTH && tracker.Status != PAUSE && tracker.Status != NOT_TRACKED {
-		return errors.New("invalid tracking status")
+	if !ValidTrackerStatus(tracker.Status) {
+		return ErrInvalidStatus
 	}
 
 	data, err := json.Marshal(tracker)
@@ -33,11 +95,18 @@ func SetTracked(client *redis.Client, ammId string, tracker types.Tracker) error
 		return err
 	}
 
-	if err := client.HSet(ctx, ammId, KEY_TRACKEDAMM, data).Err(); err != nil {
-		return err
-	}
+	_, err = client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, ammId, KEY_TRACKEDAMM, data)
+		for _, status := range TRACKER_STATUSES {
+			if status != tracker.Status {
+				pipe.ZRem(ctx, trackerIndexKey(status), ammId)
+			}
+		}
+		pipe.ZAdd(ctx, trackerIndexKey(tracker.Status), redis.Z{Score: float64(tracker.LastUpdated), Member: ammId})
+		return nil
+	})
 
-	return nil
+	return err
 }
 
 func GetTracked(client *redis.Client, ammId string) (*types.Tracker, error) {
@@ -46,7 +115,7 @@ func GetTracked(client *redis.Client, ammId string) (*types.Tracker, error) {
 	if err != nil {
 		if err == redis.Nil {
 			return &types.Tracker{
-				Status: "NOT_TRACKED",
+				Status: NOT_TRACKED,
 			}, nil
 		}
 
@@ -58,45 +127,251 @@ func GetTracked(client *redis.Client, ammId string) (*types.Tracker, error) {
 		return &types.Tracker{}, err
 	}
 
-	switch tracker.Status {
-	case TRACKED_TRIGGER_ONLY, TRACKED_BOTH, PAUSE, NOT_TRACKED:
-		return &tracker, nil
-	default:
+	if !ValidTrackerStatus(tracker.Status) {
 		return nil, errors.New("unexpected value in Redis")
 	}
+
+	return &tracker, nil
 }
 
+// GetAllTracked returns every indexed tracker, status by status
 func GetAllTracked(client *redis.Client) (*[]types.Tracker, error) {
+	var trackers []types.Tracker
+
+	for _, status := range TRACKER_STATUSES {
+		var cursor *TrackerCursor
+		for {
+			page, next, err := GetTrackedPage(client, status, cursor, TRACKER_PAGE_SIZE)
+			if err != nil {
+				return nil, err
+			}
+
+			trackers = append(trackers, page...)
+			if next == nil {
+				break
+			}
+			cursor = next
+		}
+	}
+
+	return &trackers, nil
+}
+
+// TrackerCursor is the last pool of a page, the next page starts after its score and id
+type TrackerCursor struct {
+	Score  float64
+	Member string
+}
+
+// after tells if the index entry sorts after the cursor, entries sharing a score sort by id
+func (c *TrackerCursor) after(z redis.Z) bool {
+	if c == nil || z.Score > c.Score {
+		return true
+	}
+	return z.Score == c.Score && z.Member.(string) > c.Member
+}
+
+// GetTrackedPage returns up to count trackers of a status, least recently updated first. The
+// cursor starts nil and the returned one is nil once the index is exhausted. Pages follow the
+// last score and id rather than an offset, so a pool moving while paging does not shift the
+// pages after it.
+func GetTrackedPage(client *redis.Client, status types.TrackerStatus, cursor *TrackerCursor, count int64) ([]types.Tracker, *TrackerCursor, error) {
 	ctx := context.Background()
 
-	keys, err := client.Keys(ctx, "*").Result()
-	if err != nil {
-		return nil, err
+	if !ValidTrackerStatus(status) {
+		return nil, nil, ErrInvalidStatus
 	}
 
-	var trackers []types.Tracker
+	from := "-inf"
+	if cursor != nil {
+		from = strconv.FormatFloat(cursor.Score, 'f', -1, 64)
+	}
 
-	for _, key := range keys {
-		data, err := client.HGet(ctx, key, KEY_TRACKEDAMM).Result()
+	// The range starts at the cursor's score, entries sharing it up to the cursor's id are
+	// skipped a batch at a time
+	var entries []redis.Z
+	for offset := int64(0); int64(len(entries)) < count; offset += count {
+		batch, err := client.ZRangeByScoreWithScores(ctx, trackerIndexKey(status), &redis.ZRangeBy{
+			Min:    from,
+			Max:    "+inf",
+			Offset: offset,
+			Count:  count,
+		}).Result()
 		if err != nil {
-			if err == redis.Nil {
-				continue
+			return nil, nil, err
+		}
+
+		for _, z := range batch {
+			if cursor.after(z) && int64(len(entries)) < count {
+				entries = append(entries, z)
 			}
-			return nil, err
+		}
+
+		if int64(len(batch)) < count {
+			break
+		}
+	}
+
+	ammIds := make([]string, 0, len(entries))
+	for _, z := range entries {
+		ammIds = append(ammIds, z.Member.(string))
+	}
+
+	if len(ammIds) == 0 {
+		return nil, nil, nil
+	}
+
+	cmds, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, ammId := range ammIds {
+			pipe.HGet(ctx, ammId, KEY_TRACKEDAMM)
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, nil, err
+	}
+
+	trackers := make([]types.Tracker, 0, len(cmds))
+	for _, cmd := range cmds {
+		data, err := cmd.(*redis.StringCmd).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
 		}
 
 		var tracker types.Tracker
 		if err := json.Unmarshal([]byte(data), &tracker); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		switch tracker.Status {
-		case TRACKED_TRIGGER_ONLY, TRACKED_BOTH, PAUSE, NOT_TRACKED:
-			trackers = append(trackers, tracker)
-		default:
-			return nil, errors.New("unexpected value in Redis")
+		if !ValidTrackerStatus(tracker.Status) {
+			return nil, nil, errors.New("unexpected value in Redis")
 		}
+		trackers = append(trackers, tracker)
 	}
 
-	return &trackers, nil
+	// A short page means the range ran out
+	if int64(len(entries)) < count {
+		return trackers, nil, nil
+	}
+
+	last := entries[len(entries)-1]
+	return trackers, &TrackerCursor{Score: last.Score, Member: last.Member.(string)}, nil
+}
+
+// GetTrackedBefore returns up to count pools of a status last updated before the unix time
+func GetTrackedBefore(client *redis.Client, status types.TrackerStatus, before int64, count int64) ([]string, error) {
+	ctx := context.Background()
+
+	return client.ZRangeByScore(ctx, trackerIndexKey(status), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   fmt.Sprintf("(%d", before),
+		Count: count,
+	}).Result()
+}
+
+// DeleteTracked forgets the pool's tracker, leaving the rest of its hash
+func DeleteTracked(client *redis.Client, ammId string) error {
+	ctx := context.Background()
+
+	_, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, ammId, KEY_TRACKEDAMM)
+		for _, status := range TRACKER_STATUSES {
+			pipe.ZRem(ctx, trackerIndexKey(status), ammId)
+		}
+		return nil
+	})
+
+	return err
+}
+
+// CountTracked returns the number of indexed pools per status
+func CountTracked(client *redis.Client) (map[types.TrackerStatus]int64, error) {
+	ctx := context.Background()
+
+	cmds, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, status := range TRACKER_STATUSES {
+			pipe.ZCard(ctx, trackerIndexKey(status))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[types.TrackerStatus]int64, len(TRACKER_STATUSES))
+	for i, status := range TRACKER_STATUSES {
+		counts[status] = cmds[i].(*redis.IntCmd).Val()
+	}
+
+	return counts, nil
+}
+
+// MigrateTrackedIndex indexes the trackers stored before the index existed. It SCANs the db
+// instead of using KEYS and runs once, a marker key records that it finished.
+func MigrateTrackedIndex(client *redis.Client) (int, error) {
+	ctx := context.Background()
+
+	done, err := client.Exists(ctx, KEY_TRACKER_INDEX_MIGRATED).Result()
+	if err != nil || done > 0 {
+		return 0, err
+	}
+
+	indexed := 0
+	var cursor uint64
+	// Trackers written before LastUpdated are indexed as updated now, rather than expiring at once
+	migratedAt := time.Now().Unix()
+
+	for {
+		keys, next, err := client.ScanType(ctx, cursor, "*", TRACKER_PAGE_SIZE, "hash").Result()
+		if err != nil {
+			return indexed, err
+		}
+
+		cmds, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range keys {
+				pipe.HGet(ctx, key, KEY_TRACKEDAMM)
+			}
+			return nil
+		})
+		if err != nil && err != redis.Nil {
+			return indexed, err
+		}
+
+		_, err = client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, cmd := range cmds {
+				data, err := cmd.(*redis.StringCmd).Result()
+				if err != nil {
+					// A hash without a tracker, e.g. only pool keys
+					continue
+				}
+
+				var tracker types.Tracker
+				if err := json.Unmarshal([]byte(data), &tracker); err != nil || !ValidTrackerStatus(tracker.Status) {
+					continue
+				}
+
+				score := tracker.LastUpdated
+				if score == 0 {
+					score = migratedAt
+				}
+
+				pipe.ZAdd(ctx, trackerIndexKey(tracker.Status), redis.Z{Score: float64(score), Member: keys[i]})
+				indexed++
+			}
+			return nil
+		})
+		if err != nil {
+			return indexed, err
+		}
+
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+
+	return indexed, client.Set(ctx, KEY_TRACKER_INDEX_MIGRATED, migratedAt, 0).Err()
 }
@@ -0,0 +1,86 @@
+package submitter
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// RaceResult is the outcome of a transaction raced across relays
+type RaceResult struct {
+	Submissions []SubmitResult
+	Track       TrackResult
+}
+
+type Racer struct {
+	submitters []Submitter
+	Tracker    *Tracker
+}
+
+func NewRacer(submitters []Submitter) *Racer {
+	return &Racer{
+		submitters: submitters,
+		Tracker:    NewTracker(),
+	}
+}
+
+// Submit sends the same signed transaction, or bundle, through every relay in parallel and
+// tracks its signature until it reaches the until stage or its blockhash expires. Relays only
+// ever carry the one signature so the race can land at most once. Transactions are gated on
+// their simulation by Prepare before.
+func (r *Racer) Submit(txs []*solana.Transaction, until Stage) *RaceResult {
+	start := time.Now()
+	race := &RaceResult{}
+
+	if len(txs) == 0 {
+		log.Printf("Not submitted: %v", ErrEmptySubmission)
+		race.Track = TrackResult{Stage: STAGE_REJECTED}
+		return race
+	}
+	signature := firstSignature(txs)
+
+	var wg sync.WaitGroup
+	results := make(chan SubmitResult, len(r.submitters))
+
+	for _, submitter := range r.submitters {
+		wg.Add(1)
+		go func(submitter Submitter) {
+			defer wg.Done()
+			results <- submitter.Submit(txs)
+		}(submitter)
+	}
+
+	wg.Wait()
+	close(results)
+
+	var accepted []string
+	for result := range results {
+		race.Submissions = append(race.Submissions, result)
+
+		if result.Err != nil {
+			log.Printf("%s | %s rejected the submission: %v", result.Signature, result.Relay, result.Err)
+			continue
+		}
+		accepted = append(accepted, result.Relay)
+	}
+
+	if len(accepted) == 0 {
+		race.Track = TrackResult{Signature: signature, Stage: STAGE_REJECTED}
+		return race
+	}
+
+	race.Track = r.Tracker.Track(signature, txs[0].Message.RecentBlockhash, start, until)
+
+	switch {
+	case race.Track.Landed():
+		log.Printf("%s | %s through %v, processed in %s, confirmed in %s", signature, race.Track.Stage, accepted, race.Track.ProcessedAfter, race.Track.ConfirmedAfter)
+	case race.Track.Included():
+		log.Printf("%s | Failed on-chain in slot %d: %s", signature, race.Track.Slot, race.Track.Err)
+	default:
+		log.Printf("%s | %s after %s", signature, race.Track.Stage, time.Since(start))
+	}
+
+	return race
+}
@@ -0,0 +1,66 @@
+package submitter
+
+import (
+	"log"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/rpc"
+)
+
+type SimulationPolicy int
+
+const (
+	// SIMULATE gates the submission on a successful simulation
+	SIMULATE SimulationPolicy = iota
+	// SKIP_SIMULATION sends straight away, for latency critical strategies
+	SKIP_SIMULATION
+)
+
+const (
+	COMPUTE_UNIT_MARGIN_BPS = 1000
+	MAX_COMPUTE_UNIT_LIMIT  = 1400000
+)
+
+// Simulate runs the pre-flight gate and returns the simulation error when the transaction must not
+// be sent. Bundles are not simulated since each transaction depends on the state left by the previous one.
+func Simulate(txs []*solana.Transaction) (*rpc.SimulationResult, error) {
+	if len(txs) != 1 {
+		return nil, nil
+	}
+
+	simulation, err := rpc.SimulateTransaction(txs[0], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return simulation, simulation.Err
+}
+
+// Prepare signs the transaction through sign, at its own compute unit limit when given 0. Under
+// SIMULATE the simulation gates it and it is signed again at the limit the simulation measured,
+// so the priority fee is only paid on what it uses. Only the limit changes, the simulation still
+// stands for the transaction signed again.
+func Prepare(policy SimulationPolicy, sign func(computeUnitLimit uint32) ([]*solana.Transaction, error)) ([]*solana.Transaction, error) {
+	txs, err := sign(0)
+	if err != nil || policy != SIMULATE {
+		return txs, err
+	}
+
+	simulation, err := Simulate(txs)
+	if err != nil {
+		log.Printf("%s | Not submitted: %v", firstSignature(txs), err)
+		return nil, err
+	}
+
+	if simulation == nil || simulation.UnitsConsumed == 0 {
+		return txs, nil
+	}
+
+	return sign(ComputeUnitLimit(simulation.UnitsConsumed))
+}
+
+// Compute unit limit covering the simulated usage with a margin for state changing before it lands
+func ComputeUnitLimit(unitsConsumed uint64) uint32 {
+	limit := unitsConsumed + unitsConsumed*COMPUTE_UNIT_MARGIN_BPS/10000
+	return uint32(min(limit, MAX_COMPUTE_UNIT_LIMIT))
+}
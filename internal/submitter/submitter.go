@@ -0,0 +1,139 @@
+package submitter
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/config"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/pool"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/rpc"
+)
+
+const (
+	RELAY_RPC       = "rpc"
+	RELAY_JITO      = "jito"
+	RELAY_BLOXROUTE = "bloxroute"
+
+	BLOXROUTE_CLIENTS = 2
+)
+
+var (
+	ErrEmptySubmission   = errors.New("nothing to submit")
+	ErrBundleUnsupported = errors.New("relay cannot submit bundles")
+)
+
+// Submitter sends a signed transaction, or an atomic bundle of them, through one relay
+type Submitter interface {
+	Name() string
+	Submit(txs []*solana.Transaction) SubmitResult
+}
+
+// SubmitResult is what a relay answered, not whether the transaction landed
+type SubmitResult struct {
+	Relay     string
+	Signature solana.Signature
+	BundleId  string
+	Latency   time.Duration
+	Err       error
+}
+
+// Build the submitters named in relays, e.g. config.SubmitRelays
+func NewSubmitters(relays []string) ([]Submitter, error) {
+	submitters := make([]Submitter, 0, len(relays))
+
+	for _, relay := range relays {
+		switch relay {
+		case RELAY_RPC:
+			submitters = append(submitters, &RpcSubmitter{})
+		case RELAY_JITO:
+			submitters = append(submitters, &JitoSubmitter{client: rpc.NewJitoClient(config.BLOCKENGINE_URL)})
+		case RELAY_BLOXROUTE:
+			bloxRoutePool, err := pool.NewBloxRoutePool(BLOXROUTE_CLIENTS)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", relay, err)
+			}
+			submitters = append(submitters, &BloxRouteSubmitter{pool: bloxRoutePool, UseStakedRPCs: true})
+		default:
+			return nil, fmt.Errorf("unknown relay %q", relay)
+		}
+	}
+
+	return submitters, nil
+}
+
+// Plain sendTransaction to the RPC node
+type RpcSubmitter struct{}
+
+func (s *RpcSubmitter) Name() string {
+	return RELAY_RPC
+}
+
+func (s *RpcSubmitter) Submit(txs []*solana.Transaction) SubmitResult {
+	start := time.Now()
+	result := SubmitResult{Relay: RELAY_RPC}
+
+	if len(txs) != 1 {
+		result.Err = ErrBundleUnsupported
+		return result
+	}
+
+	result.Signature, result.Err = rpc.SendTransaction(txs[0])
+	result.Latency = time.Since(start)
+
+	return result
+}
+
+// Jito block engine bundle, a single transaction is sent as a bundle of one
+type JitoSubmitter struct {
+	client *rpc.JitoClient
+}
+
+func (s *JitoSubmitter) Name() string {
+	return RELAY_JITO
+}
+
+func (s *JitoSubmitter) Submit(txs []*solana.Transaction) SubmitResult {
+	start := time.Now()
+	result := SubmitResult{Relay: RELAY_JITO, Signature: firstSignature(txs)}
+
+	result.BundleId, result.Err = s.client.SendBundle(txs)
+	result.Latency = time.Since(start)
+
+	return result
+}
+
+// bloXroute Trader API, the transaction has to carry the bloXroute memo and tip
+type BloxRouteSubmitter struct {
+	pool          *pool.BloxRoutePool
+	UseStakedRPCs bool
+}
+
+func (s *BloxRouteSubmitter) Name() string {
+	return RELAY_BLOXROUTE
+}
+
+func (s *BloxRouteSubmitter) Submit(txs []*solana.Transaction) SubmitResult {
+	start := time.Now()
+	result := SubmitResult{Relay: RELAY_BLOXROUTE}
+
+	if len(txs) != 1 {
+		result.Err = ErrBundleUnsupported
+		return result
+	}
+
+	submitted := <-s.pool.SendTransaction(txs[0], s.UseStakedRPCs)
+	result.Latency = time.Since(start)
+	result.Err = submitted.Err
+	result.Signature = firstSignature(txs)
+
+	return result
+}
+
+func firstSignature(txs []*solana.Transaction) solana.Signature {
+	if len(txs) == 0 || len(txs[0].Signatures) == 0 {
+		return solana.Signature{}
+	}
+	return txs[0].Signatures[0]
+}
@@ -0,0 +1,136 @@
+package submitter
+
+import (
+	"log"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/rpc"
+)
+
+type Stage string
+
+const (
+	STAGE_PENDING   Stage = "pending"
+	STAGE_PROCESSED Stage = "processed"
+	STAGE_CONFIRMED Stage = "confirmed"
+	STAGE_FINALIZED Stage = "finalized"
+	STAGE_EXPIRED   Stage = "expired"
+	STAGE_REJECTED  Stage = "rejected"
+)
+
+const (
+	TRACK_POLL_INTERVAL = 400 * time.Millisecond
+	// A blockhash is valid for 150 blocks, this only guards against a stuck RPC
+	TRACK_TIMEOUT = 3 * time.Minute
+)
+
+type TrackResult struct {
+	Signature solana.Signature
+	Stage     Stage
+	Slot      uint64
+	// Err is the on-chain error of a landed transaction that failed
+	Err            string
+	ProcessedAfter time.Duration
+	ConfirmedAfter time.Duration
+	FinalizedAfter time.Duration
+}
+
+// Landed reports whether the transaction landed and succeeded
+func (t TrackResult) Landed() bool {
+	return t.Included() && t.Err == ""
+}
+
+// Included reports whether the transaction landed, failed or not, and so paid its fees
+func (t TrackResult) Included() bool {
+	return t.Stage == STAGE_PROCESSED || t.Stage == STAGE_CONFIRMED || t.Stage == STAGE_FINALIZED
+}
+
+var landedStages = map[Stage]int{STAGE_PROCESSED: 1, STAGE_CONFIRMED: 2, STAGE_FINALIZED: 3}
+
+// Reached reports whether a landed stage is at least target
+func (s Stage) Reached(target Stage) bool {
+	return landedStages[s] > 0 && landedStages[s] >= landedStages[target]
+}
+
+type Tracker struct {
+	PollInterval time.Duration
+	Timeout      time.Duration
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		PollInterval: TRACK_POLL_INTERVAL,
+		Timeout:      TRACK_TIMEOUT,
+	}
+}
+
+// Track follows the signature until it reaches the until stage or can no longer land
+func (t *Tracker) Track(signature solana.Signature, blockhash solana.Hash, start time.Time, until Stage) TrackResult {
+	result := TrackResult{Signature: signature, Stage: STAGE_PENDING}
+	deadline := start.Add(t.Timeout)
+
+	for {
+		statuses, err := rpc.GetSignatureStatuses([]solana.Signature{signature})
+		if err != nil {
+			log.Printf("%s | Failed to fetch signature status: %v", signature, err)
+		}
+
+		landed := false
+		if len(statuses) > 0 && statuses[0] != nil {
+			status := statuses[0]
+
+			landed = true
+			result.Slot = status.Slot
+			if len(status.Err) > 0 && string(status.Err) != "null" {
+				result.Err = string(status.Err)
+			}
+			t.advance(&result, Stage(status.ConfirmationStatus), time.Since(start))
+		}
+
+		if result.Stage.Reached(until) {
+			return result
+		}
+
+		if !landed && err == nil {
+			valid, err := rpc.IsBlockhashValid(blockhash)
+			if err == nil && !valid {
+				result.Stage = STAGE_EXPIRED
+				return result
+			}
+		}
+
+		if time.Now().After(deadline) {
+			if !landed {
+				result.Stage = STAGE_EXPIRED
+			}
+			return result
+		}
+
+		time.Sleep(t.PollInterval)
+	}
+}
+
+// Record when each stage was first seen, stages skipped between polls get the same time
+func (t *Tracker) advance(result *TrackResult, stage Stage, elapsed time.Duration) {
+	switch stage {
+	case STAGE_FINALIZED:
+		if result.FinalizedAfter == 0 {
+			result.FinalizedAfter = elapsed
+		}
+		fallthrough
+	case STAGE_CONFIRMED:
+		if result.ConfirmedAfter == 0 {
+			result.ConfirmedAfter = elapsed
+		}
+		fallthrough
+	case STAGE_PROCESSED:
+		if result.ProcessedAfter == 0 {
+			result.ProcessedAfter = elapsed
+		}
+	default:
+		return
+	}
+
+	result.Stage = stage
+}
@@ -0,0 +1,234 @@
+package tracker
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/coder"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/config"
+	bot "github.com/iqbalbaharum/lp-remove-tracker/internal/library"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/liquidity"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/rpc"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/storage"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
+)
+
+const EVALUATOR_PAGE_SIZE = 200
+
+// Statuses the evaluator rechecks against the chain
+var EVALUATED_STATUSES = []types.TrackerStatus{storage.TRACKED_TRIGGER_ONLY, storage.TRACKED_BOTH, storage.PAUSE}
+
+// EvaluatorConfig is read from the environment by EvaluatorConfigFromEnv. A zero TTL never
+// expires the status.
+type EvaluatorConfig struct {
+	Interval            time.Duration
+	Ttl                 map[types.TrackerStatus]time.Duration
+	DeadReserveLamports uint64
+}
+
+func EvaluatorConfigFromEnv() EvaluatorConfig {
+	return EvaluatorConfig{
+		Interval: time.Duration(config.TrackerReevaluateSeconds) * time.Second,
+		Ttl: map[types.TrackerStatus]time.Duration{
+			storage.TRACKED_TRIGGER_ONLY: time.Duration(config.TrackerTtlTriggerOnlySeconds) * time.Second,
+			storage.TRACKED_BOTH:         time.Duration(config.TrackerTtlBothSeconds) * time.Second,
+			storage.PAUSE:                time.Duration(config.TrackerTtlPauseSeconds) * time.Second,
+			storage.NOT_TRACKED:          time.Duration(config.TrackerTtlNotTrackedSeconds) * time.Second,
+		},
+		DeadReserveLamports: config.TrackerDeadReserveLamports,
+	}
+}
+
+// Evaluator expires trackers past their status' TTL and rechecks the tracked pools, dropping
+// the ones that died or lost their LP
+type Evaluator struct {
+	config EvaluatorConfig
+}
+
+func NewEvaluator(evaluatorConfig EvaluatorConfig) *Evaluator {
+	return &Evaluator{config: evaluatorConfig}
+}
+
+func (e *Evaluator) Run() {
+	if e.config.Interval == 0 {
+		return
+	}
+
+	for range time.Tick(e.config.Interval) {
+		e.Expire()
+		e.Evaluate()
+	}
+}
+
+// Expire untracks pools that kept their status past its TTL. Untracked pools past theirs are
+// dropped from the index altogether.
+func (e *Evaluator) Expire() {
+	now := time.Now()
+
+	for _, status := range storage.TRACKER_STATUSES {
+		ttl := e.config.Ttl[status]
+		if ttl == 0 {
+			continue
+		}
+
+		for {
+			ammIds, err := bot.GetTrackedAmmBefore(status, now.Add(-ttl).Unix(), EVALUATOR_PAGE_SIZE)
+			if err != nil {
+				log.Printf("Failed to list expired %s trackers: %v", status, err)
+				break
+			}
+
+			failed := false
+			for _, id := range ammIds {
+				ammId, err := solana.PublicKeyFromBase58(id)
+				if err != nil {
+					continue
+				}
+
+				if status == storage.NOT_TRACKED {
+					err = bot.DeleteTrackedAmm(&ammId)
+				} else {
+					err = bot.TransitionAmmFrom(&ammId, status, storage.NOT_TRACKED, types.TrackerCause{Reason: storage.REASON_EXPIRED})
+				}
+				if err != nil && !errors.Is(err, bot.ErrTrackerChanged) {
+					failed = true
+					log.Printf("%s | Failed to expire %s: %v", ammId, status, err)
+				}
+			}
+
+			// A failed pool stays in the index, the next tick retries it
+			if failed || len(ammIds) < EVALUATOR_PAGE_SIZE {
+				break
+			}
+		}
+	}
+}
+
+// Evaluate rechecks every tracked pool. The trackers are listed before any of them moves, so a
+// pool moved to another evaluated status is not rechecked twice in a tick.
+func (e *Evaluator) Evaluate() {
+	var trackers []types.Tracker
+
+	for _, status := range EVALUATED_STATUSES {
+		var cursor *storage.TrackerCursor
+		for {
+			page, next, err := bot.GetTrackedAmmPage(status, cursor, EVALUATOR_PAGE_SIZE)
+			if err != nil {
+				log.Printf("Failed to list %s trackers: %v", status, err)
+				break
+			}
+
+			trackers = append(trackers, page...)
+			if next == nil {
+				break
+			}
+			cursor = next
+		}
+	}
+
+	var ammIds []solana.PublicKey
+	evaluated := trackers[:0]
+	for _, tracker := range trackers {
+		if tracker.AmmId != nil {
+			ammIds = append(ammIds, *tracker.AmmId)
+			evaluated = append(evaluated, tracker)
+		}
+	}
+
+	states, errs := rpc.GetLiquidityStates(ammIds)
+
+	// The markets of the pools that could be read, a pool cannot swap once its market is closed
+	var marketIds, marketProgramIds []solana.PublicKey
+	var marketOf []int
+	for i, state := range states {
+		if state != nil {
+			marketIds = append(marketIds, state.MarketId)
+			marketProgramIds = append(marketProgramIds, state.MarketProgramId)
+			marketOf = append(marketOf, i)
+		}
+	}
+
+	marketErrs := make([]error, len(states))
+	_, errsByMarket := rpc.GetMarketStates(marketIds, marketProgramIds)
+	for j, i := range marketOf {
+		marketErrs[i] = errsByMarket[j]
+	}
+
+	for i, tracker := range evaluated {
+		status, reason, ok := e.evaluate(tracker, states[i], errs[i], marketErrs[i])
+		if !ok {
+			continue
+		}
+
+		err := bot.TransitionAmmFrom(tracker.AmmId, tracker.Status, status, types.TrackerCause{Reason: reason})
+		if err != nil && !errors.Is(err, bot.ErrTrackerChanged) {
+			log.Printf("%s | Failed to move to %s: %v", tracker.AmmId, status, err)
+		}
+	}
+}
+
+// evaluate returns the status the pool should move to and why, ok is false to leave it as is.
+// Paused pools are only resumed by a deposit, see processDeposit.
+func (e *Evaluator) evaluate(tracker types.Tracker, state *coder.LiquidityState, err error, marketErr error) (types.TrackerStatus, string, bool) {
+	ammId := tracker.AmmId
+
+	if errors.Is(err, rpc.ErrAccountNotFound) {
+		return storage.NOT_TRACKED, storage.REASON_POOL_CLOSED, true
+	}
+	if err != nil {
+		log.Printf("%s | %v", ammId, err)
+		return "", "", false
+	}
+
+	if !state.Swappable() {
+		return storage.NOT_TRACKED, storage.REASON_POOL_DISABLED, true
+	}
+
+	if state.LpReserve == 0 {
+		return storage.NOT_TRACKED, storage.REASON_LP_PULLED, true
+	}
+
+	if errors.Is(marketErr, rpc.ErrAccountNotFound) {
+		return storage.NOT_TRACKED, storage.REASON_MARKET_CLOSED, true
+	}
+	if marketErr != nil {
+		log.Printf("%s | Market %s: %v", ammId, state.MarketId, marketErr)
+		return "", "", false
+	}
+
+	if tracker.Status == storage.PAUSE {
+		return "", "", false
+	}
+
+	reserve, err := solReserve(ammId)
+	if err != nil {
+		log.Printf("%s | %v", ammId, err)
+		return "", "", false
+	}
+
+	if reserve < e.config.DeadReserveLamports {
+		return storage.NOT_TRACKED, storage.REASON_DEAD, true
+	}
+
+	return "", "", false
+}
+
+// The pool's SOL reserve, from the streamed reserve when the pool trades and otherwise read
+// from its SOL vault
+func solReserve(ammId *solana.PublicKey) (uint64, error) {
+	if reserve, exists := liquidity.GetCachedPoolReserve(*ammId); exists {
+		if reserve.BaseMint == config.WRAPPED_SOL {
+			return reserve.Base, nil
+		}
+		return reserve.Quote, nil
+	}
+
+	pKey, err := liquidity.GetPoolKeys(ammId)
+	if err != nil {
+		return 0, err
+	}
+
+	return liquidity.GetPoolSolBalance(pKey)
+}
@@ -0,0 +1,99 @@
+package trader
+
+import (
+	"log"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/builder"
+	bot "github.com/iqbalbaharum/lp-remove-tracker/internal/library"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/liquidity"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/rpc"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/submitter"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
+)
+
+func (t *Trader) record(fill *types.Fill) {
+	if err := t.ledger.RecordFill(fill); err != nil {
+		log.Printf("%s | Failed to record %s fill: %v", fill.AmmId, fill.Action, err)
+	}
+}
+
+// recordLive books a landed swap at what the chain settled. The buy is booked at its exact input
+// since the WSOL account is closed back to the payer, the sell at the payer's lamport change plus
+// what it paid in fees and tip.
+func (t *Trader) recordLive(action string, mint solana.PublicKey, params builder.SwapParams, race *submitter.RaceResult) *types.Fill {
+	result, err := rpc.GetTransaction(race.Track.Signature)
+	if err != nil {
+		log.Printf("%s | Failed to fetch the landed %s: %v", race.Track.Signature, action, err)
+		return nil
+	}
+
+	meta := result.Meta
+	fill := &types.Fill{
+		Signature:   race.Track.Signature.String(),
+		AmmId:       &params.PoolKeys.ID,
+		Mint:        &mint,
+		Action:      action,
+		Fee:         meta.Fee - min(meta.Fee, priorityFee(params)),
+		PriorityFee: min(meta.Fee, priorityFee(params)),
+		Tip:         tipLamports(params),
+		Slot:        result.Slot,
+	}
+	if result.BlockTime != nil {
+		fill.BlockTime = *result.BlockTime
+	}
+
+	change, err := meta.TokenBalanceChange(t.owner, mint)
+	if err != nil {
+		log.Printf("%s | Failed to read the %s token change: %v", race.Track.Signature, action, err)
+		return nil
+	}
+
+	// A buy only adds tokens and a sell only removes them
+	if action == bot.TRADE_SELL {
+		change.Neg(change)
+	}
+	if change.Sign() < 0 || !change.IsUint64() {
+		log.Printf("%s | Unexpected %s token change %s", race.Track.Signature, action, change)
+		return nil
+	}
+
+	switch action {
+	case bot.TRADE_BUY:
+		fill.SolIn = params.AmountIn
+		fill.TokenIn = change.Uint64()
+	case bot.TRADE_SELL:
+		fill.TokenOut = change.Uint64()
+		if len(meta.PreBalances) > 0 && len(meta.PostBalances) > 0 {
+			delta := int64(meta.PostBalances[0]) - int64(meta.PreBalances[0])
+			fill.SolOut = uint64(max(delta+int64(meta.Fee)+int64(fill.Tip), 0))
+		}
+	}
+
+	t.record(fill)
+
+	return fill
+}
+
+// paperFill books a paper swap at its quote and the fees it would have paid
+func paperFill(action string, mint solana.PublicKey, params builder.SwapParams, quote *liquidity.Quote, slot uint64) *types.Fill {
+	fill := &types.Fill{
+		AmmId:       &params.PoolKeys.ID,
+		Mint:        &mint,
+		Action:      action,
+		Fee:         BASE_FEE_LAMPORTS,
+		PriorityFee: priorityFee(params),
+		Tip:         tipLamports(params),
+		Slot:        slot,
+	}
+
+	if action == bot.TRADE_BUY {
+		fill.SolIn = quote.AmountIn
+		fill.TokenIn = quote.AmountOut
+	} else {
+		fill.TokenOut = quote.AmountIn
+		fill.SolOut = quote.AmountOut
+	}
+
+	return fill
+}
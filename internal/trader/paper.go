@@ -0,0 +1,132 @@
+package trader
+
+import (
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	bot "github.com/iqbalbaharum/lp-remove-tracker/internal/library"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/liquidity"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
+)
+
+// Marks are kept in memory on every reserve update and written at most this often per trade
+const PAPER_MARK_INTERVAL = 5 * time.Second
+
+type paperPosition struct {
+	trade     *types.PaperTrade
+	lastWrite time.Time
+}
+
+// PaperBook holds the open paper trades and marks them against later reserves
+type PaperBook struct {
+	mutex     sync.Mutex
+	positions map[solana.PublicKey][]*paperPosition
+}
+
+func NewPaperBook() *PaperBook {
+	return &PaperBook{
+		positions: make(map[solana.PublicKey][]*paperPosition),
+	}
+}
+
+// Open records the trade, marked at cost until the pool moves
+func (b *PaperBook) Open(trade *types.PaperTrade) error {
+	trade.MarkSlot = trade.LandingSlot
+	trade.MarkValue = trade.CostLamports
+
+	if err := bot.SetPaperTrade(trade); err != nil {
+		return err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.positions[*trade.AmmId] = append(b.positions[*trade.AmmId], &paperPosition{
+		trade:     trade,
+		lastWrite: time.Now(),
+	})
+
+	return nil
+}
+
+// Mark values every open trade of the pool at what selling its tokens would return now. The
+// marks due for a write are stored after the lock is released.
+func (b *PaperBook) Mark(reserve liquidity.PoolReserve) {
+	var marks []types.PaperTrade
+
+	b.mutex.Lock()
+	positions, ok := b.positions[reserve.AmmId]
+	if !ok {
+		b.mutex.Unlock()
+		return
+	}
+
+	info := reserve.PoolInfo()
+
+	for _, position := range positions {
+		trade := position.trade
+		if reserve.Slot <= trade.LandingSlot {
+			continue
+		}
+
+		value := uint64(0)
+		quote, err := liquidity.ComputeAmountOut(info, trade.AmountOut, reserve.Direction(*trade.Mint))
+		if err == nil {
+			value = quote.AmountOut
+		}
+
+		trade.MarkSlot = reserve.Slot
+		trade.MarkValue = value
+		trade.Pnl = int64(value) - int64(trade.CostLamports)
+
+		if time.Since(position.lastWrite) < PAPER_MARK_INTERVAL {
+			continue
+		}
+
+		position.lastWrite = time.Now()
+		marks = append(marks, *trade)
+	}
+	b.mutex.Unlock()
+
+	for i := range marks {
+		if err := bot.MarkPaperTrade(&marks[i]); err != nil {
+			log.Printf("%s | %v", reserve.AmmId, err)
+		}
+	}
+}
+
+// Close stops marking the pool's trades and settles them at their share of the exit proceeds
+func (b *PaperBook) Close(ammId solana.PublicKey, proceeds uint64, slot uint64) {
+	b.mutex.Lock()
+	positions := b.positions[ammId]
+	delete(b.positions, ammId)
+	b.mutex.Unlock()
+
+	var tokens uint64
+	for _, position := range positions {
+		tokens += position.trade.AmountOut
+	}
+
+	for _, position := range positions {
+		trade := position.trade
+
+		share := proceeds
+		if tokens > 0 {
+			share = new(big.Int).Div(
+				new(big.Int).Mul(new(big.Int).SetUint64(proceeds), new(big.Int).SetUint64(trade.AmountOut)),
+				new(big.Int).SetUint64(tokens),
+			).Uint64()
+		}
+
+		trade.MarkSlot = slot
+		trade.MarkValue = share
+		trade.Pnl = int64(share) - int64(trade.CostLamports)
+
+		if err := bot.MarkPaperTrade(trade); err != nil {
+			log.Printf("%s | %v", ammId, err)
+		}
+	}
+}
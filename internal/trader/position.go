@@ -0,0 +1,322 @@
+package trader
+
+import (
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/config"
+	bot "github.com/iqbalbaharum/lp-remove-tracker/internal/library"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/liquidity"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
+)
+
+type ExitReason string
+
+const (
+	EXIT_TAKE_PROFIT   ExitReason = "take-profit"
+	EXIT_STOP_LOSS     ExitReason = "stop-loss"
+	EXIT_TRAILING_STOP ExitReason = "trailing-stop"
+	EXIT_MAX_HOLD      ExitReason = "max-hold"
+	EXIT_LP_WITHDRAW   ExitReason = "lp-withdraw"
+)
+
+const (
+	// Pause between chunks so each one sells into a refreshed pool
+	EXIT_CHUNK_INTERVAL = 1 * time.Second
+	EXIT_MAX_RETRIES    = 3
+	// A stalled exit is resumed by the next mark after this long
+	EXIT_STALL_BACKOFF = 30 * time.Second
+	POSITION_TICK      = 1 * time.Second
+)
+
+// ExitConfig thresholds are in bps of the entry cost, 0 disables an exit
+type ExitConfig struct {
+	TakeProfitBps   uint64
+	StopLossBps     uint64
+	TrailingStopBps uint64
+	MaxHold         time.Duration
+	Chunks          uint64
+}
+
+func ExitConfigFromEnv() ExitConfig {
+	return ExitConfig{
+		TakeProfitBps:   config.ExitTakeProfitBps,
+		StopLossBps:     config.ExitStopLossBps,
+		TrailingStopBps: config.ExitTrailingStopBps,
+		MaxHold:         time.Duration(config.ExitMaxHoldSeconds) * time.Second,
+		Chunks:          config.ExitChunks,
+	}
+}
+
+// Position is everything held in one pool. Value is what selling the remaining tokens would
+// return now, so the position is worth Proceeds + Value.
+type Position struct {
+	PoolKeys   *types.RaydiumPoolKeys
+	Mint       solana.PublicKey
+	Cost       uint64
+	Tokens     uint64
+	Remaining  uint64
+	Proceeds   uint64
+	Value      uint64
+	PeakValue  uint64
+	Slot       uint64
+	OpenedAt   time.Time
+	ExitReason ExitReason
+	// StalledAt is set when the exit gave up with tokens left, the position stays open
+	StalledAt time.Time
+}
+
+func (p *Position) Pnl() int64 {
+	return int64(p.Proceeds+p.Value) - int64(p.Cost)
+}
+
+// Sells amount tokens of the position, returning the lamports received
+type sellFunc func(position Position, amount uint64) (uint64, error)
+
+type PositionManager struct {
+	mutex     sync.Mutex
+	positions map[solana.PublicKey]*Position
+	// Pools with an entry in flight
+	pending map[solana.PublicKey]bool
+	config  ExitConfig
+	sell    sellFunc
+	onClose func(position Position)
+}
+
+func NewPositionManager(exitConfig ExitConfig, sell sellFunc, onClose func(position Position)) *PositionManager {
+	return &PositionManager{
+		positions: make(map[solana.PublicKey]*Position),
+		pending:   make(map[solana.PublicKey]bool),
+		config:    exitConfig,
+		sell:      sell,
+		onClose:   onClose,
+	}
+}
+
+// Reserve claims the pool for one entry until Release, returning the lamports the entry may
+// still spend under maxExposure
+func (m *PositionManager) Reserve(ammId solana.PublicKey, maxExposure uint64) (uint64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.pending[ammId] {
+		return 0, ErrEntryPending
+	}
+
+	var spent uint64
+	if position, ok := m.positions[ammId]; ok {
+		if position.ExitReason != "" {
+			return 0, ErrExiting
+		}
+		spent = position.Cost
+	}
+	if spent >= maxExposure {
+		return 0, ErrExposureReached
+	}
+
+	m.pending[ammId] = true
+
+	return maxExposure - spent, nil
+}
+
+func (m *PositionManager) Release(ammId solana.PublicKey) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.pending, ammId)
+}
+
+// Open records an entry, adding to the pool's position when there is one
+func (m *PositionManager) Open(pKey *types.RaydiumPoolKeys, mint solana.PublicKey, tokens uint64, cost uint64, slot uint64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	position, ok := m.positions[pKey.ID]
+	if !ok {
+		position = &Position{
+			PoolKeys: pKey,
+			Mint:     mint,
+			OpenedAt: time.Now(),
+		}
+		m.positions[pKey.ID] = position
+	}
+
+	position.Cost += cost
+	position.Tokens += tokens
+	position.Remaining += tokens
+	position.Value += cost
+	position.PeakValue = max(position.PeakValue, position.Proceeds+position.Value)
+	position.Slot = max(position.Slot, slot)
+
+	m.saveChunk(position)
+}
+
+// Mark revalues the pool's position and starts the exit when a threshold is crossed
+func (m *PositionManager) Mark(reserve liquidity.PoolReserve) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	position, ok := m.positions[reserve.AmmId]
+	if !ok || reserve.Slot < position.Slot {
+		return
+	}
+
+	position.Slot = reserve.Slot
+	position.Value = 0
+	if position.Remaining > 0 {
+		liquidity.WantPoolState(reserve.AmmId)
+
+		quote, err := liquidity.ComputeAmountOut(reserve.PoolInfo(), position.Remaining, reserve.Direction(position.Mint))
+		if err == nil {
+			position.Value = quote.AmountOut
+		}
+	}
+
+	worth := position.Proceeds + position.Value
+	position.PeakValue = max(position.PeakValue, worth)
+
+	if position.ExitReason != "" {
+		if !position.StalledAt.IsZero() && time.Since(position.StalledAt) >= EXIT_STALL_BACKOFF {
+			log.Printf("%s | Resuming stalled exit %s | Unsold %d", position.PoolKeys.ID, position.ExitReason, position.Remaining)
+			position.StalledAt = time.Time{}
+			go m.sellChunks(position.PoolKeys.ID)
+		}
+		return
+	}
+
+	cost := position.Cost
+	switch {
+	case m.config.TakeProfitBps > 0 && worth >= cost+cost*m.config.TakeProfitBps/liquidity.BPS_DENOMINATOR:
+		m.exit(position, EXIT_TAKE_PROFIT)
+	case m.config.StopLossBps > 0 && worth <= cost-min(cost, cost*m.config.StopLossBps/liquidity.BPS_DENOMINATOR):
+		m.exit(position, EXIT_STOP_LOSS)
+	case m.config.TrailingStopBps > 0 && worth > cost && worth <= position.PeakValue-position.PeakValue*m.config.TrailingStopBps/liquidity.BPS_DENOMINATOR:
+		m.exit(position, EXIT_TRAILING_STOP)
+	}
+}
+
+// OnWithdraw exits the pool's position when liquidity is pulled from it
+func (m *PositionManager) OnWithdraw(ammId solana.PublicKey) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if position, ok := m.positions[ammId]; ok && position.ExitReason == "" {
+		m.exit(position, EXIT_LP_WITHDRAW)
+	}
+}
+
+// Run enforces the max hold time
+func (m *PositionManager) Run() {
+	if m.config.MaxHold == 0 {
+		return
+	}
+
+	for range time.Tick(POSITION_TICK) {
+		m.mutex.Lock()
+		for _, position := range m.positions {
+			if position.ExitReason == "" && time.Since(position.OpenedAt) >= m.config.MaxHold {
+				m.exit(position, EXIT_MAX_HOLD)
+			}
+		}
+		m.mutex.Unlock()
+	}
+}
+
+func (m *PositionManager) Positions() []Position {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	positions := make([]Position, 0, len(m.positions))
+	for _, position := range m.positions {
+		positions = append(positions, *position)
+	}
+
+	return positions
+}
+
+// exit must be called with the mutex held
+func (m *PositionManager) exit(position *Position, reason ExitReason) {
+	position.ExitReason = reason
+	log.Printf("%s | Exit %s | Worth %d for %d cost | PnL %d (Slot %d)", position.PoolKeys.ID, reason, position.Proceeds+position.Value, position.Cost, position.Pnl(), position.Slot)
+
+	go m.sellChunks(position.PoolKeys.ID)
+}
+
+// Sell the position down chunk by chunk, in the sizes stored in the pool's TokenChunk
+func (m *PositionManager) sellChunks(ammId solana.PublicKey) {
+	retries := 0
+
+	for {
+		m.mutex.Lock()
+		position := m.positions[ammId]
+		if position.Remaining > 0 && retries > EXIT_MAX_RETRIES {
+			position.StalledAt = time.Now()
+			m.mutex.Unlock()
+
+			log.Printf("%s | Exit %s stalled after %d attempts | Unsold %d", ammId, position.ExitReason, retries, position.Remaining)
+			return
+		}
+
+		if position.Remaining == 0 {
+			delete(m.positions, ammId)
+			m.mutex.Unlock()
+
+			log.Printf("%s | Closed %s | Proceeds %d for %d cost | PnL %d", ammId, position.ExitReason, position.Proceeds, position.Cost, int64(position.Proceeds)-int64(position.Cost))
+			if m.onClose != nil {
+				m.onClose(*position)
+			}
+			return
+		}
+
+		amount := min(m.chunkSize(position), position.Remaining)
+		snapshot := *position
+		m.mutex.Unlock()
+
+		proceeds, err := m.sell(snapshot, amount)
+
+		m.mutex.Lock()
+		if err != nil {
+			retries++
+			log.Printf("%s | Failed to sell %d (attempt %d): %v", ammId, amount, retries, err)
+		} else {
+			retries = 0
+			position.Remaining -= amount
+			position.Proceeds += proceeds
+			m.saveChunk(position)
+		}
+		m.mutex.Unlock()
+
+		if position.Remaining > 0 {
+			time.Sleep(EXIT_CHUNK_INTERVAL)
+		}
+	}
+}
+
+// The chunk stored for the pool, falling back to an even split of the position
+func (m *PositionManager) chunkSize(position *Position) uint64 {
+	chunk, err := bot.GetTokenChunk(&position.PoolKeys.ID)
+	if err == nil && chunk.Chunk != nil && chunk.Chunk.IsUint64() && chunk.Chunk.Uint64() > 0 {
+		return chunk.Chunk.Uint64()
+	}
+
+	return m.evenChunk(position)
+}
+
+func (m *PositionManager) saveChunk(position *Position) {
+	err := bot.SetTokenChunk(&position.PoolKeys.ID, types.TokenChunk{
+		Total:     new(big.Int).SetUint64(position.Tokens),
+		Remaining: new(big.Int).SetUint64(position.Remaining),
+		Chunk:     new(big.Int).SetUint64(m.evenChunk(position)),
+	})
+	if err != nil {
+		log.Printf("%s | Failed to store token chunk: %v", position.PoolKeys.ID, err)
+	}
+}
+
+// Chunks rounded up so the split leaves no dust for an extra sale
+func (m *PositionManager) evenChunk(position *Position) uint64 {
+	return max((position.Tokens+m.config.Chunks-1)/m.config.Chunks, 1)
+}
@@ -0,0 +1,490 @@
+package trader
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/builder"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/config"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/ledger"
+	bot "github.com/iqbalbaharum/lp-remove-tracker/internal/library"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/liquidity"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/rpc"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/submitter"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
+)
+
+const BASE_FEE_LAMPORTS = 5000
+
+// A paper entry still waiting for its landing slot after this long is filled at the pool's
+// latest reserve, which has not moved since the trigger
+const PAPER_LANDING_TIMEOUT = 30 * time.Second
+
+var (
+	ErrEntryTooSmall   = errors.New("entry size is zero after sizing")
+	ErrExiting         = errors.New("position in the pool is being exited")
+	ErrEntryPending    = errors.New("an entry in the pool is pending")
+	ErrExposureReached = errors.New("position in the pool is at the max exposure")
+	ErrSlippage        = errors.New("output is below the minimum amount out")
+)
+
+// Entry is the signal a strategy acts on, the swap that triggered it and where it landed
+type Entry struct {
+	PoolKeys         *types.RaydiumPoolKeys
+	TriggerSignature string
+	TriggerSlot      uint64
+	Blockhash        solana.Hash
+}
+
+// A paper entry waiting for the pool's reserve at the slot it would have landed in
+type paperLanding struct {
+	entry  Entry
+	mint   solana.PublicKey
+	params builder.SwapParams
+	slot   uint64
+}
+
+// Trader sizes, builds and executes entries for one strategy. In paper mode the transactions
+// are built for an unsigned owner and recorded instead of sent.
+type Trader struct {
+	strategy   string
+	mode       string
+	owner      solana.PublicKey
+	payer      solana.PrivateKey
+	racer      *submitter.Racer
+	simulation submitter.SimulationPolicy
+	paper      *PaperBook
+	positions  *PositionManager
+	// Pending paper entries by pool, at most one per pool since entries reserve their pool
+	landingMutex sync.Mutex
+	landings     map[solana.PublicKey]*paperLanding
+	ledger       *ledger.Ledger
+}
+
+func NewTrader(strategy string, mode string, payer solana.PrivateKey, racer *submitter.Racer, simulation submitter.SimulationPolicy, exitConfig ExitConfig) (*Trader, error) {
+	t := &Trader{
+		strategy:   strategy,
+		mode:       mode,
+		payer:      payer,
+		racer:      racer,
+		simulation: simulation,
+		ledger:     ledger.NewLedger(strategy, mode == config.TRADE_MODE_PAPER),
+	}
+
+	switch mode {
+	case config.TRADE_MODE_PAPER:
+		// The owner only has to exist for the transaction to be built
+		if payer == nil {
+			t.payer = solana.NewWallet().PrivateKey
+		}
+		t.paper = NewPaperBook()
+		t.landings = make(map[solana.PublicKey]*paperLanding)
+	case config.TRADE_MODE_LIVE:
+		if payer == nil {
+			return nil, errors.New("live trading needs PAYER_PRIVATE_KEY")
+		}
+		if racer == nil {
+			return nil, errors.New("live trading needs a submitter")
+		}
+	default:
+		return nil, fmt.Errorf("trade mode %q does not trade", mode)
+	}
+
+	t.owner = t.payer.PublicKey()
+	t.positions = NewPositionManager(exitConfig, t.sell, t.closed)
+
+	return t, nil
+}
+
+// Run enforces the time based exits and snapshots the unrealized PnL
+func (t *Trader) Run() {
+	go t.ledger.Run()
+	t.positions.Run()
+}
+
+// Enter buys the pool's token with WSOL, sized against the pool's SOL reserve and what the
+// pool's position can still take. One entry per pool is in flight at a time.
+func (t *Trader) Enter(entry Entry) error {
+	pKey := entry.PoolKeys
+
+	room, err := t.positions.Reserve(pKey.ID, config.EntryMaxPoolExposureLamports)
+	if err != nil {
+		return err
+	}
+
+	if err := t.enter(entry, room); err != nil {
+		t.positions.Release(pKey.ID)
+		return err
+	}
+
+	return nil
+}
+
+func (t *Trader) enter(entry Entry, room uint64) error {
+	pKey := entry.PoolKeys
+
+	mint, _, err := liquidity.GetMint(pKey)
+	if err != nil {
+		return err
+	}
+
+	reserve, err := liquidity.GetPoolReserve(pKey)
+	if err != nil {
+		return err
+	}
+	liquidity.WantPoolState(pKey.ID)
+
+	solReserve := reserve.Quote
+	if reserve.BaseMint == config.WRAPPED_SOL {
+		solReserve = reserve.Base
+	}
+
+	amountIn := min(config.EntryAmountLamports, solReserve*config.EntryMaxPoolBps/liquidity.BPS_DENOMINATOR, room)
+	if amountIn == 0 {
+		return ErrEntryTooSmall
+	}
+
+	quote, err := liquidity.ComputeAmountOut(reserve.PoolInfo(), amountIn, reserve.Direction(config.WRAPPED_SOL))
+	if err != nil {
+		return err
+	}
+
+	params := builder.SwapParams{
+		PoolKeys:         pKey,
+		Owner:            t.owner,
+		Side:             builder.BUY,
+		AmountIn:         amountIn,
+		AmountOut:        liquidity.MinimumAmountOut(quote.AmountOut, config.EntrySlippageBps),
+		ComputeUnitLimit: uint32(config.EntryComputeUnitLimit),
+		ComputeUnitPrice: config.EntryComputeUnitPrice,
+		RecentBlockhash:  entry.Blockhash,
+	}
+
+	if t.mode == config.TRADE_MODE_PAPER {
+		return t.enterPaper(entry, mint, params)
+	}
+
+	return t.enterLive(entry, mint, params, quote)
+}
+
+// Mark fills a paper entry that has reached its landing slot, then revalues the pool's position
+// and paper trades, which can trigger an exit
+func (t *Trader) Mark(reserve liquidity.PoolReserve) {
+	if t.paper != nil {
+		landing := t.takeLanding(reserve.AmmId, func(landing *paperLanding) bool { return reserve.Slot >= landing.slot })
+		if landing != nil {
+			t.landPaper(landing, reserve)
+		}
+		t.paper.Mark(reserve)
+	}
+	t.positions.Mark(reserve)
+}
+
+// OnWithdraw exits any position in a pool losing liquidity
+func (t *Trader) OnWithdraw(ammId solana.PublicKey) {
+	t.positions.OnWithdraw(ammId)
+}
+
+// The would-be transaction lands PaperLandingDelaySlots after the trigger, it is quoted once a
+// reserve at or past that slot is seen. The minimum amount out is the one signed at the trigger.
+func (t *Trader) enterPaper(entry Entry, mint solana.PublicKey, params builder.SwapParams) error {
+	withRelayTips(&params)
+	ammId := params.PoolKeys.ID
+
+	landing := &paperLanding{
+		entry:  entry,
+		mint:   mint,
+		params: params,
+		slot:   entry.TriggerSlot + config.PaperLandingDelaySlots,
+	}
+
+	t.landingMutex.Lock()
+	t.landings[ammId] = landing
+	t.landingMutex.Unlock()
+
+	time.AfterFunc(PAPER_LANDING_TIMEOUT, func() {
+		if t.takeLanding(ammId, func(pending *paperLanding) bool { return pending == landing }) == nil {
+			return
+		}
+
+		reserve, err := liquidity.GetPoolReserve(params.PoolKeys)
+		if err != nil {
+			log.Printf("%s | Paper %s entry dropped: %v", ammId, t.strategy, err)
+			t.positions.Release(ammId)
+			return
+		}
+		t.landPaper(landing, reserve)
+	})
+
+	return nil
+}
+
+// takeLanding removes and returns the pool's pending paper entry when due accepts it
+func (t *Trader) takeLanding(ammId solana.PublicKey, due func(landing *paperLanding) bool) *paperLanding {
+	t.landingMutex.Lock()
+	defer t.landingMutex.Unlock()
+
+	landing, ok := t.landings[ammId]
+	if !ok || !due(landing) {
+		return nil
+	}
+	delete(t.landings, ammId)
+
+	return landing
+}
+
+func (t *Trader) landPaper(landing *paperLanding, reserve liquidity.PoolReserve) {
+	defer t.positions.Release(reserve.AmmId)
+
+	if err := t.fillPaper(landing, reserve); err != nil {
+		log.Printf("%s | Paper %s entry not filled (Slot %d): %v", reserve.AmmId, t.strategy, landing.slot, err)
+	}
+}
+
+func (t *Trader) fillPaper(landing *paperLanding, reserve liquidity.PoolReserve) error {
+	params := landing.params
+
+	quote, err := liquidity.ComputeAmountOut(reserve.PoolInfo(), params.AmountIn, reserve.Direction(config.WRAPPED_SOL))
+	if err != nil {
+		return err
+	}
+	if quote.AmountOut < params.AmountOut {
+		return fmt.Errorf("%w: %d < %d", ErrSlippage, quote.AmountOut, params.AmountOut)
+	}
+
+	tx, err := builder.NewSwapTransaction(params)
+	if err != nil {
+		return err
+	}
+
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	trade := &types.PaperTrade{
+		Strategy:         t.strategy,
+		AmmId:            &params.PoolKeys.ID,
+		Mint:             &landing.mint,
+		Action:           bot.TRADE_BUY,
+		AmountIn:         quote.AmountIn,
+		AmountOut:        quote.AmountOut,
+		MinimumAmountOut: params.AmountOut,
+		PriceImpact:      quote.PriceImpact,
+		CostLamports:     quote.AmountIn + fees(params),
+		TriggerSignature: landing.entry.TriggerSignature,
+		TriggerSlot:      landing.entry.TriggerSlot,
+		LandingSlot:      landing.slot,
+		Transaction:      base64.StdEncoding.EncodeToString(data),
+	}
+
+	if err := t.paper.Open(trade); err != nil {
+		return err
+	}
+	t.positions.Open(params.PoolKeys, landing.mint, quote.AmountOut, trade.CostLamports, trade.LandingSlot)
+	t.record(paperFill(bot.TRADE_BUY, landing.mint, params, quote, trade.LandingSlot))
+
+	log.Printf("%s | Paper %s | %d lamports for %d (min %d, impact %.2f%%) (Slot %d)", params.PoolKeys.ID, t.strategy, quote.AmountIn, quote.AmountOut, params.AmountOut, quote.PriceImpact*100, trade.LandingSlot)
+
+	return nil
+}
+
+// The position is opened once the entry lands, at the settled amount when it can be fetched
+func (t *Trader) enterLive(entry Entry, mint solana.PublicKey, params builder.SwapParams, quote *liquidity.Quote) error {
+	withRelayTips(&params)
+
+	go func() {
+		defer t.positions.Release(params.PoolKeys.ID)
+
+		txs, err := t.sign(&params)
+		if err != nil {
+			log.Printf("%s | Entry not sent: %v", params.PoolKeys.ID, err)
+			return
+		}
+
+		race := t.racer.Submit(txs, submitter.STAGE_FINALIZED)
+		if race.Track.Landed() {
+			tokens, cost := quote.AmountOut, quote.AmountIn+fees(params)
+			if fill := t.recordLive(bot.TRADE_BUY, mint, params, race); fill != nil && fill.TokenIn > 0 {
+				tokens, cost = fill.TokenIn, fill.SolIn+fill.Costs()
+			}
+			t.positions.Open(params.PoolKeys, mint, tokens, cost, race.Track.Slot)
+		}
+	}()
+
+	return nil
+}
+
+// Sell amount of the position's tokens back to WSOL, blocking until the sale is confirmed so
+// the next chunk is not held up by finalization
+func (t *Trader) sell(position Position, amount uint64) (uint64, error) {
+	pKey := position.PoolKeys
+
+	reserve, err := liquidity.GetPoolReserve(pKey)
+	if err != nil {
+		return 0, err
+	}
+	liquidity.WantPoolState(pKey.ID)
+
+	if t.mode == config.TRADE_MODE_LIVE && amount == position.Remaining {
+		// The last chunk sells what is really held, the entry was only booked at its quote
+		tokenAccount, _, err := solana.FindAssociatedTokenAddress(t.owner, position.Mint)
+		if err != nil {
+			return 0, err
+		}
+
+		balance, _, err := rpc.GetTokenAccountBalance(tokenAccount)
+		if err != nil {
+			return 0, err
+		}
+		if balance == 0 {
+			return 0, nil
+		}
+		amount = min(amount, balance)
+	}
+
+	quote, err := liquidity.ComputeAmountOut(reserve.PoolInfo(), amount, reserve.Direction(position.Mint))
+	if err != nil {
+		return 0, err
+	}
+
+	params := builder.SwapParams{
+		PoolKeys:         pKey,
+		Owner:            t.owner,
+		Side:             builder.SELL,
+		AmountIn:         amount,
+		AmountOut:        liquidity.MinimumAmountOut(quote.AmountOut, config.ExitSlippageBps),
+		ComputeUnitLimit: uint32(config.EntryComputeUnitLimit),
+		ComputeUnitPrice: config.EntryComputeUnitPrice,
+	}
+
+	if t.mode == config.TRADE_MODE_PAPER {
+		return t.sellPaper(position, params, quote, reserve.Slot)
+	}
+
+	params.RecentBlockhash, err = rpc.GetLatestBlockhash()
+	if err != nil {
+		return 0, err
+	}
+
+	withRelayTips(&params)
+
+	txs, err := t.sign(&params)
+	if err != nil {
+		return 0, err
+	}
+
+	race := t.racer.Submit(txs, submitter.STAGE_CONFIRMED)
+
+	if !race.Track.Landed() {
+		return 0, fmt.Errorf("sell %s %s", race.Track.Stage, race.Track.Err)
+	}
+
+	if fill := t.recordLive(bot.TRADE_SELL, position.Mint, params, race); fill != nil {
+		return fill.SolOut - min(fill.SolOut, fill.Costs()), nil
+	}
+
+	return quote.AmountOut - min(quote.AmountOut, fees(params)), nil
+}
+
+func (t *Trader) sellPaper(position Position, params builder.SwapParams, quote *liquidity.Quote, slot uint64) (uint64, error) {
+	withRelayTips(&params)
+
+	tx, err := builder.NewSwapTransaction(params)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+
+	trade := &types.PaperTrade{
+		Strategy:         t.strategy,
+		AmmId:            &position.PoolKeys.ID,
+		Mint:             &position.Mint,
+		Action:           bot.TRADE_SELL,
+		AmountIn:         quote.AmountIn,
+		AmountOut:        quote.AmountOut,
+		MinimumAmountOut: params.AmountOut,
+		PriceImpact:      quote.PriceImpact,
+		CostLamports:     fees(params),
+		LandingSlot:      slot + config.PaperLandingDelaySlots,
+		MarkSlot:         slot + config.PaperLandingDelaySlots,
+		MarkValue:        quote.AmountOut,
+		Pnl:              int64(quote.AmountOut) - int64(fees(params)),
+		Transaction:      base64.StdEncoding.EncodeToString(data),
+	}
+
+	if err := bot.SetPaperTrade(trade); err != nil {
+		return 0, err
+	}
+	t.record(paperFill(bot.TRADE_SELL, position.Mint, params, quote, trade.LandingSlot))
+
+	return quote.AmountOut - min(quote.AmountOut, fees(params)), nil
+}
+
+// Settle the paper entries of a closed position at what the exit realized
+func (t *Trader) closed(position Position) {
+	if t.paper != nil {
+		t.paper.Close(position.PoolKeys.ID, position.Proceeds, position.Slot)
+	}
+}
+
+// sign builds the swap signed by the payer. When the strategy simulates it is signed again at
+// the compute unit limit the simulation measured, which params then carries for the fees.
+func (t *Trader) sign(params *builder.SwapParams) ([]*solana.Transaction, error) {
+	return submitter.Prepare(t.simulation, func(computeUnitLimit uint32) ([]*solana.Transaction, error) {
+		if computeUnitLimit > 0 {
+			params.ComputeUnitLimit = computeUnitLimit
+		}
+
+		tx, err := builder.BuildSwapTransaction(*params, t.payer)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*solana.Transaction{tx}, nil
+	})
+}
+
+func withRelayTip(params *builder.SwapParams, relay string) {
+	switch relay {
+	case submitter.RELAY_JITO:
+		params.Tips = append(params.Tips, builder.Tip{Account: config.GetJitoTipAddress(), Lamports: config.EntryTipLamports})
+	case submitter.RELAY_BLOXROUTE:
+		params.Tips = append(params.Tips, builder.Tip{Account: config.BLOXROUTE_TIP, Lamports: max(config.EntryTipLamports, config.BloxRouteTipLamports)})
+	}
+}
+
+// The same signed transaction goes through every relay so it can only land once, it carries
+// the tip of each relay that expects one
+func withRelayTips(params *builder.SwapParams) {
+	params.Tips = nil
+	for _, relay := range config.SubmitRelays {
+		withRelayTip(params, relay)
+	}
+}
+
+// Network, priority and tip fees paid on top of the swap input
+func fees(params builder.SwapParams) uint64 {
+	return BASE_FEE_LAMPORTS + priorityFee(params) + tipLamports(params)
+}
+
+func tipLamports(params builder.SwapParams) uint64 {
+	var total uint64
+	for _, tip := range params.Tips {
+		total += tip.Lamports
+	}
+	return total
+}
+
+func priorityFee(params builder.SwapParams) uint64 {
+	return uint64(params.ComputeUnitLimit) * params.ComputeUnitPrice / 1000000
+}
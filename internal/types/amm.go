@@ -1,15 +1,37 @@
 package types
 
 import (
-	"math/big"
-
 	"github.com/gagliardetto/solana-go"
 )
 
+// Amm is a row of the amms table. Pools are pieced together from several sources, so fields
+// left nil keep what is already stored.
 type Amm struct {
-	ammId     *solana.PublicKey
-	baseMint  *solana.PublicKey
-	quoteMint *solana.PublicKey
-	base      *big.Int
-	quote     *big.Int
+	AmmId           *solana.PublicKey
+	BaseMint        *solana.PublicKey
+	QuoteMint       *solana.PublicKey
+	LpMint          *solana.PublicKey
+	BaseVault       *solana.PublicKey
+	QuoteVault      *solana.PublicKey
+	MarketId        *solana.PublicKey
+	MarketProgramId *solana.PublicKey
+	BaseDecimals    *int
+	QuoteDecimals   *int
+	LpDecimals      *int
+	OpenTime        *uint64
+	InitPcAmount    *uint64
+	InitCoinAmount  *uint64
+	Creator         *solana.PublicKey
+	FirstSeenSlot   *uint64
+}
+
+type LpStatus string
+
+// LpState is what became of a pool's LP tokens. UnlockTime is nil when the lock is permanent
+// or its end is unknown.
+type LpState struct {
+	Status     LpStatus
+	UnlockTime *int64
+	Signature  string
+	Slot       uint64
 }
@@ -0,0 +1,43 @@
+package types
+
+import "github.com/gagliardetto/solana-go"
+
+// Fill is a trade as it settled, amounts in lamports and raw token units
+type Fill struct {
+	Id        int64
+	Strategy  string
+	Paper     bool
+	Signature string
+	AmmId     *solana.PublicKey
+	Mint      *solana.PublicKey
+	Action    string
+	SolIn     uint64
+	SolOut    uint64
+	TokenIn   uint64
+	TokenOut  uint64
+	// TokenUnmatched is the part of TokenOut no open lot held, its proceeds are not realized
+	TokenUnmatched uint64
+	Fee            uint64
+	PriorityFee    uint64
+	Tip            uint64
+	RealizedPnl    int64
+	Slot           uint64
+	BlockTime      int64
+}
+
+// Costs is everything paid to get the fill included
+func (f *Fill) Costs() uint64 {
+	return f.Fee + f.PriorityFee + f.Tip
+}
+
+// Lot is the unsold part of a buy, with its share of the buy's cost
+type Lot struct {
+	Id             int64
+	FillId         int64
+	Strategy       string
+	Paper          bool
+	AmmId          *solana.PublicKey
+	Mint           *solana.PublicKey
+	TokenRemaining uint64
+	CostRemaining  uint64
+}
@@ -1,7 +1,19 @@
 package types
 
 type GrpcConfig struct {
-	Addr               string
-	Token              string
-	InsecureConnection bool
+	Name               string     `json:"name"`
+	Addr               string     `json:"address"`
+	Token              string     `json:"token"`
+	TLS                string     `json:"tls"`
+	Commitment         string     `json:"commitment"`
+	Filters            GrpcFilter `json:"filters"`
+	InsecureConnection bool       `json:"-"`
+}
+
+type GrpcFilter struct {
+	AccountInclude  []string `json:"accountInclude"`
+	AccountExclude  []string `json:"accountExclude"`
+	AccountRequired []string `json:"accountRequired"`
+	Vote            bool     `json:"vote"`
+	Failed          bool     `json:"failed"`
 }
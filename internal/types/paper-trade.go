@@ -0,0 +1,25 @@
+package types
+
+import "github.com/gagliardetto/solana-go"
+
+type PaperTrade struct {
+	Id               int64
+	Strategy         string
+	AmmId            *solana.PublicKey
+	Mint             *solana.PublicKey
+	Action           string
+	AmountIn         uint64
+	AmountOut        uint64
+	MinimumAmountOut uint64
+	PriceImpact      float64
+	// CostLamports is the SOL spent including the network fee, priority fee and tip
+	CostLamports     uint64
+	TriggerSignature string
+	TriggerSlot      uint64
+	LandingSlot      uint64
+	MarkSlot         uint64
+	MarkValue        uint64
+	Pnl              int64
+	Transaction      string
+	Timestamp        int64
+}
@@ -2,8 +2,32 @@ package types
 
 import "github.com/gagliardetto/solana-go"
 
+type TrackerStatus string
+
 type Tracker struct {
 	AmmId       *solana.PublicKey
-	Status      string
+	Status      TrackerStatus
 	LastUpdated int64
+	// What moved the tracker into Status
+	Reason    string
+	Signature string
+	Slot      uint64
+}
+
+// TrackerCause is why a tracker changes status, with the transaction that triggered it if any
+type TrackerCause struct {
+	Reason    string
+	Signature string
+	Slot      uint64
+}
+
+// TrackerTransition is one row of a pool's tracking history
+type TrackerTransition struct {
+	AmmId     *solana.PublicKey
+	From      TrackerStatus
+	To        TrackerStatus
+	Reason    string
+	Signature string
+	Slot      uint64
+	Timestamp int64
 }
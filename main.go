@@ -5,20 +5,31 @@ import (
 	"fmt"
 	"log"
 	"math/big"
+	"os"
 	"runtime"
+	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "go.uber.org/automaxprocs"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/iqbalbaharum/lp-remove-tracker/internal/adapter"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/arbitrage"
 	"github.com/iqbalbaharum/lp-remove-tracker/internal/coder"
 	"github.com/iqbalbaharum/lp-remove-tracker/internal/config"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/dedup"
 	"github.com/iqbalbaharum/lp-remove-tracker/internal/generators"
 	bot "github.com/iqbalbaharum/lp-remove-tracker/internal/library"
 	"github.com/iqbalbaharum/lp-remove-tracker/internal/liquidity"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/rpc"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/rules"
 	"github.com/iqbalbaharum/lp-remove-tracker/internal/storage"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/submitter"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/tracker"
+	"github.com/iqbalbaharum/lp-remove-tracker/internal/trader"
 	"github.com/iqbalbaharum/lp-remove-tracker/internal/types"
 )
 
@@ -32,8 +43,13 @@ var (
 	wsolTokenAccount solana.PublicKey
 	wg               sync.WaitGroup
 	txChannel        chan generators.GeyserResponse
+	routeFinder      *arbitrage.RouteFinder
+	entryTrader      *trader.Trader
+	withdrawRules    *rules.RuleSet
 )
 
+const STRATEGY_TRIGGER_ENTRY = "trigger-entry"
+
 func main() {
 	numCPU := runtime.NumCPU() * 2
 	maxProcs := runtime.GOMAXPROCS(0)
@@ -62,21 +78,60 @@ func main() {
 		return
 	}
 
-	log.Print("Initialized ENVIRONMENT successfully")
-
-	client, err := generators.GrpcConnect(config.GRPC1.Addr, config.GRPC1.InsecureConnection)
-	client2, err := generators.GrpcConnect(config.GRPC2.Addr, config.GRPC2.InsecureConnection)
-
-	grpcs = append(grpcs, client, client2)
+	withdrawRules = rules.DefaultWithdrawRules()
+	if _, err := os.Stat(config.WithdrawRulesFile); err == nil {
+		withdrawRules, err = rules.LoadRules(config.WithdrawRulesFile)
+		if err != nil {
+			log.Fatalf("Failed to load withdraw rules: %v", err)
+			return
+		}
+		log.Printf("Loaded %d withdraw rules from %s", len(withdrawRules.Rules), config.WithdrawRulesFile)
+	}
 
+	err = bot.MigrateTrackerIndex()
 	if err != nil {
-		log.Fatalf("Error in GRPC connection: %s ", err)
+		log.Fatalf(fmt.Sprintf("Failed to index trackers: %v", err))
 		return
 	}
 
+	log.Print("Initialized ENVIRONMENT successfully")
+
+	for _, source := range config.GrpcSources {
+		client, err := generators.GrpcConnect(source.Addr, source.InsecureConnection)
+		if err != nil {
+			log.Fatalf("%s | Error in GRPC connection: %s ", source.Name, err)
+			return
+		}
+
+		grpcs = append(grpcs, client)
+	}
+
 	txChannel = make(chan generators.GeyserResponse)
 
-	var processed sync.Map
+	routeFinder = arbitrage.NewRouteFinder(arbitrage.Config{
+		TipLamports:         config.ArbTipLamports,
+		PriorityFeeLamports: config.ArbPriorityFee,
+		MinProfitLamports:   config.ArbMinProfit,
+		MaxInputLamports:    config.ArbMaxInput,
+		MaxOpportunities:    10,
+	})
+	go routeFinder.Run()
+	go reportOpportunities(routeFinder)
+
+	if config.TradeMode != config.TRADE_MODE_OFF {
+		entryTrader, err = newTrader(STRATEGY_TRIGGER_ENTRY)
+		if err != nil {
+			log.Fatalf("Failed to initialize %s trader: %v", config.TradeMode, err)
+			return
+		}
+		go entryTrader.Run()
+		log.Printf("Trading %s in %s mode", STRATEGY_TRIGGER_ENTRY, config.TradeMode)
+	}
+
+	go tracker.NewEvaluator(tracker.EvaluatorConfigFromEnv()).Run()
+
+	deduplicator := dedup.NewDeduplicator(1*time.Minute, 10000)
+	go reportSourceRace(deduplicator, 1*time.Minute)
 
 	// Create a worker pool
 	for i := 0; i < numCPU; i++ {
@@ -84,32 +139,19 @@ func main() {
 		go func() {
 			defer wg.Done()
 			for response := range txChannel {
-				if _, exists := processed.Load(response.MempoolTxns.Signature); !exists {
-					processed.Store(response.MempoolTxns.Signature, true)
+				tx := response.MempoolTxns
+				if deduplicator.Observe(tx.Signature, tx.Source, tx.ReceivedAt) {
 					processResponse(response)
-
-					time.AfterFunc(1*time.Minute, func() {
-						processed.Delete(response.MempoolTxns.Signature)
-					})
 				}
 			}
 		}()
 	}
 
-	// wg.Add(1)
-	listenFor(
-		grpcs[0],
-		"triton",
-		[]string{
-			config.RAYDIUM_AMM_V4.String(),
-		}, txChannel, &wg)
+	go subscribeLpMints(grpcs, time.Duration(config.LpMintFilterRefreshSeconds)*time.Second)
 
-	listenFor(
-		grpcs[1],
-		"solana-tracker",
-		[]string{
-			config.RAYDIUM_AMM_V4.String(),
-		}, txChannel, &wg)
+	for i, source := range config.GrpcSources {
+		listenFor(grpcs[i], source, txChannel, &wg)
+	}
 
 	wg.Wait()
 
@@ -123,50 +165,391 @@ func main() {
 	}
 }
 
-// Listening geyser for new addresses
-func listenFor(client *generators.GrpcClient, name string, addresses []string, txChannel chan generators.GeyserResponse, wg *sync.WaitGroup) {
+// Paper traders never send, so they skip building the relay clients
+func newTrader(strategy string) (*trader.Trader, error) {
+	var racer *submitter.Racer
+
+	if config.TradeMode == config.TRADE_MODE_LIVE {
+		submitters, err := submitter.NewSubmitters(config.SubmitRelays)
+		if err != nil {
+			return nil, err
+		}
+		racer = submitter.NewRacer(submitters)
+	}
+
+	policy := submitter.SIMULATE
+	if slices.Contains(config.SkipSimulationStrategies, strategy) {
+		policy = submitter.SKIP_SIMULATION
+	}
+
+	return trader.NewTrader(strategy, config.TradeMode, config.PayerPrivateKey, racer, policy, trader.ExitConfigFromEnv())
+}
+
+func reportOpportunities(finder *arbitrage.RouteFinder) {
+	for opportunities := range finder.Opportunities() {
+		best := opportunities[0]
+
+		route := best.Hops[0].InputMint.String()
+		for _, hop := range best.Hops {
+			route += fmt.Sprintf(" -(%s)-> %s", hop.AmmId, hop.OutputMint)
+		}
+
+		log.Printf("Arbitrage | %d found | Best net %d lamports on %d in (Slot %d) | %s", len(opportunities), best.NetProfit, best.AmountIn, best.Slot, route)
+	}
+}
+
+// Log which Geyser source is winning the race for each signature
+func reportSourceRace(deduplicator *dedup.Deduplicator, interval time.Duration) {
+	for range time.Tick(interval) {
+		for _, s := range deduplicator.Flush() {
+			log.Printf("%s | Won %.2f%% | Wins %d | Delivered %d | Lag p50 %s p99 %s (%d samples) | Exclusive %d",
+				s.Source, s.WinRate*100, s.Wins, s.Delivered, s.LagP50, s.LagP99, s.Samples, s.Exclusive)
+		}
+	}
+}
+
+// Listening geyser for the source's configured filters
+func listenFor(client *generators.GrpcClient, source types.GrpcConfig, txChannel chan generators.GeyserResponse, wg *sync.WaitGroup) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		err := client.GrpcSubscribeByAddresses(
-			name,
-			config.GrpcToken,
-			addresses,
-			[]string{}, txChannel)
+		err := client.GrpcSubscribe(source, txChannel)
 		if err != nil {
-			log.Printf("Error in first gRPC subscription: %v", err)
+			log.Printf("%s | Subscription stopped: %v", source.Name, err)
 		}
 	}()
 }
 
+const LP_MINT_FILTER = "lp_mints"
+
+// The LP mints streamed by the LP mint filter
+var lpMints atomic.Pointer[map[solana.PublicKey]bool]
+
+// Stream the transactions touching the known pools' LP mints, which burns always do. Pools are
+// only picked up on the next refresh after they are stored.
+func subscribeLpMints(clients []*generators.GrpcClient, interval time.Duration) {
+	var subscribed []string
+
+	for ; ; time.Sleep(interval) {
+		mints, err := bot.GetLpMints(int(config.LpMintFilterLimit))
+		if err != nil {
+			log.Printf("Failed to list LP mints: %v", err)
+			continue
+		}
+
+		known := make(map[solana.PublicKey]bool, len(mints))
+		accounts := make([]string, 0, len(mints))
+		for _, mint := range mints {
+			known[mint] = true
+			accounts = append(accounts, mint.String())
+		}
+		lpMints.Store(&known)
+
+		if slices.Equal(accounts, subscribed) {
+			continue
+		}
+
+		for i, client := range clients {
+			if err := client.SetAccountFilter(LP_MINT_FILTER, accounts); err != nil {
+				log.Printf("%s | Failed to update the LP mint filter: %v", config.GrpcSources[i].Name, err)
+			}
+		}
+		subscribed = accounts
+	}
+}
+
+func isKnownLpMint(mint solana.PublicKey) bool {
+	known := lpMints.Load()
+	return known != nil && (*known)[mint]
+}
+
 func processResponse(response generators.GeyserResponse) {
 	latestBlockhash = response.MempoolTxns.RecentBlockhash
 
+	withdrawn := false
+
 	c := coder.NewRaydiumAmmInstructionCoder()
-	for _, ins := range response.MempoolTxns.Instructions {
-		programId := response.MempoolTxns.AccountKeys[ins.ProgramIdIndex]
+	for _, ins := range getInstructions(response.MempoolTxns) {
+		programId, err := getAccountKeyFromTx(int(ins.ProgramIdIndex), response.MempoolTxns)
+		if err != nil {
+			continue
+		}
 
-		if programId == config.RAYDIUM_AMM_V4.String() {
+		if *programId == config.RAYDIUM_AMM_V4 {
 			decodedIx, err := c.Decode(ins.Data)
 			if err != nil {
 				continue
 			}
 
-			switch decodedIx.(type) {
+			if ammIndex, ok := coder.AmmAccountIndex(decodedIx); ok {
+				trackPoolReserve(ammIndex, ins, response.MempoolTxns)
+			}
+
+			switch ix := decodedIx.(type) {
 			case coder.Initialize2:
 				log.Printf("Initialize2 | %s | %s", response.MempoolTxns.Source, response.MempoolTxns.Signature)
-				processInitialize2(ins, response)
+				processInitialize2(ix, ins, response)
+			case coder.Deposit:
+				log.Printf("Deposit | %s | %s", response.MempoolTxns.Source, response.MempoolTxns.Signature)
+				processDeposit(ins, response)
 			case coder.Withdraw:
 				log.Printf("Withdraw | %s | %s", response.MempoolTxns.Source, response.MempoolTxns.Signature)
-				processWithdraw(ins, response)
+				withdrawn = true
+				processWithdraw(ix, ins, response)
 			case coder.SwapBaseIn:
-				processSwapBaseIn(ins, response)
+				processSwap("SwapBaseIn", ins, response)
 			case coder.SwapBaseOut:
+				processSwap("SwapBaseOut", ins, response)
+			case coder.SetParams:
+				processAdminAction(fmt.Sprintf("SetParams(%d)", ix.Param), coder.SetParamsAccounts.Amm, ins, response)
+			case coder.AdminCancelOrders:
+				processAdminAction("AdminCancelOrders", coder.AdminCancelOrdersAccounts.Amm, ins, response)
+			case coder.WithdrawPnl:
+				processAdminAction("WithdrawPnl", coder.WithdrawPnlAccounts.Amm, ins, response)
+			case coder.WithdrawSrm:
+				processAdminAction("WithdrawSrm", coder.WithdrawSrmAccounts.Amm, ins, response)
+			case coder.MigrateToOpenBook:
+				processAdminAction("MigrateToOpenBook", coder.MigrateToOpenBookAccounts.Amm, ins, response)
+			case coder.Initialize, coder.PreInitialize, coder.MonitorStep, coder.SimulateInfo:
+			case coder.CreateConfigAccount, coder.UpdateConfigAccount:
+				log.Printf("%T | %s | %s", ix, response.MempoolTxns.Source, response.MempoolTxns.Signature)
 			default:
 				log.Println("Unknown instruction type")
 			}
 		}
 	}
+
+	if response.MempoolTxns.Error == "" {
+		processLpEvents(response.MempoolTxns, withdrawn)
+	}
+}
+
+// Look for LP burned, sent to a locker or moved away from the creator. Burns in a withdraw are
+// the AMM burning the LP it pays out, so they are skipped.
+func processLpEvents(tx generators.MempoolTxn, withdrawn bool) {
+	for _, ins := range getInstructionsOutsideLockers(tx) {
+		ix, ok := decodeTokenInstruction(ins, tx)
+		if !ok || !ix.IsTransfer() {
+			continue
+		}
+
+		mint, owner, ok := transferSource(ins, tx)
+		if !ok || !isKnownLpMint(mint) {
+			continue
+		}
+
+		dropCreatorLpStatus(mint, owner, ix.Amount, tx)
+	}
+
+	if !withdrawn {
+		for _, ins := range getInstructions(tx) {
+			ix, ok := decodeTokenInstruction(ins, tx)
+			if !ok || !ix.IsBurn() {
+				continue
+			}
+
+			mint, err := getPublicKeyFromTx(coder.TokenBurnAccounts.Mint, tx, ins)
+			if err != nil || mint == nil || !isKnownLpMint(*mint) {
+				continue
+			}
+
+			recordLpStatus(*mint, ix.Amount, types.LpState{Status: storage.LP_STATUS_BURNED}, tx)
+		}
+	}
+
+	for _, inner := range tx.InnerInstructions {
+		if int(inner.Index) >= len(tx.Instructions) {
+			continue
+		}
+
+		top := tx.Instructions[inner.Index]
+		programId, err := getAccountKeyFromTx(int(top.ProgramIdIndex), tx)
+		if err != nil || !slices.Contains(config.LpLockers, *programId) {
+			continue
+		}
+
+		for _, ins := range inner.Instructions {
+			ix, ok := decodeTokenInstruction(ins, tx)
+			if !ok || !ix.IsTransfer() {
+				continue
+			}
+
+			mint, locked := lockedTransferMint(ix, ins, tx)
+			if !locked || !isKnownLpMint(mint) {
+				continue
+			}
+
+			state := types.LpState{Status: storage.LP_STATUS_LOCKED}
+			// Raydium's locker never releases, Streamflow's unlock time is in its create instruction
+			if *programId == config.STREAMFLOW_ID {
+				if unlockTime, ok := coder.DecodeStreamflowUnlockTime(top.Data); ok {
+					state.UnlockTime = &unlockTime
+				}
+			}
+
+			recordLpStatus(mint, ix.Amount, state, tx)
+		}
+	}
+}
+
+func decodeTokenInstruction(ins generators.TxInstruction, tx generators.MempoolTxn) (coder.TokenInstruction, bool) {
+	programId, err := getAccountKeyFromTx(int(ins.ProgramIdIndex), tx)
+	if err != nil || (*programId != config.TOKEN_PROGRAM_ID && *programId != solana.Token2022ProgramID) {
+		return coder.TokenInstruction{}, false
+	}
+
+	ix, err := coder.DecodeTokenInstruction(ins.Data)
+	return ix, err == nil
+}
+
+// The mint of a transfer into an account owned by a program, as escrows are. Transfers to a
+// wallet are the locker paying tokens out.
+func lockedTransferMint(ix coder.TokenInstruction, ins generators.TxInstruction, tx generators.MempoolTxn) (solana.PublicKey, bool) {
+	destination := coder.TokenTransferAccounts.Destination
+	if ix.Checked {
+		destination = coder.TokenTransferCheckedAccounts.Destination
+	}
+	if destination >= len(ins.Accounts) {
+		return solana.PublicKey{}, false
+	}
+
+	for _, balance := range tx.PostTokenBalances {
+		if balance.AccountIndex != uint32(ins.Accounts[destination]) {
+			continue
+		}
+
+		mint, err := solana.PublicKeyFromBase58(balance.Mint)
+		if err != nil {
+			return solana.PublicKey{}, false
+		}
+		owner, err := solana.PublicKeyFromBase58(balance.Owner)
+		if err != nil || solana.IsOnCurve(owner[:]) {
+			return solana.PublicKey{}, false
+		}
+
+		return mint, true
+	}
+
+	return solana.PublicKey{}, false
+}
+
+// Top-level instructions and the ones invoked through CPI by anything but a locker
+func getInstructionsOutsideLockers(tx generators.MempoolTxn) []generators.TxInstruction {
+	instructions := append([]generators.TxInstruction{}, tx.Instructions...)
+	for _, inner := range tx.InnerInstructions {
+		if int(inner.Index) >= len(tx.Instructions) {
+			continue
+		}
+
+		programId, err := getAccountKeyFromTx(int(tx.Instructions[inner.Index].ProgramIdIndex), tx)
+		if err != nil || slices.Contains(config.LpLockers, *programId) {
+			continue
+		}
+		instructions = append(instructions, inner.Instructions...)
+	}
+
+	return instructions
+}
+
+// The mint and owner of the account a transfer spends from, as of before the transaction
+func transferSource(ins generators.TxInstruction, tx generators.MempoolTxn) (solana.PublicKey, solana.PublicKey, bool) {
+	// Both transfer variants take the source first
+	source := coder.TokenTransferAccounts.Source
+	if source >= len(ins.Accounts) {
+		return solana.PublicKey{}, solana.PublicKey{}, false
+	}
+
+	for _, balance := range tx.PreTokenBalances {
+		if balance.AccountIndex != uint32(ins.Accounts[source]) {
+			continue
+		}
+
+		mint, err := solana.PublicKeyFromBase58(balance.Mint)
+		if err != nil {
+			return solana.PublicKey{}, solana.PublicKey{}, false
+		}
+		owner, err := solana.PublicKeyFromBase58(balance.Owner)
+		if err != nil {
+			return solana.PublicKey{}, solana.PublicKey{}, false
+		}
+
+		return mint, owner, true
+	}
+
+	return solana.PublicKey{}, solana.PublicKey{}, false
+}
+
+// Drop the creator status of the pool minting the LP once its creator moved a large enough
+// share of it anywhere but a locker
+func dropCreatorLpStatus(lpMint solana.PublicKey, owner solana.PublicKey, amount uint64, tx generators.MempoolTxn) {
+	ammId, err := bot.GetAmmIdByLpMint(lpMint)
+	if err != nil || ammId == nil {
+		return
+	}
+
+	state, err := bot.GetAmmLpStatus(ammId)
+	if err != nil || state == nil || state.Status != storage.LP_STATUS_CREATOR {
+		return
+	}
+
+	creator, err := bot.GetAmmCreator(ammId)
+	if err != nil || creator == nil || *creator != owner {
+		return
+	}
+
+	share, err := liquidity.GetLpShareBps(lpMint, amount, tx.Slot, false)
+	if err != nil {
+		log.Printf("%s | %v", ammId, err)
+		return
+	}
+
+	log.Printf("%s | LP moved by creator | %d bps of supply | %s", ammId, share, tx.Signature)
+	if share < config.LpStatusMinBps {
+		return
+	}
+
+	if err := bot.DropAmmLpCreatorStatus(ammId, tx.Signature, tx.Slot); err != nil {
+		log.Printf("%s | %v", ammId, err)
+	}
+}
+
+// Attach the LP status to the pool minting the LP, when amount is a large enough share of it
+func recordLpStatus(lpMint solana.PublicKey, amount uint64, state types.LpState, tx generators.MempoolTxn) {
+	ammId, err := bot.GetAmmIdByLpMint(lpMint)
+	if err != nil {
+		log.Printf("%s | %v", lpMint, err)
+		return
+	}
+	if ammId == nil {
+		return
+	}
+
+	share, err := liquidity.GetLpShareBps(lpMint, amount, tx.Slot, state.Status == storage.LP_STATUS_BURNED)
+	if err != nil {
+		log.Printf("%s | %v", ammId, err)
+		return
+	}
+
+	log.Printf("%s | LP %s | %d bps of supply | %s", ammId, state.Status, share, tx.Signature)
+	if share < config.LpStatusMinBps {
+		return
+	}
+
+	state.Signature = tx.Signature
+	state.Slot = tx.Slot
+	if err := bot.SetAmmLpStatus(ammId, state); err != nil {
+		log.Printf("%s | %v", ammId, err)
+	}
+}
+
+// Top-level instructions followed by the ones invoked through CPI, so swaps
+// routed by aggregators and bots go through the same processing
+func getInstructions(tx generators.MempoolTxn) []generators.TxInstruction {
+	instructions := append([]generators.TxInstruction{}, tx.Instructions...)
+	for _, inner := range tx.InnerInstructions {
+		instructions = append(instructions, inner.Instructions...)
+	}
+	return instructions
 }
 
 func getPublicKeyFromTx(pos int, tx generators.MempoolTxn, instruction generators.TxInstruction) (*solana.PublicKey, error) {
@@ -175,34 +558,95 @@ func getPublicKeyFromTx(pos int, tx generators.MempoolTxn, instruction generator
 		return nil, errors.New("no account indexes provided")
 	}
 
-	lookupsForAccountKeyIndex := bot.GenerateTableLookup(tx.AddressTableLookups)
-	var ammId *solana.PublicKey
-	accountIndex := int(accountIndexes[pos])
+	if pos >= len(accountIndexes) {
+		return nil, errors.New("account position out of range")
+	}
 
-	if accountIndex >= len(tx.AccountKeys) {
-		lookupIndex := accountIndex - len(tx.AccountKeys)
-		lookup := lookupsForAccountKeyIndex[lookupIndex]
-		table, err := bot.GetLookupTable(solana.MustPublicKeyFromBase58(lookup.LookupTableKey))
+	return getAccountKeyFromTx(int(accountIndexes[pos]), tx)
+}
+
+// Resolve an index into the transaction's account list, including addresses loaded from lookup tables
+func getAccountKeyFromTx(accountIndex int, tx generators.MempoolTxn) (*solana.PublicKey, error) {
+	if accountIndex < len(tx.AccountKeys) {
+		key, err := solana.PublicKeyFromBase58(tx.AccountKeys[accountIndex])
 		if err != nil {
 			return nil, err
 		}
+		return &key, nil
+	}
+
+	lookupsForAccountKeyIndex := bot.GenerateTableLookup(tx.AddressTableLookups)
+	lookupIndex := accountIndex - len(tx.AccountKeys)
+	if lookupIndex >= len(lookupsForAccountKeyIndex) {
+		return nil, errors.New("account index out of range")
+	}
+
+	lookup := lookupsForAccountKeyIndex[lookupIndex]
+	table, err := bot.GetLookupTable(solana.MustPublicKeyFromBase58(lookup.LookupTableKey))
+	if err != nil {
+		return nil, err
+	}
+
+	if int(lookup.LookupTableIndex) >= len(table.Addresses) {
+		return nil, errors.New("lookup table index out of range")
+	}
+
+	return &table.Addresses[lookup.LookupTableIndex], nil
+}
+
+/**
+* Update the pool's reserve from the vault balances this transaction left behind
+ */
+func trackPoolReserve(ammIndex int, ins generators.TxInstruction, tx generators.MempoolTxn) {
+	if tx.Error != "" {
+		return
+	}
+
+	ammId, err := getPublicKeyFromTx(ammIndex, tx, ins)
+	if err != nil {
+		return
+	}
+
+	pKey, err := liquidity.GetPoolKeys(ammId)
+	if err != nil {
+		return
+	}
+
+	var base, quote *uint64
+	for _, balance := range tx.PostTokenBalances {
+		if balance.Owner != config.RAYDIUM_AUTHORITY.String() {
+			continue
+		}
 
-		if int(lookup.LookupTableIndex) >= len(table.Addresses) {
-			return nil, errors.New("lookup table index out of range")
+		account, err := getAccountKeyFromTx(int(balance.AccountIndex), tx)
+		if err != nil {
+			continue
 		}
 
-		ammId = &table.Addresses[lookup.LookupTableIndex]
+		amount, err := strconv.ParseUint(balance.Amount, 10, 64)
+		if err != nil {
+			continue
+		}
 
-	} else {
-		key := solana.MustPublicKeyFromBase58(tx.AccountKeys[accountIndex])
-		ammId = &key
+		switch *account {
+		case pKey.BaseVault:
+			base = &amount
+		case pKey.QuoteVault:
+			quote = &amount
+		}
 	}
 
-	return ammId, nil
+	if reserve, updated := liquidity.UpdatePoolReserve(pKey, base, quote, tx.Slot); updated {
+		bot.SnapshotAmmReserve(reserve.AmmId, reserve.BaseMint, reserve.QuoteMint, reserve.Base, reserve.Quote, reserve.Slot)
+		routeFinder.Update(reserve)
+		if entryTrader != nil {
+			entryTrader.Mark(reserve)
+		}
+	}
 }
 
-func processInitialize2(ins generators.TxInstruction, tx generators.GeyserResponse) {
-	ammId, err := getPublicKeyFromTx(4, tx.MempoolTxns, ins)
+func processInitialize2(ix coder.Initialize2, ins generators.TxInstruction, tx generators.GeyserResponse) {
+	ammId, err := getPublicKeyFromTx(coder.Initialize2Accounts.Amm, tx.MempoolTxns, ins)
 	if err != nil {
 		return
 	}
@@ -212,6 +656,10 @@ func processInitialize2(ins generators.TxInstruction, tx generators.GeyserRespon
 		return
 	}
 
+	if tx.MempoolTxns.Error == "" {
+		storeInitialize2(ammId, ix, ins, tx.MempoolTxns)
+	}
+
 	tracker, err := bot.GetAmmTrackingStatus(ammId)
 	if err != nil {
 		log.Print(err)
@@ -220,12 +668,58 @@ func processInitialize2(ins generators.TxInstruction, tx generators.GeyserRespon
 
 	if tracker.Status == storage.TRACKED_TRIGGER_ONLY || tracker.Status == storage.TRACKED_BOTH {
 		log.Printf("%s | Untracked because of initialize2", ammId)
-		bot.PauseAmmTracking(ammId)
+		err := bot.PauseAmmTracking(ammId, types.TrackerCause{
+			Reason:    storage.REASON_INITIALIZE2,
+			Signature: tx.MempoolTxns.Signature,
+			Slot:      tx.MempoolTxns.Slot,
+		})
+		if err != nil {
+			log.Printf("%s | %v", ammId, err)
+		}
+	}
+}
+
+// Record the pool as its creator set it up
+func storeInitialize2(ammId *solana.PublicKey, ix coder.Initialize2, ins generators.TxInstruction, tx generators.MempoolTxn) {
+	account := func(pos int) *solana.PublicKey {
+		key, err := getPublicKeyFromTx(pos, tx, ins)
+		if err != nil {
+			return nil
+		}
+		return key
+	}
+
+	amm := &types.Amm{
+		AmmId:           ammId,
+		BaseMint:        account(coder.Initialize2Accounts.CoinMint),
+		QuoteMint:       account(coder.Initialize2Accounts.PcMint),
+		LpMint:          account(coder.Initialize2Accounts.LpMint),
+		BaseVault:       account(coder.Initialize2Accounts.PoolCoinVault),
+		QuoteVault:      account(coder.Initialize2Accounts.PoolPcVault),
+		MarketId:        account(coder.Initialize2Accounts.Market),
+		MarketProgramId: account(coder.Initialize2Accounts.MarketProgram),
+		OpenTime:        &ix.OpenTime,
+		InitPcAmount:    &ix.InitPcAmount,
+		InitCoinAmount:  &ix.InitCoinAmount,
+		Creator:         account(coder.Initialize2Accounts.UserWallet),
+		FirstSeenSlot:   &tx.Slot,
 	}
+
+	go func() {
+		if err := bot.SetAmm(amm); err != nil {
+			log.Printf("%s | %v", ammId, err)
+		}
+
+		// Initialize2 mints the LP to the creator
+		state := types.LpState{Status: storage.LP_STATUS_CREATOR, Signature: tx.Signature, Slot: tx.Slot}
+		if err := bot.SetAmmLpStatus(ammId, state); err != nil {
+			log.Printf("%s | %v", ammId, err)
+		}
+	}()
 }
 
-func processWithdraw(ins generators.TxInstruction, tx generators.GeyserResponse) {
-	ammId, err := getPublicKeyFromTx(1, tx.MempoolTxns, ins)
+func processDeposit(ins generators.TxInstruction, tx generators.GeyserResponse) {
+	ammId, err := getPublicKeyFromTx(coder.DepositAccounts.Amm, tx.MempoolTxns, ins)
 	if err != nil {
 		return
 	}
@@ -235,32 +729,158 @@ func processWithdraw(ins generators.TxInstruction, tx generators.GeyserResponse)
 		return
 	}
 
+	tracker, err := bot.GetAmmTrackingStatus(ammId)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+
+	cause := types.TrackerCause{
+		Reason:    storage.REASON_LIQUIDITY_ADDED,
+		Signature: tx.MempoolTxns.Signature,
+		Slot:      tx.MempoolTxns.Slot,
+	}
+
+	// New liquidity promotes a tracked pool and resumes one paused before the deposit
+	switch tracker.Status {
+	case storage.TRACKED_TRIGGER_ONLY:
+		err = bot.TransitionAmmFrom(ammId, storage.TRACKED_TRIGGER_ONLY, storage.TRACKED_BOTH, cause)
+	case storage.PAUSE:
+		if tx.MempoolTxns.Slot <= tracker.Slot {
+			return
+		}
+		err = bot.TransitionAmmFrom(ammId, storage.PAUSE, storage.TRACKED_TRIGGER_ONLY, cause)
+	case storage.TRACKED_BOTH:
+		log.Printf("%s | Liquidity added to tracked pool | %s", ammId, tx.MempoolTxns.Signature)
+	}
+
+	if err != nil && !errors.Is(err, bot.ErrTrackerChanged) {
+		log.Printf("%s | %v", ammId, err)
+	}
+}
+
+/**
+* Admin instructions change the pool itself (params, orders, pnl), log them with the pool they touched
+ */
+func processAdminAction(name string, ammIndex int, ins generators.TxInstruction, tx generators.GeyserResponse) {
+	ammId, err := getPublicKeyFromTx(ammIndex, tx.MempoolTxns, ins)
+	if err != nil || ammId == nil {
+		return
+	}
+
+	tracker, err := bot.GetAmmTrackingStatus(ammId)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+
+	log.Printf("%s | %s | %s | %s | %s", ammId, name, tracker.Status, tx.MempoolTxns.Source, tx.MempoolTxns.Signature)
+}
+
+func processWithdraw(ix coder.Withdraw, ins generators.TxInstruction, tx generators.GeyserResponse) {
+	ammId, err := getPublicKeyFromTx(coder.WithdrawAccounts20.Amm, tx.MempoolTxns, ins)
+	if err != nil {
+		return
+	}
+
+	if ammId == nil {
+		log.Print("Unable to retrieve AMM ID")
+		return
+	}
+
+	if entryTrader != nil && tx.MempoolTxns.Error == "" {
+		entryTrader.OnWithdraw(*ammId)
+	}
+
 	pKey, err := liquidity.GetPoolKeys(ammId)
 	if err != nil {
 		log.Printf("%s | %s", ammId, err)
 		return
 	}
 
-	time.Sleep(time.Duration(500) * time.Millisecond)
-
-	reserve, err := liquidity.GetPoolSolBalance(pKey)
+	reserve, err := liquidity.GetPoolSolReserve(pKey)
 	if err != nil {
 		log.Printf("%s | %s", ammId, err)
 		return
 	}
 
-	if reserve > uint64(config.LAMPORTS_PER_SOL) {
-		log.Printf("%s | Pool still have high balance", ammId)
+	input := withdrawInput(ammId, pKey, ix, ins, tx.MempoolTxns, reserve)
+	decision := withdrawRules.Evaluate(input)
+	log.Printf("%s | Withdraw rules | %s | %s | %s", ammId, input, decision, tx.MempoolTxns.Signature)
+
+	if decision.Action != rules.ACTION_TRACK {
 		return
 	}
 
-	bot.TrackedAmm(ammId)
+	err = bot.TrackedAmm(ammId, types.TrackerCause{
+		Reason:    fmt.Sprintf("%s (%s)", storage.REASON_LP_WITHDRAWN, decision.Rule),
+		Signature: tx.MempoolTxns.Signature,
+		Slot:      tx.MempoolTxns.Slot,
+	})
+	if err != nil {
+		log.Printf("%s | %v", ammId, err)
+	}
+}
+
+// Gather what the withdraw rules read, an input that fails to load is left unknown. Inputs
+// costing an RPC call are only fetched when a rule uses them.
+func withdrawInput(ammId *solana.PublicKey, pKey *types.RaydiumPoolKeys, ix coder.Withdraw, ins generators.TxInstruction, tx generators.MempoolTxn, reserve uint64) rules.Input {
+	input := rules.Input{rules.FIELD_RESERVE_LAMPORTS: reserve}
+
+	if withdrawRules.Needs(rules.FIELD_LP_WITHDRAWN_BPS) {
+		if bps, err := liquidity.GetLpShareBps(pKey.LpMint, ix.Amount, tx.Slot, true); err == nil {
+			input[rules.FIELD_LP_WITHDRAWN_BPS] = bps
+		}
+	}
+
+	if withdrawRules.Needs(rules.FIELD_POOL_AGE_SECONDS) {
+		state, err := rpc.GetLiquidityState(ammId)
+		if err == nil {
+			now := uint64(time.Now().Unix())
+			input[rules.FIELD_POOL_AGE_SECONDS] = now - min(now, state.PoolOpenTime)
+		}
+	}
+
+	if withdrawRules.Needs(rules.FIELD_WITHDRAWER_IS_CREATOR) {
+		// The owner moves with the padding accounts, see coder.WithdrawAccountsOf
+		if layout, ok := coder.WithdrawAccountsOf(len(ins.Accounts)); ok {
+			withdrawer, err := getPublicKeyFromTx(layout.UserWallet, tx, ins)
+			creator, creatorErr := bot.GetAmmCreator(ammId)
+			if err == nil && creatorErr == nil && withdrawer != nil && creator != nil {
+				input[rules.FIELD_WITHDRAWER_IS_CREATOR] = *withdrawer == *creator
+			}
+		}
+	}
+
+	if withdrawRules.Needs(rules.FIELD_MINT_AUTHORITY_SET) || withdrawRules.Needs(rules.FIELD_FREEZE_AUTHORITY_SET) {
+		if mint, _, err := liquidity.GetMint(pKey); err == nil {
+			if state, err := rpc.GetMint(mint); err == nil {
+				input[rules.FIELD_MINT_AUTHORITY_SET] = state.MintAuthority != nil
+				input[rules.FIELD_FREEZE_AUTHORITY_SET] = state.FreezeAuthority != nil
+			}
+		}
+	}
+
+	if withdrawRules.Needs(rules.FIELD_LP_STATUS) || withdrawRules.Needs(rules.FIELD_LP_UNLOCK_IN_SECONDS) {
+		if state, err := bot.GetAmmLpStatus(ammId); err == nil {
+			input[rules.FIELD_LP_STATUS] = string(storage.LP_STATUS_UNKNOWN)
+			if state != nil {
+				input[rules.FIELD_LP_STATUS] = string(state.Status)
+			}
+			if state != nil && state.UnlockTime != nil {
+				now := time.Now().Unix()
+				input[rules.FIELD_LP_UNLOCK_IN_SECONDS] = uint64(*state.UnlockTime - min(now, *state.UnlockTime))
+			}
+		}
+	}
+
+	return input
 }
 
 /**
-* Process swap base in instruction
+* Process swap instruction, SwapBaseIn and SwapBaseOut share the same account layout
  */
-func processSwapBaseIn(ins generators.TxInstruction, tx generators.GeyserResponse) {
+func processSwap(name string, ins generators.TxInstruction, tx generators.GeyserResponse) {
 	var ammId *solana.PublicKey
 	var openbookId *solana.PublicKey
 	var sourceTokenAccount *solana.PublicKey
@@ -268,7 +888,7 @@ func processSwapBaseIn(ins generators.TxInstruction, tx generators.GeyserRespons
 	var signerPublicKey *solana.PublicKey
 
 	var err error
-	ammId, err = getPublicKeyFromTx(1, tx.MempoolTxns, ins)
+	ammId, err = getPublicKeyFromTx(coder.SwapAccounts18.Amm, tx.MempoolTxns, ins)
 	if err != nil {
 		return
 	}
@@ -277,33 +897,39 @@ func processSwapBaseIn(ins generators.TxInstruction, tx generators.GeyserRespons
 		return
 	}
 
-	openbookId, err = getPublicKeyFromTx(7, tx.MempoolTxns, ins)
+	openbookId, err = getPublicKeyFromTx(coder.SwapAccounts18.MarketProgram, tx.MempoolTxns, ins)
 	if err != nil {
 		return
 	}
 
-	var sourceAccountIndex int
-	var destinationAccountIndex int
-	var signerAccountIndex int
-
+	layout := coder.SwapAccounts17
 	if openbookId.String() == config.OPENBOOK_ID.String() {
-		sourceAccountIndex = 15
-		destinationAccountIndex = 16
-		signerAccountIndex = 17
-	} else {
-		sourceAccountIndex = 14
-		destinationAccountIndex = 15
-		signerAccountIndex = 16
+		layout = coder.SwapAccounts18
 	}
 
+	sourceAccountIndex := layout.UserSourceToken
+	destinationAccountIndex := layout.UserDestToken
+	signerAccountIndex := layout.UserOwner
+
 	if sourceAccountIndex >= len(ins.Accounts) || destinationAccountIndex >= len(ins.Accounts) || signerAccountIndex >= len(ins.Accounts) {
 		log.Printf("%s | Invalid data length (%d)", ammId, len(ins.Accounts))
 		return
 	}
 
 	sourceTokenAccount, err = getPublicKeyFromTx(sourceAccountIndex, tx.MempoolTxns, ins)
+	if err != nil {
+		return
+	}
+
 	destinationTokenAccount, err = getPublicKeyFromTx(destinationAccountIndex, tx.MempoolTxns, ins)
+	if err != nil {
+		return
+	}
+
 	signerPublicKey, err = getPublicKeyFromTx(signerAccountIndex, tx.MempoolTxns, ins)
+	if err != nil {
+		return
+	}
 
 	if sourceTokenAccount == nil || destinationTokenAccount == nil || signerPublicKey == nil {
 		return
@@ -320,6 +946,9 @@ func processSwapBaseIn(ins generators.TxInstruction, tx generators.GeyserRespons
 	}
 
 	tracker, err := bot.GetAmmTrackingStatus(ammId)
+	if err != nil {
+		return
+	}
 
 	if tracker.Status != storage.TRACKED_TRIGGER_ONLY {
 		return
@@ -328,24 +957,47 @@ func processSwapBaseIn(ins generators.TxInstruction, tx generators.GeyserRespons
 	amount := bot.GetBalanceFromTransaction(tx.MempoolTxns.PreTokenBalances, tx.MempoolTxns.PostTokenBalances, mint)
 	amountSol := bot.GetBalanceFromTransaction(tx.MempoolTxns.PreTokenBalances, tx.MempoolTxns.PostTokenBalances, config.WRAPPED_SOL)
 
-	if amount.Sign() == 1 {
-		if amountSol.Cmp(big.NewInt(0)) == 1 {
-			log.Printf("%s | %s | Potential entry %d SOL (Slot %d) | %s", pKey.ID, tx.MempoolTxns.Source, amountSol, tx.MempoolTxns.Slot, tx.MempoolTxns.Signature)
+	action, ok := bot.GetSwapDirection(amount, amountSol)
+	if !ok {
+		return
+	}
+
+	if action == bot.TRADE_BUY {
+		log.Printf("%s | %s | %s | Potential entry %d SOL (Slot %d) | %s", pKey.ID, tx.MempoolTxns.Source, name, big.NewInt(0).Abs(amountSol), tx.MempoolTxns.Slot, tx.MempoolTxns.Signature)
 
-			bot.SetTrade(&types.Trade{
-				AmmId:     ammId,
-				Mint:      &mint,
-				Action:    "BUY",
-				Amount:    big.NewInt(0).Abs(amount).String(),
-				Signature: tx.MempoolTxns.Signature,
-			})
+		if entryTrader != nil && tx.MempoolTxns.Error == "" {
+			enter(pKey, tx.MempoolTxns)
 		}
 	}
 
-	// Only proceed if the amount is greater than 0.011 SOL and amount of SOL is a negative number (represent buy action)
-	// log.Printf("%s | %d | %s | %s", ammId, amount.Sign(), amountSol, tx.MempoolTxns.Signature)
-	// sniper(amount *big.Int, amountSol *big.Int, pKey *types.RaydiumPoolKeys, tx generators.GeyserResponse)
+	bot.SetTrade(&types.Trade{
+		AmmId:     ammId,
+		Mint:      &mint,
+		Action:    action,
+		Amount:    big.NewInt(0).Abs(amount).String(),
+		Signature: tx.MempoolTxns.Signature,
+	})
 
 	// Machine gun technique
 	// Sniper technique
 }
+
+func enter(pKey *types.RaydiumPoolKeys, tx generators.MempoolTxn) {
+	blockhash, err := solana.HashFromBase58(tx.RecentBlockhash)
+	if err != nil {
+		return
+	}
+
+	err = entryTrader.Enter(trader.Entry{
+		PoolKeys:         pKey,
+		TriggerSignature: tx.Signature,
+		TriggerSlot:      tx.Slot,
+		Blockhash:        blockhash,
+	})
+	switch {
+	case errors.Is(err, trader.ErrEntryPending), errors.Is(err, trader.ErrExposureReached), errors.Is(err, trader.ErrExiting):
+		log.Printf("%s | Entry skipped: %v", pKey.ID, err)
+	case err != nil:
+		log.Printf("%s | Entry failed: %v", pKey.ID, err)
+	}
+}
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS paper_trades (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    strategy VARCHAR(64),
+    amm_id VARCHAR(255),
+    mint VARCHAR(255),
+    action VARCHAR(255),
+    amount_in BIGINT UNSIGNED,
+    amount_out BIGINT UNSIGNED,
+    minimum_amount_out BIGINT UNSIGNED,
+    price_impact DOUBLE,
+    cost BIGINT UNSIGNED,
+    trigger_signature VARCHAR(255),
+    trigger_slot BIGINT UNSIGNED,
+    landing_slot BIGINT UNSIGNED,
+    mark_slot BIGINT UNSIGNED,
+    mark_value BIGINT UNSIGNED,
+    pnl BIGINT,
+    transaction TEXT,
+    timestamp INT
+);
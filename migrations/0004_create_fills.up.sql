@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS fills (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    strategy VARCHAR(64),
+    paper BOOLEAN,
+    signature VARCHAR(255),
+    amm_id VARCHAR(255),
+    mint VARCHAR(255),
+    action VARCHAR(255),
+    sol_in BIGINT UNSIGNED,
+    sol_out BIGINT UNSIGNED,
+    token_in BIGINT UNSIGNED,
+    token_out BIGINT UNSIGNED,
+    fee BIGINT UNSIGNED,
+    priority_fee BIGINT UNSIGNED,
+    tip BIGINT UNSIGNED,
+    realized_pnl BIGINT,
+    slot BIGINT UNSIGNED,
+    block_time INT
+);
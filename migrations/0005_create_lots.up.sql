@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS lots (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    fill_id BIGINT,
+    strategy VARCHAR(64),
+    paper BOOLEAN,
+    amm_id VARCHAR(255),
+    mint VARCHAR(255),
+    token_remaining BIGINT UNSIGNED,
+    cost_remaining BIGINT UNSIGNED,
+    INDEX open_lots (strategy, paper, mint, token_remaining)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS pnl_daily (
+    strategy VARCHAR(64),
+    paper BOOLEAN,
+    day DATE,
+    realized_pnl BIGINT DEFAULT 0,
+    unrealized_pnl BIGINT DEFAULT 0,
+    fees BIGINT UNSIGNED DEFAULT 0,
+    buys INT DEFAULT 0,
+    sells INT DEFAULT 0,
+    PRIMARY KEY (strategy, paper, day)
+);
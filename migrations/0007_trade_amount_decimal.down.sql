@@ -0,0 +1 @@
+ALTER TABLE trades MODIFY amount BIGINT;
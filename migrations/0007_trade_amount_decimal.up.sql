@@ -0,0 +1 @@
+ALTER TABLE trades MODIFY amount DECIMAL(39, 0);
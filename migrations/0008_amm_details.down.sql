@@ -0,0 +1,25 @@
+ALTER TABLE amms
+    DROP INDEX status,
+    DROP INDEX creator,
+    DROP PRIMARY KEY,
+    DROP COLUMN created_at,
+    DROP COLUMN status_updated_at,
+    DROP COLUMN status,
+    DROP COLUMN reserve_updated_at,
+    DROP COLUMN reserve_slot,
+    DROP COLUMN first_seen_slot,
+    DROP COLUMN creator,
+    DROP COLUMN init_coin_amount,
+    DROP COLUMN init_pc_amount,
+    DROP COLUMN open_time,
+    DROP COLUMN lp_decimals,
+    DROP COLUMN quote_decimals,
+    DROP COLUMN base_decimals,
+    DROP COLUMN market_program_id,
+    DROP COLUMN market_id,
+    DROP COLUMN quote_vault,
+    DROP COLUMN base_vault,
+    DROP COLUMN lp_mint,
+    MODIFY quote BIGINT,
+    MODIFY base BIGINT,
+    MODIFY amm_id VARCHAR(255);
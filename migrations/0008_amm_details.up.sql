@@ -0,0 +1,25 @@
+ALTER TABLE amms
+    MODIFY amm_id VARCHAR(255) NOT NULL,
+    MODIFY base BIGINT UNSIGNED,
+    MODIFY quote BIGINT UNSIGNED,
+    ADD COLUMN lp_mint VARCHAR(255),
+    ADD COLUMN base_vault VARCHAR(255),
+    ADD COLUMN quote_vault VARCHAR(255),
+    ADD COLUMN market_id VARCHAR(255),
+    ADD COLUMN market_program_id VARCHAR(255),
+    ADD COLUMN base_decimals INT,
+    ADD COLUMN quote_decimals INT,
+    ADD COLUMN lp_decimals INT,
+    ADD COLUMN open_time BIGINT UNSIGNED,
+    ADD COLUMN init_pc_amount BIGINT UNSIGNED,
+    ADD COLUMN init_coin_amount BIGINT UNSIGNED,
+    ADD COLUMN creator VARCHAR(255),
+    ADD COLUMN first_seen_slot BIGINT UNSIGNED,
+    ADD COLUMN reserve_slot BIGINT UNSIGNED,
+    ADD COLUMN reserve_updated_at INT,
+    ADD COLUMN status VARCHAR(32),
+    ADD COLUMN status_updated_at INT,
+    ADD COLUMN created_at INT,
+    ADD PRIMARY KEY (amm_id),
+    ADD INDEX creator (creator),
+    ADD INDEX status (status);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS tracker_history (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    amm_id VARCHAR(255) NOT NULL,
+    from_status VARCHAR(32),
+    to_status VARCHAR(32),
+    reason VARCHAR(255),
+    signature VARCHAR(255),
+    slot BIGINT UNSIGNED,
+    timestamp INT,
+    INDEX amm_history (amm_id, id)
+);
@@ -0,0 +1,7 @@
+ALTER TABLE amms
+    DROP INDEX lp_mint,
+    DROP COLUMN lp_status_updated_at,
+    DROP COLUMN lp_status_slot,
+    DROP COLUMN lp_status_signature,
+    DROP COLUMN lp_unlock_time,
+    DROP COLUMN lp_status;
@@ -0,0 +1,7 @@
+ALTER TABLE amms
+    ADD COLUMN lp_status VARCHAR(16),
+    ADD COLUMN lp_unlock_time BIGINT,
+    ADD COLUMN lp_status_signature VARCHAR(255),
+    ADD COLUMN lp_status_slot BIGINT UNSIGNED,
+    ADD COLUMN lp_status_updated_at INT,
+    ADD INDEX lp_mint (lp_mint);
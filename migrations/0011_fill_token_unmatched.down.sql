@@ -0,0 +1 @@
+ALTER TABLE fills DROP COLUMN token_unmatched;
@@ -0,0 +1 @@
+ALTER TABLE fills ADD COLUMN token_unmatched BIGINT UNSIGNED NOT NULL DEFAULT 0;
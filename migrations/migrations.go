@@ -0,0 +1,9 @@
+// Package migrations embeds the schema migrations, applied in version order by the runner in
+// internal/database. Each version has an up and a down file named <version>_<name>.<up|down>.sql.
+// Applied files must not be edited, add a new version instead.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS